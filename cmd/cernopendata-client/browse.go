@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/browser"
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+var browseCmd = &cobra.Command{
+	Use:   "browse",
+	Short: "Browse records and select files interactively",
+	Long: `Browse records and select files interactively.
+
+Search records with facet filters, scroll through the results, inspect the
+metadata of a record, drill into its file indexes, select files (sizes and
+availability are shown) and download the selection, all in one session.
+
+On a terminal the browser runs full screen and is driven with the keys:
+arrows to move, Enter to open, Esc to go back, / to search, f for facets,
+space to select files and d to download them. Press ? for the list of keys.
+
+Otherwise the browser reads one command per line; type 'help' for the list
+of commands. When the standard input is not a terminal, or with --script,
+the commands are read without prompting, so sessions can be scripted; the
+command then exits with status 1 if any command failed. Use --line-mode for
+the commands on a terminal.
+
+Examples:
+
+     $ cernopendata-client browse
+
+     $ cernopendata-client browse --query-pattern "DoubleMuParked" --query-facet experiment=CMS
+
+     $ printf 'search Higgs\nopen 1\nfiles\nselect all\ndownload higgs\n' | cernopendata-client browse
+
+     $ cernopendata-client browse --script session.txt --download-engine xrootd`,
+	Run: func(cmd *cobra.Command, args []string) {
+		queryPattern, _ := cmd.Flags().GetString("query-pattern")
+		queryFacets, _ := cmd.Flags().GetStringArray("query-facet")
+		script, _ := cmd.Flags().GetString("script")
+		lineMode, _ := cmd.Flags().GetBool("line-mode")
+		pageSize, _ := cmd.Flags().GetInt("page-size")
+		protocol, _ := cmd.Flags().GetString("protocol")
+		downloadEngine, _ := cmd.Flags().GetString("download-engine")
+		retryLimit, _ := cmd.Flags().GetInt("retry-limit")
+		retrySleep, _ := cmd.Flags().GetInt("retry-sleep")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		server, _ := cmd.Flags().GetString("server")
+
+		if pageSize < 1 {
+			printer.DisplayMessage(printer.Error, "--page-size must be at least 1")
+			os.Exit(1)
+		}
+
+		if downloadEngine != "" && downloadEngine != "http" && downloadEngine != "xrootd" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid download engine: %s (choose from 'http', 'xrootd')", downloadEngine))
+			os.Exit(1)
+		}
+
+		if protocol == "" {
+			if downloadEngine == "xrootd" {
+				protocol = "xrootd"
+			} else {
+				protocol = "http"
+			}
+		}
+		if protocol != "http" && protocol != "xrootd" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid protocol: %s (choose from 'http', 'xrootd')", protocol))
+			os.Exit(1)
+		}
+		// Each download engine only handles the URIs of its own protocol.
+		engine := downloadEngine
+		if engine == "" {
+			engine = "http"
+		}
+		if protocol != engine {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("--protocol %s cannot be used with the %s download engine, use --download-engine %s", protocol, engine, protocol))
+			os.Exit(1)
+		}
+
+		facetsMap := make(map[string]string)
+		for _, qf := range queryFacets {
+			parts := strings.SplitN(qf, "=", 2)
+			if len(parts) != 2 {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid facet format: %s (expected key=value)", qf))
+				os.Exit(1)
+			}
+			facetsMap[parts[0]] = parts[1]
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		var input io.Reader = os.Stdin
+		interactive := browser.IsTerminal(os.Stdin)
+		if script != "" {
+			f, err := os.Open(script) // #nosec G304
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to open script: %v", err))
+				os.Exit(1)
+			}
+			defer func() { _ = f.Close() }()
+			input = f
+			interactive = false
+		}
+
+		b := browser.New(searcher.NewClient(server), input, os.Stdout)
+		b.Interactive = interactive
+		b.PageSize = pageSize
+		b.Protocol = protocol
+		b.OnDownload = func(files []searcher.FileInfo, dir string) error {
+			var fileList []any
+			for _, file := range files {
+				fileList = append(fileList, map[string]any{
+					"uri":      file.URI,
+					"size":     float64(file.Size),
+					"checksum": file.Checksum,
+				})
+			}
+			stats := runDownload(cmd, downloadEngine, fileList, dir, retryLimit, retrySleep, false, dryRun)
+			if stats.FailedFiles > 0 {
+				return fmt.Errorf("%d of %d files failed to download", stats.FailedFiles, stats.TotalFiles)
+			}
+			return nil
+		}
+
+		if interactive && !lineMode && browser.IsTerminal(os.Stdout) {
+			t := browser.NewTUI(b)
+			if queryPattern != "" || len(facetsMap) > 0 {
+				if err := t.Search(queryPattern, facetsMap); err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Search failed: %v", err))
+					os.Exit(1)
+				}
+			}
+			if err := browser.RunTerminal(t, os.Stdin, os.Stdout); err != nil {
+				printer.DisplayMessage(printer.Error, err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+
+		if queryPattern != "" || len(facetsMap) > 0 {
+			if err := b.Search(queryPattern, facetsMap); err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Search failed: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		if err := b.Run(); err != nil {
+			printer.DisplayMessage(printer.Error, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	browseCmd.Flags().String("query-pattern", "", "Initial free text search pattern")
+	browseCmd.Flags().StringArrayP("query-facet", "f", []string{}, "Initial facet filter in key=value format (can be repeated)")
+	browseCmd.Flags().String("script", "", "Read the browser commands from this file")
+	browseCmd.Flags().Bool("line-mode", false, "Read one command per line even on a terminal")
+	browseCmd.Flags().Int("page-size", 10, "Number of search results per page")
+	browseCmd.Flags().StringP("protocol", "p", "", "Protocol to be used in links [http,xrootd]")
+	browseCmd.Flags().String("download-engine", "", "Download engine to use (http|xrootd)")
+	browseCmd.Flags().IntP("retry-limit", "y", 10, "Number of retries when downloading a file")
+	browseCmd.Flags().IntP("retry-sleep", "Y", 5, "Sleep time in seconds before retrying downloads")
+	browseCmd.Flags().BoolP("dry-run", "N", false, "Dry run (don't actually download)")
+	browseCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/citer"
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+var citeCmd = &cobra.Command{
+	Use:   "cite",
+	Short: "Export citations for records",
+	Long: `Export citations for records.
+
+Select one or more CERN Open Data records by record ID, DOI, title,
+a search query, or metadata saved with get-metadata --format json,
+and render citations in BibTeX, RIS, CSL-JSON or plain text. Records
+sharing a DOI are cited only once.
+
+Examples:
+
+     $ cernopendata-client cite --recid 1
+
+     $ cernopendata-client cite --recid 1 --recid 2 --style ris
+
+     $ cernopendata-client cite --doi 10.7483/OPENDATA.CMS.A342.9982 --style csl-json
+
+     $ cernopendata-client cite --query-pattern "Higgs" --query-facet experiment=CMS
+
+     $ cernopendata-client cite --input record-1.json --style text`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recids, _ := cmd.Flags().GetIntSlice("recid")
+		dois, _ := cmd.Flags().GetStringArray("doi")
+		titles, _ := cmd.Flags().GetStringArray("title")
+		inputs, _ := cmd.Flags().GetStringArray("input")
+		queryPattern, _ := cmd.Flags().GetString("query-pattern")
+		queryFacets, _ := cmd.Flags().GetStringArray("query-facet")
+		style, _ := cmd.Flags().GetString("style")
+		server, _ := cmd.Flags().GetString("server")
+
+		if style != "bibtex" && style != "ris" && style != "csl-json" && style != "text" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid style: %s (choose from 'bibtex', 'ris', 'csl-json', 'text')", style))
+			os.Exit(1)
+		}
+
+		if len(recids) == 0 && len(dois) == 0 && len(titles) == 0 && len(inputs) == 0 && queryPattern == "" && len(queryFacets) == 0 {
+			printer.DisplayMessage(printer.Error, "Please provide recid, doi, title, input or a search query")
+			os.Exit(1)
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		var metadataList []map[string]any
+
+		for _, input := range inputs {
+			record, err := searcher.LoadRecordFile(input)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load record: %v", err))
+				os.Exit(1)
+			}
+			metadataList = append(metadataList, record.Metadata)
+		}
+
+		client := searcher.NewClient(server)
+
+		for _, doi := range dois {
+			parsedRecid, err := searcher.GetRecid(server, doi, "", 0)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+				os.Exit(1)
+			}
+			recids = append(recids, parsedRecid)
+		}
+
+		for _, title := range titles {
+			parsedRecid, err := searcher.GetRecid(server, "", title, 0)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+				os.Exit(1)
+			}
+			recids = append(recids, parsedRecid)
+		}
+
+		for _, recid := range recids {
+			record, err := client.GetRecord(recid)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get metadata: %v", err))
+				os.Exit(1)
+			}
+			metadataList = append(metadataList, record.Metadata)
+		}
+
+		if queryPattern != "" || len(queryFacets) > 0 {
+			facetsMap := make(map[string]string)
+			for _, qf := range queryFacets {
+				parts := strings.SplitN(qf, "=", 2)
+				if len(parts) != 2 {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid facet format: %s (expected key=value)", qf))
+					os.Exit(1)
+				}
+				facetsMap[parts[0]] = parts[1]
+			}
+
+			searchResp, err := client.SearchAllRecords(queryPattern, facetsMap, "")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Search failed: %v", err))
+				os.Exit(1)
+			}
+			for _, hit := range searchResp.Hits.Hits {
+				metadataList = append(metadataList, hit.Metadata)
+			}
+		}
+
+		var citations []*citer.Citation
+		for _, metadata := range metadataList {
+			citation, err := citer.FromMetadata(metadata)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to build citation: %v", err))
+				os.Exit(1)
+			}
+			citations = append(citations, citation)
+		}
+
+		output, err := citer.Format(citer.Deduplicate(citations), style)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format citations: %v", err))
+			os.Exit(1)
+		}
+		printer.DisplayOutput(output)
+	},
+}
+
+func init() {
+	citeCmd.Flags().IntSliceP("recid", "r", nil, "Record ID (can be repeated)")
+	citeCmd.Flags().StringArrayP("doi", "d", nil, "Digital Object Identifier (can be repeated)")
+	citeCmd.Flags().StringArrayP("title", "t", nil, "Record title (exact match, can be repeated)")
+	citeCmd.Flags().StringArrayP("input", "i", nil, "Metadata file saved with get-metadata --format json (can be repeated)")
+	citeCmd.Flags().String("query-pattern", "", "Cite all records matching a free text search pattern")
+	citeCmd.Flags().StringArrayP("query-facet", "f", []string{}, "Facet filter in key=value format (can be repeated)")
+	citeCmd.Flags().StringP("style", "m", "bibtex", "Citation style (bibtex|ris|csl-json|text)")
+	citeCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+}
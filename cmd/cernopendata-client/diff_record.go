@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/differ"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+var diffRecordCmd = &cobra.Command{
+	Use:   "diff-record",
+	Short: "Compare two snapshots of a record",
+	Long: `Compare two snapshots of a record.
+
+Compare a record saved with get-metadata --format json against the
+live record on the portal, or two saved snapshots against each other,
+and report changed metadata fields as well as added, removed, resized
+and re-checksummed files. The command exits with status 1 when
+differences are found.
+
+Examples:
+
+     $ cernopendata-client get-metadata --recid 5500 --format json > 5500.json
+
+     $ cernopendata-client diff-record --recid 5500 --old 5500.json
+
+     $ cernopendata-client diff-record --old 5500-2024.json --new 5500-2025.json
+
+     $ cernopendata-client diff-record --recid 5500 --old 5500.json --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		oldPath, _ := cmd.Flags().GetString("old")
+		newPath, _ := cmd.Flags().GetString("new")
+		expand, _ := cmd.Flags().GetBool("expand")
+		noExpand, _ := cmd.Flags().GetBool("no-expand")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		if outputFormat != "text" && outputFormat != "json" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json')", outputFormat))
+			os.Exit(1)
+		}
+
+		if oldPath == "" {
+			printer.DisplayMessage(printer.Error, "--old is required")
+			os.Exit(1)
+		}
+
+		if cmd.Flags().Changed("expand") && cmd.Flags().Changed("no-expand") {
+			printer.DisplayMessage(printer.Error, "Cannot specify both --expand and --no-expand")
+			os.Exit(1)
+		}
+
+		if noExpand {
+			expand = false
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		oldRecord, err := searcher.LoadRecordFile(oldPath)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load record: %v", err))
+			os.Exit(1)
+		}
+
+		client := searcher.NewClient(server)
+
+		var newRecord *searcher.RecordResponse
+		if newPath != "" {
+			newRecord, err = searcher.LoadRecordFile(newPath)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load record: %v", err))
+				os.Exit(1)
+			}
+		} else {
+			if recid == 0 && doi == "" && title == "" {
+				recid, _ = strconv.Atoi(oldRecord.ID)
+			}
+			parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+			if !ok {
+				return
+			}
+			newRecord, err = client.GetRecord(parsedRecid)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		oldFiles, err := client.GetFilesList(oldRecord, "", expand)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get files list: %v", err))
+			os.Exit(1)
+		}
+		newFiles, err := client.GetFilesList(newRecord, "", expand)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get files list: %v", err))
+			os.Exit(1)
+		}
+
+		result := differ.Diff(oldRecord.Metadata, newRecord.Metadata, oldFiles, newFiles)
+
+		if outputFormat == "json" {
+			jsonBytes, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+				os.Exit(1)
+			}
+			printer.DisplayOutput(string(jsonBytes))
+		} else {
+			printer.DisplayOutput(differ.FormatText(result))
+		}
+
+		if !result.Empty() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	diffRecordCmd.Flags().IntP("recid", "r", 0, "Record ID of the live record (defaults to the recid of --old)")
+	diffRecordCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier of the live record")
+	diffRecordCmd.Flags().StringP("title", "t", "", "Record title of the live record")
+	diffRecordCmd.Flags().StringP("old", "o", "", "Saved record snapshot to compare from")
+	diffRecordCmd.Flags().StringP("new", "n", "", "Saved record snapshot to compare to (defaults to the live record)")
+	diffRecordCmd.Flags().BoolP("expand", "x", true, "Expand file indexes?")
+	diffRecordCmd.Flags().Bool("no-expand", false, "Don't expand file indexes")
+	diffRecordCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	diffRecordCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(diffRecordCmd)
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -10,6 +12,7 @@ import (
 	"github.com/clelange/cernopendata-client-go/internal/config"
 	"github.com/clelange/cernopendata-client-go/internal/downloader"
 	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/relater"
 	"github.com/clelange/cernopendata-client-go/internal/searcher"
 	"github.com/clelange/cernopendata-client-go/internal/utils"
 	"github.com/clelange/cernopendata-client-go/internal/verifier"
@@ -34,7 +37,11 @@ Examples:
 
      $ cernopendata-client download-files --recid 5500 --filter-range 1-4
 
-     $ cernopendata-client download-files --recid 5500 --filter-range 1-2,5-7`,
+     $ cernopendata-client download-files --recid 5500 --filter-range 1-2,5-7
+
+     $ cernopendata-client download-files --recid 6004 --index CMS_Run2012B_DoubleMuParked_AOD_22Jan2013-v1_10000_file_index
+
+     $ cernopendata-client download-files --recid 6004 --filter-range 1-2 --with-related Validation --with-related Configuration`,
 	Run: func(cmd *cobra.Command, args []string) {
 		recid, err := cmd.Flags().GetInt("recid")
 		if err != nil {
@@ -44,11 +51,6 @@ Examples:
 		doi, _ := cmd.Flags().GetString("doi")
 		title, _ := cmd.Flags().GetString("title")
 		outputDir, _ := cmd.Flags().GetString("output-dir")
-		filterName, _ := cmd.Flags().GetString("filter-name")
-		filterRegexp, _ := cmd.Flags().GetString("filter-regexp")
-		filterRange, _ := cmd.Flags().GetString("filter-range")
-		expand, _ := cmd.Flags().GetBool("expand")
-		noExpand, _ := cmd.Flags().GetBool("no-expand")
 		retryLimit, _ := cmd.Flags().GetInt("retry-limit")
 		retrySleep, _ := cmd.Flags().GetInt("retry-sleep")
 		verbose, _ := cmd.Flags().GetBool("verbose")
@@ -57,30 +59,17 @@ Examples:
 		downloadEngine, _ := cmd.Flags().GetString("download-engine")
 		protocol, _ := cmd.Flags().GetString("protocol")
 		server, _ := cmd.Flags().GetString("server")
-		fileAvailability, _ := cmd.Flags().GetString("file-availability")
+		withRelated, _ := cmd.Flags().GetStringArray("with-related")
 
-		if fileAvailability != "" && fileAvailability != "online" && fileAvailability != "all" {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid file availability: %s (choose from 'online', 'all')", fileAvailability))
-			os.Exit(1)
-		}
-
-		if cmd.Flags().Changed("expand") && cmd.Flags().Changed("no-expand") {
-			printer.DisplayMessage(printer.Error, "Cannot specify both --expand and --no-expand")
-			os.Exit(1)
-		}
-
-		if noExpand {
-			expand = false
-		}
+		selection := parseFileSelection(cmd, "download")
 
 		if server == "" {
 			server = config.ServerHTTPURI
 		}
 
-		parsedRecid, err := searcher.GetRecid(server, doi, title, recid)
-		if err != nil {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
-			os.Exit(1)
+		parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+		if !ok {
+			return
 		}
 
 		if outputDir == "" {
@@ -102,105 +91,16 @@ Examples:
 			}
 		}
 
-		files, err := client.GetFilesList(record, protocol, expand)
-		if err != nil {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get files list: %v", err))
-			os.Exit(1)
-		}
-		totalFiles := len(files)
-		var totalBytes int64
-		for _, f := range files {
-			totalBytes += f.Size
-		}
-
-		tapeFilesSkipped := 0
-		if expand {
-			// Check if we have offline files
-			hasOfflineFiles := false
-			for _, f := range files {
-				if f.Availability != "" && f.Availability != "online" {
-					hasOfflineFiles = true
-					break
-				}
-			}
-
-			// Apply filtering logic
-			if fileAvailability == "online" {
-				files, _ = searcher.FilterFilesByAvailability(files, "online")
-			} else if fileAvailability == "" && hasOfflineFiles {
-				// Default behavior: warn and skip offline files
-				printer.DisplayMessage(printer.Warning, "Some files are stored on tape and will be skipped.")
-				printer.DisplayMessage(printer.Warning, fmt.Sprintf("Visit https://opendata.cern.ch/record/%d to request file staging.", parsedRecid))
-				printer.DisplayMessage(printer.Warning, "Use '--file-availability all' to force attempting to download all files.")
-				files, _ = searcher.FilterFilesByAvailability(files, "online")
-			}
-			// If "all", we keep everything (user explicitly requested it)
-
-			tapeFilesSkipped = totalFiles - len(files)
-		}
-		var fileList []any
-		for _, file := range files {
-			fileList = append(fileList, map[string]any{
-				"uri":      file.URI,
-				"size":     float64(file.Size),
-				"checksum": file.Checksum,
-			})
-		}
-
-		if filterName != "" {
-			nameFilters := strings.Split(filterName, ",")
-			for i, filter := range nameFilters {
-				nameFilters[i] = strings.TrimSpace(filter)
-			}
-			fileList = downloader.FilterFilesByMultipleNames(fileList, nameFilters)
-		}
-
-		if filterRegexp != "" {
-			fileList = downloader.FilterFilesByRegex(fileList, filterRegexp)
-		}
-
-		if filterRange != "" {
-			ranges, err := utils.ParseRanges([]string{filterRange})
-			if err != nil {
-				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid range filter: %v", err))
-				os.Exit(1)
-			}
-			fileList = downloader.FilterFilesByMultipleRanges(fileList, ranges)
-		}
+		fileList, totalFiles, totalBytes, tapeFilesSkipped := selection.apply(client, record, parsedRecid, protocol)
 
-		if len(fileList) == 0 {
-			printer.DisplayMessage(printer.Error, "No files matching filters")
-			os.Exit(1)
-		}
+		stats := runDownload(cmd, downloadEngine, fileList, outputDir, retryLimit, retrySleep, verbose, dryRun)
 
-		var stats downloader.DownloadStats
-		if downloadEngine == "xrootd" {
-			xrdDownloader := xrootddownloader.NewDownloader()
-			defer func() {
-				_ = xrdDownloader.Close()
-			}()
-			// Enable progress when --progress or --verbose flags are set
-			showProgress := verbose
-			if progressFlag, _ := cmd.Flags().GetBool("progress"); progressFlag {
-				showProgress = true
-			}
-			xrdStats := xrdDownloader.DownloadFiles(cmd.Context(), fileList, outputDir, retryLimit, retrySleep, verbose, dryRun, showProgress)
-			stats = downloader.DownloadStats{
-				TotalFiles:      xrdStats.TotalFiles,
-				TotalBytes:      xrdStats.TotalBytes,
-				DownloadedFiles: xrdStats.DownloadedFiles,
-				DownloadedBytes: xrdStats.DownloadedBytes,
-				FailedFiles:     xrdStats.FailedFiles,
-				SkippedFiles:    xrdStats.SkippedFiles,
-			}
-		} else {
-			httpDownloader := downloader.NewDownloader()
-			// Enable progress when --progress or --verbose flags are set
-			showProgress := verbose
-			if progressFlag, _ := cmd.Flags().GetBool("progress"); progressFlag {
-				showProgress = true
+		var related []relatedDownload
+		if len(withRelated) > 0 {
+			related = downloadRelated(cmd, client, parsedRecid, withRelated, protocol, downloadEngine, outputDir, retryLimit, retrySleep, verbose, dryRun)
+			for _, r := range related {
+				stats.FailedFiles += r.stats.FailedFiles
 			}
-			stats = httpDownloader.DownloadFiles(fileList, outputDir, retryLimit, retrySleep, verbose, dryRun, showProgress)
 		}
 
 		if verifyFlag {
@@ -216,6 +116,18 @@ Examples:
 				printer.DisplayMessage(printer.Error, "Some files failed verification")
 				os.Exit(1)
 			}
+
+			for _, r := range related {
+				verifyStats, err := v.VerifyFiles(r.dir, r.files)
+				if err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Verification of related record %d failed: %v", r.recid, err))
+					os.Exit(1)
+				}
+				if verifyStats.SizeFailed > 0 || verifyStats.ChecksumFailed > 0 {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Some files of related record %d failed verification", r.recid))
+					os.Exit(1)
+				}
+			}
 		}
 
 		if stats.FailedFiles == 0 {
@@ -230,6 +142,9 @@ Examples:
 			printer.DisplayOutput(fmt.Sprintf("- Files skipped (on tape): %d", tapeFilesSkipped))
 		}
 		printer.DisplayOutput(fmt.Sprintf("- Bytes downloaded: %s / %s", utils.FormatBytes(float64(stats.DownloadedBytes)), utils.FormatBytes(float64(totalBytes))))
+		for _, r := range related {
+			printer.DisplayOutput(fmt.Sprintf("- Related record %d: %d / %d files in %s", r.recid, r.stats.DownloadedFiles, r.stats.TotalFiles, r.dir))
+		}
 
 		if stats.FailedFiles > 0 {
 			os.Exit(1)
@@ -257,4 +172,252 @@ func init() {
 	downloadFilesCmd.Flags().StringP("protocol", "p", "", "Protocol to be used in links [http,xrootd]")
 	downloadFilesCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
 	downloadFilesCmd.Flags().StringP("file-availability", "", "", "Filter files by their availability status [online, all]")
+	downloadFilesCmd.Flags().StringArray("index", nil, "Download only files from the file index with this key, key without extension, or glob (can be repeated)")
+	downloadFilesCmd.Flags().StringArray("with-related", nil, "Also download the files of related records of this type, e.g. Validation or Configuration, or 'all' (can be repeated)")
+	addResolveFlags(downloadFilesCmd)
+}
+
+// fileSelection holds the flags selecting the files of a record that are
+// shared by download-files and verify-files.
+type fileSelection struct {
+	action           string
+	expand           bool
+	fileAvailability string
+	indexPatterns    []string
+	filterName       string
+	filterRegexp     string
+	filterRange      string
+}
+
+// parseFileSelection reads and checks the file selection flags. The action
+// ("download" or "verify") is used in the messages about tape files.
+func parseFileSelection(cmd *cobra.Command, action string) fileSelection {
+	expand, _ := cmd.Flags().GetBool("expand")
+	noExpand, _ := cmd.Flags().GetBool("no-expand")
+	fileAvailability, _ := cmd.Flags().GetString("file-availability")
+	indexPatterns, _ := cmd.Flags().GetStringArray("index")
+	filterName, _ := cmd.Flags().GetString("filter-name")
+	filterRegexp, _ := cmd.Flags().GetString("filter-regexp")
+	filterRange, _ := cmd.Flags().GetString("filter-range")
+
+	if fileAvailability != "" && fileAvailability != "online" && fileAvailability != "all" {
+		printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid file availability: %s (choose from 'online', 'all')", fileAvailability))
+		os.Exit(1)
+	}
+
+	if cmd.Flags().Changed("expand") && cmd.Flags().Changed("no-expand") {
+		printer.DisplayMessage(printer.Error, "Cannot specify both --expand and --no-expand")
+		os.Exit(1)
+	}
+
+	if noExpand {
+		expand = false
+	}
+
+	if len(indexPatterns) > 0 && !expand {
+		printer.DisplayMessage(printer.Error, "Cannot specify both --index and --no-expand")
+		os.Exit(1)
+	}
+
+	return fileSelection{
+		action:           action,
+		expand:           expand,
+		fileAvailability: fileAvailability,
+		indexPatterns:    indexPatterns,
+		filterName:       filterName,
+		filterRegexp:     filterRegexp,
+		filterRange:      filterRange,
+	}
+}
+
+// apply lists the files of the record and applies the selection. It returns
+// the selected files, the number and size of all files of the record, and
+// the number of files skipped because they are on tape.
+func (s fileSelection) apply(client *searcher.Client, record *searcher.RecordResponse, recid int, protocol string) ([]any, int, int64, int) {
+	var files []searcher.FileInfo
+	var err error
+	if len(s.indexPatterns) > 0 {
+		files, err = client.GetIndexFilesList(record, protocol, s.indexPatterns)
+	} else {
+		files, err = client.GetFilesList(record, protocol, s.expand)
+	}
+	if err != nil {
+		printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get files list: %v", err))
+		os.Exit(1)
+	}
+	totalFiles := len(files)
+	var totalBytes int64
+	for _, f := range files {
+		totalBytes += f.Size
+	}
+
+	tapeFilesSkipped := 0
+	if s.expand {
+		// Check if we have offline files
+		hasOfflineFiles := false
+		for _, f := range files {
+			if f.Availability != "" && f.Availability != "online" {
+				hasOfflineFiles = true
+				break
+			}
+		}
+
+		// Apply filtering logic
+		if s.fileAvailability == "online" {
+			files, _ = searcher.FilterFilesByAvailability(files, "online")
+		} else if s.fileAvailability == "" && hasOfflineFiles {
+			// Default behavior: warn and skip offline files
+			printer.DisplayMessage(printer.Warning, "Some files are stored on tape and will be skipped.")
+			printer.DisplayMessage(printer.Warning, fmt.Sprintf("Visit https://opendata.cern.ch/record/%d to request file staging.", recid))
+			printer.DisplayMessage(printer.Warning, fmt.Sprintf("Use '--file-availability all' to force attempting to %s all files.", s.action))
+			files, _ = searcher.FilterFilesByAvailability(files, "online")
+		}
+		// If "all", we keep everything (user explicitly requested it)
+
+		tapeFilesSkipped = totalFiles - len(files)
+	}
+	var fileList []any
+	for _, file := range files {
+		fileList = append(fileList, map[string]any{
+			"uri":      file.URI,
+			"size":     float64(file.Size),
+			"checksum": file.Checksum,
+		})
+	}
+
+	if s.filterName != "" {
+		nameFilters := strings.Split(s.filterName, ",")
+		for i, filter := range nameFilters {
+			nameFilters[i] = strings.TrimSpace(filter)
+		}
+		fileList = downloader.FilterFilesByMultipleNames(fileList, nameFilters)
+	}
+
+	if s.filterRegexp != "" {
+		fileList = downloader.FilterFilesByRegex(fileList, s.filterRegexp)
+	}
+
+	if s.filterRange != "" {
+		ranges, err := utils.ParseRanges([]string{s.filterRange})
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid range filter: %v", err))
+			os.Exit(1)
+		}
+		fileList = downloader.FilterFilesByMultipleRanges(fileList, ranges)
+	}
+
+	if len(fileList) == 0 {
+		printer.DisplayMessage(printer.Error, "No files matching filters")
+		os.Exit(1)
+	}
+
+	return fileList, totalFiles, totalBytes, tapeFilesSkipped
+}
+
+// recordFiles lists all files of the record, with file indexes both
+// expanded and not, so that the record's files in a download directory can
+// be told apart from extra files whatever selection downloaded them.
+func recordFiles(client *searcher.Client, record *searcher.RecordResponse, protocol string) ([]any, error) {
+	var fileList []any
+	for _, expand := range []bool{false, true} {
+		files, err := client.GetFilesList(record, protocol, expand)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			fileList = append(fileList, map[string]any{"uri": file.URI})
+		}
+	}
+	return fileList, nil
+}
+
+// runDownload downloads the files with the selected download engine.
+func runDownload(cmd *cobra.Command, downloadEngine string, fileList []any, outputDir string, retryLimit, retrySleep int, verbose, dryRun bool) downloader.DownloadStats {
+	// Enable progress when --progress or --verbose flags are set
+	showProgress := verbose
+	if progressFlag, _ := cmd.Flags().GetBool("progress"); progressFlag {
+		showProgress = true
+	}
+
+	if downloadEngine == "xrootd" {
+		xrdDownloader := xrootddownloader.NewDownloader()
+		defer func() {
+			_ = xrdDownloader.Close()
+		}()
+		xrdStats := xrdDownloader.DownloadFiles(cmd.Context(), fileList, outputDir, retryLimit, retrySleep, verbose, dryRun, showProgress)
+		return downloader.DownloadStats{
+			TotalFiles:      xrdStats.TotalFiles,
+			TotalBytes:      xrdStats.TotalBytes,
+			DownloadedFiles: xrdStats.DownloadedFiles,
+			DownloadedBytes: xrdStats.DownloadedBytes,
+			FailedFiles:     xrdStats.FailedFiles,
+			SkippedFiles:    xrdStats.SkippedFiles,
+		}
+	}
+
+	httpDownloader := downloader.NewDownloader()
+	return httpDownloader.DownloadFiles(fileList, outputDir, retryLimit, retrySleep, verbose, dryRun, showProgress)
+}
+
+// relatedDownload holds the files downloaded for a related record.
+type relatedDownload struct {
+	recid int
+	dir   string
+	files []any
+	stats downloader.DownloadStats
+}
+
+// downloadRelated downloads the online files of the records directly
+// referenced by the record whose type matches one of types. Each record
+// goes into a subdirectory of outputDir named after its record ID.
+func downloadRelated(cmd *cobra.Command, client *searcher.Client, recid int, types []string, protocol, downloadEngine, outputDir string, retryLimit, retrySleep int, verbose, dryRun bool) []relatedDownload {
+	graph, err := relater.Traverse(recid, recordFetcher(client), relater.Options{Depth: 1, Server: client.Server()})
+	if err != nil {
+		printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get related records: %v", err))
+		os.Exit(1)
+	}
+
+	var downloads []relatedDownload
+	for _, node := range graph.Related() {
+		if node.Error != "" {
+			printer.DisplayMessage(printer.Warning, fmt.Sprintf("Skipping related record %d: %s", node.Recid, node.Error))
+			continue
+		}
+		matched := false
+		for _, t := range types {
+			if t == "all" || node.HasType(t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		record := &searcher.RecordResponse{ID: strconv.Itoa(node.Recid), Metadata: node.Metadata}
+		files, err := client.GetFilesList(record, protocol, true)
+		if err != nil {
+			printer.DisplayMessage(printer.Warning, fmt.Sprintf("Skipping related record %d: %v", node.Recid, err))
+			continue
+		}
+		files, _ = searcher.FilterFilesByAvailability(files, "online")
+		if len(files) == 0 {
+			continue
+		}
+
+		var fileList []any
+		for _, file := range files {
+			fileList = append(fileList, map[string]any{
+				"uri":      file.URI,
+				"size":     float64(file.Size),
+				"checksum": file.Checksum,
+			})
+		}
+
+		dir := filepath.Join(outputDir, strconv.Itoa(node.Recid))
+		printer.DisplayMessage(printer.Info, fmt.Sprintf("Downloading related record %d: %s", node.Recid, node.Title))
+		stats := runDownload(cmd, downloadEngine, fileList, dir, retryLimit, retrySleep, verbose, dryRun)
+		downloads = append(downloads, relatedDownload{recid: node.Recid, dir: dir, files: fileList, stats: stats})
+	}
+	return downloads
 }
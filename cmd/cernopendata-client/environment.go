@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/provisioner"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+var environmentCmd = &cobra.Command{
+	Use:   "environment",
+	Short: "Show the software environment of a record",
+	Long: `Show the software environment of a record.
+
+Select a CERN Open Data record by a record ID, a DOI, or a title and
+summarize the software environment described in its system details: the
+release, global tag, recommended container image, setup instructions and
+usage links. The environment can also be written as a Dockerfile, an
+Apptainer definition file or a shell setup script, which is meant to be
+sourced in bash.
+
+Examples:
+
+     $ cernopendata-client environment --recid 5500
+
+     $ cernopendata-client environment --recid 5500 --format dockerfile > Dockerfile
+
+     $ cernopendata-client environment --recid 5500 --format apptainer > record.def
+
+     $ cernopendata-client environment --recid 5500 --format shell > setup.sh
+
+     $ cernopendata-client environment --recid 5500 --registry gitlab --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		input, _ := cmd.Flags().GetString("input")
+		registry, _ := cmd.Flags().GetString("registry")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		switch outputFormat {
+		case "text", "json", provisioner.FormatDockerfile, provisioner.FormatApptainer, provisioner.FormatShell:
+		default:
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json', 'dockerfile', 'apptainer', 'shell')", outputFormat))
+			os.Exit(1)
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		var record *searcher.RecordResponse
+		if input != "" {
+			record, err = searcher.LoadRecordFile(input)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load record: %v", err))
+				os.Exit(1)
+			}
+		} else {
+			parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+			if !ok {
+				return
+			}
+
+			client := searcher.NewClient(server)
+			record, err = client.GetRecord(parsedRecid)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		env, err := provisioner.FromMetadata(record.Metadata)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get environment: %v", err))
+			os.Exit(1)
+		}
+
+		if err := env.SelectImage(registry); err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to select container image: %v", err))
+			os.Exit(1)
+		}
+
+		if outputFormat == "json" {
+			jsonBytes, err := json.MarshalIndent(env, "", "  ")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+				os.Exit(1)
+			}
+			printer.DisplayOutput(string(jsonBytes))
+			return
+		}
+
+		output, err := env.Format(outputFormat)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format environment: %v", err))
+			os.Exit(1)
+		}
+		printer.DisplayOutput(output)
+	},
+}
+
+func init() {
+	environmentCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
+	environmentCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
+	environmentCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
+	environmentCmd.Flags().StringP("input", "i", "", "Metadata file saved with get-metadata --format json")
+	environmentCmd.Flags().String("registry", "", "Container registry to take the image from, e.g. dockerhub or gitlab")
+	environmentCmd.Flags().StringP("format", "m", "text", "Output format (text|json|dockerfile|apptainer|shell)")
+	environmentCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(environmentCmd)
+}
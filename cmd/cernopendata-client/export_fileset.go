@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/downloader"
+	"github.com/clelange/cernopendata-client-go/internal/exporter"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+var exportFilesetCmd = &cobra.Command{
+	Use:   "export-fileset",
+	Short: "Export file lists for analysis frameworks",
+	Long: `Export file lists for analysis frameworks.
+
+Select one or more CERN Open Data records by record ID, DOI, title or
+a search query and write the locations of their files in a shape that
+analysis frameworks read directly:
+
+     cmssw   CMSSW PoolSource fileNames python fragment
+     txt     plain list of file URLs
+     coffea  coffea fileset JSON ({dataset: {files: {url: treename}}})
+     tchain  ROOT macro building a TChain
+
+With --chunks, the files are split into that many chunks of roughly equal
+total size, written to numbered files derived from --output.
+
+Examples:
+
+     $ cernopendata-client export-fileset --recid 6004 --format cmssw
+
+     $ cernopendata-client export-fileset --recid 6004 --recid 6021 --format coffea --output fileset.json
+
+     $ cernopendata-client export-fileset --recid 6004 --index '*_10000_*' --format tchain --output chain.C
+
+     $ cernopendata-client export-fileset --query-pattern "Run2012B" --query-facet experiment=CMS --format txt
+
+     $ cernopendata-client export-fileset --recid 6004 --format txt --chunks 10 --output jobs/files.txt`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recids, _ := cmd.Flags().GetIntSlice("recid")
+		dois, _ := cmd.Flags().GetStringArray("doi")
+		titles, _ := cmd.Flags().GetStringArray("title")
+		queryPattern, _ := cmd.Flags().GetString("query-pattern")
+		queryFacets, _ := cmd.Flags().GetStringArray("query-facet")
+		indexPatterns, _ := cmd.Flags().GetStringArray("index")
+		protocol, _ := cmd.Flags().GetString("protocol")
+		filterName, _ := cmd.Flags().GetString("filter-name")
+		filterRegexp, _ := cmd.Flags().GetString("filter-regexp")
+		fileAvailability, _ := cmd.Flags().GetString("file-availability")
+		format, _ := cmd.Flags().GetString("format")
+		treeName, _ := cmd.Flags().GetString("tree-name")
+		chunks, _ := cmd.Flags().GetInt("chunks")
+		output, _ := cmd.Flags().GetString("output")
+		server, _ := cmd.Flags().GetString("server")
+
+		if _, ok := exporter.Extensions[format]; !ok {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'cmssw', 'txt', 'coffea', 'tchain')", format))
+			os.Exit(1)
+		}
+
+		if protocol != "xrootd" && protocol != "https" && protocol != "http" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid protocol: %s (choose from 'xrootd', 'https', 'http')", protocol))
+			os.Exit(1)
+		}
+
+		if fileAvailability != "" && fileAvailability != "online" && fileAvailability != "all" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid file availability: %s (choose from 'online', 'all')", fileAvailability))
+			os.Exit(1)
+		}
+
+		if chunks < 1 {
+			printer.DisplayMessage(printer.Error, "--chunks must be at least 1")
+			os.Exit(1)
+		}
+
+		if chunks > 1 && output == "" {
+			printer.DisplayMessage(printer.Error, "--chunks requires --output")
+			os.Exit(1)
+		}
+
+		if len(recids) == 0 && len(dois) == 0 && len(titles) == 0 && queryPattern == "" && len(queryFacets) == 0 {
+			printer.DisplayMessage(printer.Error, "Please provide recid, doi, title or a search query")
+			os.Exit(1)
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		client := searcher.NewClient(server)
+
+		for _, doi := range dois {
+			parsedRecid, err := searcher.GetRecid(server, doi, "", 0)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+				os.Exit(1)
+			}
+			recids = append(recids, parsedRecid)
+		}
+
+		for _, title := range titles {
+			parsedRecid, err := searcher.GetRecid(server, "", title, 0)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+				os.Exit(1)
+			}
+			recids = append(recids, parsedRecid)
+		}
+
+		var records []*searcher.RecordResponse
+		for _, recid := range recids {
+			record, err := client.GetRecord(recid)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+				os.Exit(1)
+			}
+			records = append(records, record)
+		}
+
+		if queryPattern != "" || len(queryFacets) > 0 {
+			facetsMap := make(map[string]string)
+			for _, qf := range queryFacets {
+				parts := strings.SplitN(qf, "=", 2)
+				if len(parts) != 2 {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid facet format: %s (expected key=value)", qf))
+					os.Exit(1)
+				}
+				facetsMap[parts[0]] = parts[1]
+			}
+
+			found, err := searchRecords(client, queryPattern, facetsMap)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Search failed: %v", err))
+				os.Exit(1)
+			}
+			records = append(records, found...)
+		}
+
+		var nameFilters []string
+		if filterName != "" {
+			for _, name := range strings.Split(filterName, ",") {
+				nameFilters = append(nameFilters, strings.TrimSpace(name))
+			}
+		}
+
+		var datasets []exporter.Dataset
+		seen := make(map[string]bool)
+		for _, record := range records {
+			if seen[record.ID] {
+				continue
+			}
+			seen[record.ID] = true
+			recid, _ := strconv.Atoi(record.ID)
+
+			var files []searcher.FileInfo
+			var err error
+			if len(indexPatterns) > 0 {
+				files, err = client.GetIndexFilesList(record, protocol, indexPatterns)
+			} else {
+				files, err = client.GetFilesList(record, protocol, true)
+			}
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to list files of record %d: %v", recid, err))
+				os.Exit(1)
+			}
+
+			if fileAvailability != "all" {
+				online, hasOfflineFiles := searcher.FilterFilesByAvailability(files, "online")
+				if hasOfflineFiles && fileAvailability == "" {
+					printer.DisplayMessage(printer.Warning, fmt.Sprintf("Record %d has files stored on tape; they are left out. Use '--file-availability all' to include them.", recid))
+				}
+				files = online
+			}
+
+			files = filterFilesByNames(files, nameFilters)
+			files, err = exporter.FilterFiles(files, nil, filterRegexp)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid filter: %v", err))
+				os.Exit(1)
+			}
+
+			if len(files) == 0 {
+				printer.DisplayMessage(printer.Warning, fmt.Sprintf("Record %d has no matching files.", recid))
+				continue
+			}
+			datasets = append(datasets, exporter.Dataset{
+				Name:  exporter.DatasetName(record.Metadata, recid),
+				Recid: recid,
+				Files: files,
+			})
+		}
+
+		if len(datasets) == 0 {
+			printer.DisplayMessage(printer.Error, "No files matching filters")
+			os.Exit(1)
+		}
+
+		parts := exporter.Split(datasets, chunks)
+		for i, part := range parts {
+			content, err := exporter.Format(part, format, treeName)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format file list: %v", err))
+				os.Exit(1)
+			}
+
+			if output == "" {
+				printer.DisplayOutput(content)
+				continue
+			}
+
+			destPath := exporter.ChunkPath(output, i, len(parts))
+			if dir := filepath.Dir(destPath); dir != "." {
+				if err := os.MkdirAll(dir, 0750); err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to create directory %s: %v", dir, err))
+					os.Exit(1)
+				}
+			}
+			if err := os.WriteFile(destPath, []byte(content+"\n"), 0600); err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to write %s: %v", destPath, err))
+				os.Exit(1)
+			}
+
+			var numFiles int
+			for _, dataset := range part {
+				numFiles += len(dataset.Files)
+			}
+			printer.DisplayMessage(printer.Note, fmt.Sprintf("Wrote %s (%d files, %s)", destPath, numFiles, utils.FormatBytes(float64(exporter.TotalSize(part)))))
+		}
+	},
+}
+
+// searchRecords returns the records matching a search query. Search hits
+// carry no file lists, so every record is fetched in full.
+func searchRecords(client *searcher.Client, q string, facets map[string]string) ([]*searcher.RecordResponse, error) {
+	searchResp, err := client.SearchAllRecords(q, facets, "")
+	if err != nil {
+		return nil, err
+	}
+	var records []*searcher.RecordResponse
+	for _, hit := range searchResp.Hits.Hits {
+		recid, err := strconv.Atoi(hit.ID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record ID %q", hit.ID)
+		}
+		record, err := client.GetRecord(recid)
+		if err != nil {
+			return nil, err
+		}
+		if record.ID == "" {
+			record.ID = hit.ID
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// filterFilesByNames keeps the files selected by the names, the way
+// download-files applies --filter-name.
+func filterFilesByNames(files []searcher.FileInfo, names []string) []searcher.FileInfo {
+	if len(names) == 0 {
+		return files
+	}
+	fileList := make([]any, len(files))
+	byURI := make(map[string]searcher.FileInfo, len(files))
+	for i, file := range files {
+		fileList[i] = map[string]any{"uri": file.URI}
+		byURI[file.URI] = file
+	}
+	var result []searcher.FileInfo
+	for _, file := range downloader.FilterFilesByMultipleNames(fileList, names) {
+		uri, _ := file.(map[string]any)["uri"].(string)
+		result = append(result, byURI[uri])
+	}
+	return result
+}
+
+func init() {
+	exportFilesetCmd.Flags().IntSliceP("recid", "r", nil, "Record ID (can be repeated)")
+	exportFilesetCmd.Flags().StringArrayP("doi", "d", nil, "Digital Object Identifier (can be repeated)")
+	exportFilesetCmd.Flags().StringArrayP("title", "t", nil, "Record title (exact match, can be repeated)")
+	exportFilesetCmd.Flags().String("query-pattern", "", "Export the files of all records matching a free text search pattern")
+	exportFilesetCmd.Flags().StringArrayP("query-facet", "f", []string{}, "Facet filter in key=value format (can be repeated)")
+	exportFilesetCmd.Flags().StringArray("index", nil, "Only export files listed in matching file indexes (can be repeated)")
+	exportFilesetCmd.Flags().StringP("protocol", "p", "xrootd", "Protocol to be used in links [xrootd,https,http]")
+	exportFilesetCmd.Flags().StringP("filter-name", "n", "", "Export files matching exactly the file name")
+	exportFilesetCmd.Flags().StringP("filter-regexp", "e", "", "Export files matching the regular expression")
+	exportFilesetCmd.Flags().String("file-availability", "", "Filter files by their availability status [online, all]")
+	exportFilesetCmd.Flags().StringP("format", "m", "txt", "Output format (cmssw|txt|coffea|tchain)")
+	exportFilesetCmd.Flags().String("tree-name", "Events", "Tree name for coffea and tchain output")
+	exportFilesetCmd.Flags().Int("chunks", 1, "Split the files into this many chunks of similar total size")
+	exportFilesetCmd.Flags().StringP("output", "o", "", "Write to this file instead of standard output")
+	exportFilesetCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+}
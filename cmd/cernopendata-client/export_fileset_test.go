@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+func TestSearchRecords(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/records/":
+			// Search results come without files.
+			if r.URL.Query().Get("skip_files") != "1" {
+				t.Errorf("search without skip_files: %s", r.URL)
+			}
+			_, _ = w.Write([]byte(`{"hits": {"total": 1, "hits": [{"id": "5500", "metadata": {"recid": 5500, "title": "Dataset"}}]}}`))
+		case "/api/records/5500":
+			_, _ = w.Write([]byte(`{"id": "5500", "metadata": {"recid": 5500, "title": "Dataset", "files": [
+				{"uri": "root://eospublic.cern.ch//eos/opendata/a.root", "size": 10, "checksum": "adler32:00000001"}
+			]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	client := searcher.NewClient(server.URL)
+	records, err := searchRecords(client, "Dataset", nil)
+	if err != nil {
+		t.Fatalf("searchRecords() error = %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "5500" {
+		t.Fatalf("searchRecords() = %+v", records)
+	}
+
+	files, err := client.GetFilesList(records[0], "xrootd", true)
+	if err != nil {
+		t.Fatalf("GetFilesList() error = %v", err)
+	}
+	if len(files) != 1 || files[0].URI != "root://eospublic.cern.ch//eos/opendata/a.root" {
+		t.Errorf("files of the found record = %+v", files)
+	}
+}
+
+func TestFilterFilesByNames(t *testing.T) {
+	files := []searcher.FileInfo{
+		{URI: "root://eospublic.cern.ch//eos/opendata/a.root", Size: 10},
+		{URI: "root://eospublic.cern.ch//eos/opendata/b.root", Size: 20},
+		{URI: "root://eospublic.cern.ch//eos/opendata/readme.txt", Size: 30},
+	}
+
+	got := filterFilesByNames(files, []string{"readme.txt", "b.root"})
+	if len(got) != 2 || got[0] != files[2] || got[1] != files[1] {
+		t.Errorf("filterFilesByNames(names) = %+v", got)
+	}
+	if got := filterFilesByNames(files, nil); len(got) != 3 {
+		t.Errorf("filterFilesByNames(nil) = %+v", got)
+	}
+	if got := filterFilesByNames(files, []string{"a"}); len(got) != 0 {
+		t.Errorf("filterFilesByNames() matched a partial name: %+v", got)
+	}
+}
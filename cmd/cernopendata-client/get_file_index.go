@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+var getFileIndexCmd = &cobra.Command{
+	Use:   "get-file-index",
+	Short: "List and retrieve the file indexes of a record",
+	Long: `List and retrieve the file indexes of a record.
+
+Without --index, list the file indexes of a record together with the
+number and total size of the files they contain. With --index, download
+the selected index files from the portal and print the files they list,
+or save the raw index files with --output-dir.
+
+Index keys may be given in full, without their .json/.txt extension, or
+as a glob pattern.
+
+Examples:
+
+     $ cernopendata-client get-file-index --recid 6004
+
+     $ cernopendata-client get-file-index --recid 6004 --index 'CMS_Run2012B_DoubleMuParked_AOD_22Jan2013-v1_10000_file_index'
+
+     $ cernopendata-client get-file-index --recid 6004 --index '*_10000_file_index.txt' --raw
+
+     $ cernopendata-client get-file-index --recid 6004 --index '*' --output-dir indexes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		indexPatterns, _ := cmd.Flags().GetStringArray("index")
+		protocol, _ := cmd.Flags().GetString("protocol")
+		raw, _ := cmd.Flags().GetBool("raw")
+		outputDir, _ := cmd.Flags().GetString("output-dir")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		if outputFormat != "text" && outputFormat != "json" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json')", outputFormat))
+			os.Exit(1)
+		}
+
+		if (raw || outputDir != "") && len(indexPatterns) == 0 {
+			printer.DisplayMessage(printer.Error, "--raw and --output-dir can only be used with --index")
+			os.Exit(1)
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+		if !ok {
+			return
+		}
+
+		client := searcher.NewClient(server)
+		record, err := client.GetRecord(parsedRecid)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+			os.Exit(1)
+		}
+
+		indices, err := client.GetFileIndices(record, protocol)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get file indexes: %v", err))
+			os.Exit(1)
+		}
+
+		if len(indices) == 0 {
+			printer.DisplayMessage(printer.Info, fmt.Sprintf("Record %d has no file indexes.", parsedRecid))
+			return
+		}
+
+		if len(indexPatterns) == 0 {
+			if outputFormat == "json" {
+				jsonBytes, err := json.MarshalIndent(indices, "", "  ")
+				if err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+					os.Exit(1)
+				}
+				printer.DisplayOutput(string(jsonBytes))
+				return
+			}
+
+			var totalFiles int
+			var totalSize int64
+			for _, index := range indices {
+				printer.DisplayOutput(fmt.Sprintf("%s\t%d files\t%s", index.Key, index.NumberFiles, utils.FormatBytes(float64(index.FilesSize))))
+				totalFiles += index.NumberFiles
+				totalSize += index.FilesSize
+			}
+			printer.DisplayMessage(printer.Info, fmt.Sprintf("\nTotal: %d indexes, %d files, %s", len(indices), totalFiles, utils.FormatBytes(float64(totalSize))))
+			return
+		}
+
+		selected, err := searcher.SelectFileIndices(indices, indexPatterns)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to select file indexes: %v", err))
+			os.Exit(1)
+		}
+
+		if raw || outputDir != "" {
+			if outputDir != "" {
+				if err := os.MkdirAll(outputDir, 0750); err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to create directory %s: %v", outputDir, err))
+					os.Exit(1)
+				}
+			}
+			for _, index := range selected {
+				data, err := client.FetchFileIndex(index)
+				if err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get file index: %v", err))
+					os.Exit(1)
+				}
+				if outputDir != "" {
+					destPath := filepath.Join(outputDir, filepath.Base(index.Key))
+					if err := os.WriteFile(destPath, data, 0600); err != nil {
+						printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to write %s: %v", destPath, err))
+						os.Exit(1)
+					}
+					printer.DisplayMessage(printer.Note, fmt.Sprintf("Saved %s", destPath))
+				} else {
+					printer.DisplayOutput(string(data))
+				}
+			}
+			return
+		}
+
+		var files []searcher.FileInfo
+		for _, index := range selected {
+			indexFiles, err := client.GetFileIndexFiles(index, protocol)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get file index: %v", err))
+				os.Exit(1)
+			}
+			files = append(files, indexFiles...)
+		}
+
+		if outputFormat == "json" {
+			jsonBytes, err := json.MarshalIndent(files, "", "  ")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+				os.Exit(1)
+			}
+			printer.DisplayOutput(string(jsonBytes))
+			return
+		}
+
+		for _, file := range files {
+			if verbose {
+				printer.DisplayOutput(fmt.Sprintf("%s\t%d\t%s", file.URI, file.Size, file.Checksum))
+			} else {
+				printer.DisplayOutput(file.URI)
+			}
+		}
+	},
+}
+
+func init() {
+	getFileIndexCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
+	getFileIndexCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
+	getFileIndexCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
+	getFileIndexCmd.Flags().StringArray("index", nil, "File index key, key without extension, or glob (can be repeated)")
+	getFileIndexCmd.Flags().StringP("protocol", "p", "http", "Protocol to be used in links [http,https,xrootd]")
+	getFileIndexCmd.Flags().Bool("raw", false, "Print the raw index file content")
+	getFileIndexCmd.Flags().StringP("output-dir", "O", "", "Save the raw index files to this directory")
+	getFileIndexCmd.Flags().BoolP("verbose", "V", false, "Output also the file size (2nd) and checksum (3rd)")
+	getFileIndexCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	getFileIndexCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(getFileIndexCmd)
+}
@@ -70,10 +70,9 @@ Examples:
 			server = config.ServerHTTPURI
 		}
 
-		parsedRecid, err := searcher.GetRecid(server, doi, title, recid)
-		if err != nil {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
-			os.Exit(1)
+		parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+		if !ok {
+			return
 		}
 
 		client := searcher.NewClient(server)
@@ -146,4 +145,5 @@ func init() {
 	getFileLocationsCmd.Flags().StringP("file-availability", "", "", "Filter files by their availability status [online, all]")
 	getFileLocationsCmd.Flags().StringP("server", "S", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
 	getFileLocationsCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	addResolveFlags(getFileLocationsCmd)
 }
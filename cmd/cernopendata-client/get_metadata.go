@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"text/template"
 
 	"github.com/spf13/cobra"
 
@@ -20,13 +21,46 @@ var getMetadataCmd = &cobra.Command{
 Select a CERN Open Data bibliographic record by a record ID, a
 DOI, or a title and return its metadata in the JSON format.
 
+The --output-value option accepts a dot path such as usage.links.url or a
+query expression supporting indexing (files[0]), slicing (files[1:3]),
+wildcards (files[*]), recursive descent (..checksum), filters
+(files[?size>1e9].uri), pipes and functions (files | length), and object
+construction ({title, doi, "n": (files | length)}).
+
+The --filter option selects array items with conditions of the form
+field<op>value, where op is one of =, !=, ~= (regex), >, <, >= or <=, or
+"field in a,b,c" and "field exists". Numbers and sizes such as 2GB are
+compared numerically. Conditions can be combined with && and ||, and
+repeated --filter options must all match. Items lacking a filtered field
+match unless --strict-filter is given. Values starting with an operator
+character must be quoted. A single matching item is printed on its own,
+several matching items as a list.
+
+The output is printed as a tree (pretty), JSON, YAML or TOML. Alternatively,
+--template formats it with a Go text/template; besides the built-in
+functions, formatBytes, join, default, json and query are available.
+
 Examples:
 
      $ cernopendata-client get-metadata --recid 1
 
      $ cernopendata-client get-metadata --recid 1 --output-value title
 
-     $ cernopendata-client get-metadata --recid 329 --output-value authors.orcid --filter name="Rousseau, David"`,
+     $ cernopendata-client get-metadata --recid 329 --output-value authors.orcid --filter name="Rousseau, David"
+
+     $ cernopendata-client get-metadata --recid 5500 --output-value files --filter 'size>1GB' --filter 'key~=\.root$'
+
+     $ cernopendata-client get-metadata --recid 5500 --output-value files --filter 'checksum exists' --strict-filter
+
+     $ cernopendata-client get-metadata --recid 5500 --output-value 'files[?size>1e9].uri'
+
+     $ cernopendata-client get-metadata --recid 5500 --output-value '{title, doi, "n": (files | length)}' --format json
+
+     $ cernopendata-client get-metadata --recid 5500 --format yaml
+
+     $ cernopendata-client get-metadata --recid 5500 --template '{{.title}} ({{join ", " .keywords | default "no keywords"}})'
+
+     $ cernopendata-client get-metadata --recid 5500 --output-value files --template '{{range .}}{{.key}} {{formatBytes .size}}{{"\n"}}{{end}}'`,
 	Run: func(cmd *cobra.Command, args []string) {
 		recid, err := cmd.Flags().GetInt("recid")
 		if err != nil {
@@ -36,25 +70,40 @@ Examples:
 		doi, _ := cmd.Flags().GetString("doi")
 		title, _ := cmd.Flags().GetString("title")
 		outputValue, _ := cmd.Flags().GetString("output-value")
-		filterStr, _ := cmd.Flags().GetString("filter")
+		filters, _ := cmd.Flags().GetStringArray("filter")
+		strictFilter, _ := cmd.Flags().GetBool("strict-filter")
 		outputFormat, _ := cmd.Flags().GetString("format")
+		templateText, _ := cmd.Flags().GetString("template")
 		server, _ := cmd.Flags().GetString("server")
 
 		if server == "" {
 			server = config.ServerHTTPURI
 		}
 
-		if filterStr != "" && outputValue == "" {
-			printer.DisplayMessage(printer.Error, "--filter can only be used with --output-value")
+		if outputFormat != "pretty" && outputFormat != "json" && outputFormat != "yaml" && outputFormat != "toml" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'pretty', 'json', 'yaml', 'toml')", outputFormat))
 			os.Exit(1)
 		}
 
-		parsedRecid, err := searcher.GetRecid(server, doi, title, recid)
-		if err != nil {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+		var tmpl *template.Template
+		if templateText != "" {
+			tmpl, err = metadater.ParseTemplate(templateText)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid template: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		if len(filters) > 0 && outputValue == "" {
+			printer.DisplayMessage(printer.Error, "--filter can only be used with --output-value")
 			os.Exit(1)
 		}
 
+		parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+		if !ok {
+			return
+		}
+
 		client := searcher.NewClient(server)
 		record, err := client.GetRecord(parsedRecid)
 		if err != nil {
@@ -62,13 +111,8 @@ Examples:
 			os.Exit(1)
 		}
 
-		var filters []string
-		if filterStr != "" {
-			filters = []string{filterStr}
-		}
-
 		if outputValue == "" {
-			output, err := metadater.FormatOutput(record.Metadata, outputFormat)
+			output, err := metadater.Render(record.Metadata, outputFormat, tmpl)
 			if err != nil {
 				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format output: %v", err))
 				os.Exit(1)
@@ -86,13 +130,18 @@ Examples:
 				if !isArray {
 					items = []any{metadata}
 				}
-				filtered, err := metadater.FilterArray(items, filters)
+				filtered, err := metadater.FilterItems(items, filters, strictFilter)
 				if err != nil {
 					printer.DisplayMessage(printer.Error, fmt.Sprintf("Filter error: %v", err))
 					os.Exit(1)
 				}
 				if len(filtered) > 0 {
-					output, err := metadater.FormatOutput(filtered[0], outputFormat)
+					// A single match is shown on its own, several as a list
+					var value any = filtered
+					if len(filtered) == 1 {
+						value = filtered[0]
+					}
+					output, err := metadater.Render(value, outputFormat, tmpl)
 					if err != nil {
 						printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format output: %v", err))
 						os.Exit(1)
@@ -100,7 +149,7 @@ Examples:
 					printer.DisplayOutput(output)
 				}
 			} else {
-				output, err := metadater.FormatOutput(metadata, outputFormat)
+				output, err := metadater.Render(metadata, outputFormat, tmpl)
 				if err != nil {
 					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format output: %v", err))
 					os.Exit(1)
@@ -115,8 +164,11 @@ func init() {
 	getMetadataCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
 	getMetadataCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
 	getMetadataCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
-	getMetadataCmd.Flags().StringP("output-value", "v", "", "Output value of only desired metadata field [example=title]")
-	getMetadataCmd.Flags().StringP("filter", "f", "", "Filter only certain output values matching filtering criteria. [Use --filter some_field_name=some_value]")
-	getMetadataCmd.Flags().StringP("format", "m", "pretty", "Output format (pretty|json)")
+	getMetadataCmd.Flags().StringP("output-value", "v", "", "Output value of only desired metadata field or query expression [example=title]")
+	getMetadataCmd.Flags().StringArrayP("filter", "f", nil, "Filter only certain output values matching filtering criteria (can be repeated). [Use --filter some_field_name=some_value]")
+	getMetadataCmd.Flags().Bool("strict-filter", false, "Exclude output values lacking a filtered field")
+	getMetadataCmd.Flags().StringP("format", "m", "pretty", "Output format (pretty|json|yaml|toml)")
+	getMetadataCmd.Flags().String("template", "", "Format the output with a Go template, e.g. '{{.title}}'")
 	getMetadataCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(getMetadataCmd)
 }
@@ -902,6 +902,22 @@ func TestIntegrationVerifyFilesByNameFilter(t *testing.T) {
 	}
 }
 
+func TestIntegrationVerifyFilesExpandConflict(t *testing.T) {
+	output := assertCommandError(t, "verify-files", "--recid", testRecID, "--expand", "--no-expand")
+	if !contains(output, "Cannot specify both --expand and --no-expand") {
+		t.Errorf("Expected --expand/--no-expand conflict message, got: %s", output)
+	}
+}
+
+func TestIntegrationVerifyFilesFilterRange(t *testing.T) {
+	tmpDir := t.TempDir()
+	assertCommandSuccess(t, "download-files", "--recid", testRecID, "--filter-range", "1-2", "--output-dir", tmpDir)
+	output := assertCommandSuccess(t, "verify-files", "--recid", testRecID, "--filter-range", "1-2", "--input-dir", tmpDir)
+	if !contains(output, "Expected 2, found 2") {
+		t.Errorf("Expected two files to be verified, got: %s", output)
+	}
+}
+
 func TestIntegrationVerifyFilesNoIdentifier(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -1795,3 +1811,24 @@ func TestIntegrationListDirectoryJSON(t *testing.T) {
 
 	t.Logf("Successfully got %d entries in JSON format", len(entries))
 }
+
+func TestIntegrationBrowseProtocolEngineMismatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	for _, args := range [][]string{
+		{"--protocol", "xrootd"},
+		{"--protocol", "http", "--download-engine", "xrootd"},
+	} {
+		// #nosec G204
+		cmd := exec.Command(getBinaryPath(), append([]string{"browse", "--script", os.DevNull}, args...)...)
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Errorf("Expected browse %v to fail", args)
+		}
+		if !strings.Contains(string(output), "cannot be used with the") {
+			t.Errorf("Expected an error about the download engine for %v, got: %s", args, string(output))
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/clelange/cernopendata-client-go/internal/config"
 	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
 	"github.com/clelange/cernopendata-client-go/internal/version"
 )
 
@@ -29,7 +31,14 @@ func main() {
 			}
 			return fmt.Errorf("unknown command: %s", args[0])
 		},
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			rateLimit, _ := cmd.Flags().GetFloat64("api-rate-limit")
+			concurrency, _ := cmd.Flags().GetInt("api-concurrency")
+			searcher.SetRateLimit(rateLimit, concurrency)
+		},
 	}
+	rootCmd.PersistentFlags().Float64("api-rate-limit", config.APIRequestsPerSecond, "Maximum number of portal API requests per second (0 for no limit)")
+	rootCmd.PersistentFlags().Int("api-concurrency", config.APIMaxConcurrentRequests, "Maximum number of concurrent portal API requests (0 for no limit)")
 
 	var completionCmd = &cobra.Command{
 		Use:   "completion",
@@ -59,8 +68,21 @@ func main() {
 	rootCmd.AddCommand(getFileLocationsCmd)
 	rootCmd.AddCommand(downloadFilesCmd)
 	rootCmd.AddCommand(verifyFilesCmd)
+	rootCmd.AddCommand(remoteChecksumCmd)
+	rootCmd.AddCommand(manifestCmd)
 	rootCmd.AddCommand(listDirectoryCmd)
 	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(citeCmd)
+	rootCmd.AddCommand(resolveCmd)
+	rootCmd.AddCommand(diffRecordCmd)
+	rootCmd.AddCommand(getFileIndexCmd)
+	rootCmd.AddCommand(exportFilesetCmd)
+	rootCmd.AddCommand(environmentCmd)
+	rootCmd.AddCommand(relatedCmd)
+	rootCmd.AddCommand(runsCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(validateMetadataCmd)
+	rootCmd.AddCommand(browseCmd)
 	rootCmd.AddCommand(completionCmd)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/manifester"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/reporter"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+	"github.com/clelange/cernopendata-client-go/internal/verifier"
+)
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Create and verify file manifests",
+	Long: `Create and verify file manifests.
+
+A manifest lists files with their sizes and checksums, so that data
+copied to other sites can be verified without network access. Manifests
+created from record metadata and from local files have the same format.
+
+Examples:
+
+     $ cernopendata-client manifest create 5500 --output 5500.json
+
+     $ cernopendata-client manifest verify 5500.json --input-dir 5500`,
+}
+
+var manifestCreateCmd = &cobra.Command{
+	Use:   "create [directory]",
+	Short: "Create a manifest of a local directory or of a record's files",
+	Long: `Create a manifest of a local directory or of a record's files.
+
+Given a directory, all files below it are hashed and listed with their
+paths relative to the directory. Checksum cache sidecar files and the
+manifest itself are left out. Checksums already in the checksum cache are
+not computed again unless --rehash is given.
+
+Given a record by a record ID, a DOI, or a title instead, the manifest
+lists the record's files with the sizes and checksums from the record
+metadata, as download-files stores them. The files are selected with the
+same flags as download-files.
+
+The manifest is written as JSON, or with --format sha256sum as lines that
+sha256sum -c can check. The sha256sum format records no sizes and needs a
+sha256 checksum for every file, so it is only available for directories.
+
+Examples:
+
+     $ cernopendata-client manifest create data --output data/MANIFEST.json
+
+     $ cernopendata-client manifest create data --format sha256sum > SHA256SUMS
+
+     $ cernopendata-client manifest create data --algorithm md5
+
+     $ cernopendata-client manifest create --recid 5500 --output 5500.json`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		output, _ := cmd.Flags().GetString("output")
+		format, _ := cmd.Flags().GetString("format")
+		algorithms, _ := cmd.Flags().GetStringArray("algorithm")
+		rehash, _ := cmd.Flags().GetBool("rehash")
+		server, _ := cmd.Flags().GetString("server")
+
+		selection := parseFileSelection(cmd, "include")
+
+		if format != "json" && format != "sha256sum" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'json', 'sha256sum')", format))
+			os.Exit(1)
+		}
+
+		fromRecord := recid != 0 || doi != "" || title != ""
+		if fromRecord == (len(args) == 1) {
+			printer.DisplayMessage(printer.Error, "Specify either a directory or a record (--recid, --doi or --title)")
+			os.Exit(1)
+		}
+
+		var manifest *manifester.Manifest
+		if fromRecord {
+			if server == "" {
+				server = config.ServerHTTPURI
+			}
+
+			parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+			if !ok {
+				return
+			}
+
+			client := searcher.NewClient(server)
+			record, err := client.GetRecord(parsedRecid)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+				os.Exit(1)
+			}
+
+			fileList, _, _, _ := selection.apply(client, record, parsedRecid, "http")
+			manifest = manifester.FromFiles(parsedRecid, fileList)
+		} else {
+			if format == "sha256sum" && !cmd.Flags().Changed("algorithm") {
+				algorithms = []string{"sha256"}
+			}
+
+			var cache *checksum.Cache
+			if !rehash {
+				cache = checksum.NewCache()
+			}
+
+			// Leave out the manifest if it is written into the directory.
+			outputPath := ""
+			if output != "" {
+				outputPath, _ = filepath.Abs(output)
+			}
+			skip := func(path string) bool {
+				abs, _ := filepath.Abs(path)
+				return abs == outputPath
+			}
+
+			manifest, err = manifester.FromDirectory(args[0], algorithms, cache, skip)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to create manifest: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := manifest.Write(&buf, format); err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to write manifest: %v", err))
+			os.Exit(1)
+		}
+
+		if output == "" {
+			_, _ = os.Stdout.Write(buf.Bytes())
+			return
+		}
+		if err := os.WriteFile(output, buf.Bytes(), 0600); err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to write manifest: %v", err))
+			os.Exit(1)
+		}
+		printer.DisplayMessage(printer.Info, fmt.Sprintf("Wrote manifest of %d files to %s", len(manifest.Files), output))
+	},
+}
+
+var manifestVerifyCmd = &cobra.Command{
+	Use:   "verify <manifest>",
+	Short: "Verify local files against a manifest",
+	Long: `Verify local files against a manifest.
+
+Check the sizes and checksums of the files listed in a manifest, without
+network access. The file paths are relative to the directory of the
+manifest unless --input-dir is given.
+
+With --format json, junit or tap, a report is written to standard output
+as by verify-files, which also documents the exit status.
+
+Besides manifests created with manifest create, lines in the formats of
+sha256sum and md5sum, including their --tag variants, are accepted. Such
+lines record no sizes, so the files are verified by checksum only.
+
+Examples:
+
+     $ cernopendata-client manifest verify data/MANIFEST.json
+
+     $ cernopendata-client manifest verify 5500.json --input-dir 5500
+
+     $ cernopendata-client manifest verify SHA256SUMS --jobs 8 --progress
+
+     $ cernopendata-client manifest verify 5500.json --input-dir 5500 --format tap`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inputDir, _ := cmd.Flags().GetString("input-dir")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		showProgress, _ := cmd.Flags().GetBool("progress")
+		rehash, _ := cmd.Flags().GetBool("rehash")
+		outputFormat, _ := cmd.Flags().GetString("format")
+
+		if outputFormat != "text" && !slices.Contains(reporter.Formats, outputFormat) {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', '%s')", outputFormat, strings.Join(reporter.Formats, "', '")))
+			os.Exit(1)
+		}
+
+		if jobs < 0 {
+			printer.DisplayMessage(printer.Error, "--jobs must not be negative")
+			os.Exit(1)
+		}
+		if jobs == 0 {
+			jobs = runtime.NumCPU()
+		}
+
+		manifest, err := manifester.ReadFile(args[0])
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to read manifest: %v", err))
+			os.Exit(1)
+		}
+
+		if inputDir == "" {
+			inputDir = filepath.Dir(args[0])
+		}
+
+		v := verifier.NewVerifier()
+		v.Jobs = jobs
+		v.ShowProgress = showProgress
+		v.Rehash = rehash
+		v.Quiet = outputFormat != "text"
+		stats, err := v.VerifyFiles(inputDir, manifest.FileList())
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Verification failed: %v", err))
+			os.Exit(1)
+		}
+
+		if outputFormat != "text" {
+			report := reporter.Report{Recid: manifest.Recid, Directory: inputDir, Stats: stats}
+			if err := reporter.Write(os.Stdout, outputFormat, report); err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to write report: %v", err))
+				os.Exit(1)
+			}
+			os.Exit(reporter.ExitCode(stats.Results))
+		}
+
+		printVerificationSummary(stats, 0)
+
+		if code := reporter.ExitCode(stats.Results); code != 0 {
+			os.Exit(code)
+		}
+
+		printer.DisplayMessage(printer.Info, "Success!")
+	},
+}
+
+func init() {
+	manifestCreateCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
+	manifestCreateCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
+	manifestCreateCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
+	manifestCreateCmd.Flags().StringP("filter-name", "n", "", "Include files matching exactly the file name")
+	manifestCreateCmd.Flags().StringP("filter-regexp", "e", "", "Include files matching the regular expression")
+	manifestCreateCmd.Flags().String("filter-range", "", "Include files from a specified list range (i-j)")
+	manifestCreateCmd.Flags().BoolP("expand", "x", true, "Expand file indexes?")
+	manifestCreateCmd.Flags().Bool("no-expand", false, "Don't expand file indexes")
+	manifestCreateCmd.Flags().StringP("file-availability", "", "", "Filter files by their availability status [online, all]")
+	manifestCreateCmd.Flags().StringArray("index", nil, "Include only files from the file index with this key, key without extension, or glob (can be repeated)")
+	manifestCreateCmd.Flags().StringP("output", "o", "", "Write the manifest to this file instead of standard output")
+	manifestCreateCmd.Flags().StringP("format", "m", "json", "Manifest format (json|sha256sum)")
+	manifestCreateCmd.Flags().StringArrayP("algorithm", "a", []string{"sha256", checksum.DefaultAlgorithm}, "Checksum algorithm for local files (adler32|crc32c|md5|sha256, can be repeated)")
+	manifestCreateCmd.Flags().Bool("rehash", false, "Compute all checksums again instead of using the checksum cache")
+	manifestCreateCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(manifestCreateCmd)
+
+	manifestVerifyCmd.Flags().StringP("input-dir", "i", "", "Directory the manifest paths are relative to (default: the manifest's directory)")
+	manifestVerifyCmd.Flags().IntP("jobs", "j", 1, "Number of files to hash in parallel (0 for one per CPU)")
+	manifestVerifyCmd.Flags().BoolP("progress", "P", false, "Show the bytes hashed and the estimated time left")
+	manifestVerifyCmd.Flags().StringP("format", "m", "text", "Output format (text|json|junit|tap)")
+	manifestVerifyCmd.Flags().Bool("rehash", false, "Compute all checksums again instead of using the checksum cache")
+
+	manifestCmd.AddCommand(manifestCreateCmd)
+	manifestCmd.AddCommand(manifestVerifyCmd)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/relater"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+var relatedCmd = &cobra.Command{
+	Use:   "related",
+	Short: "Show records related to a record",
+	Long: `Show records related to a record.
+
+Select a CERN Open Data record by a record ID, a DOI, or a title and follow
+the references in its metadata to other records: relations, and record
+links in usage, methodology and other fields. Datasets link this way to
+their configuration files, validated run lists and software. The records
+found are followed in turn up to the given depth, and the resulting graph
+is written as an indented list, as JSON, or in the Graphviz DOT language.
+
+Examples:
+
+     $ cernopendata-client related --recid 6004
+
+     $ cernopendata-client related --recid 6004 --depth 2 --format json
+
+     $ cernopendata-client related --recid 6004 --field relations --field usage
+
+     $ cernopendata-client related --recid 6004 --depth 3 --format dot | dot -Tsvg > related.svg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		depth, _ := cmd.Flags().GetInt("depth")
+		fields, _ := cmd.Flags().GetStringArray("field")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		switch outputFormat {
+		case "text", "json", "dot":
+		default:
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json', 'dot')", outputFormat))
+			os.Exit(1)
+		}
+
+		if depth < 0 {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid depth: %d (must not be negative)", depth))
+			os.Exit(1)
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+		if !ok {
+			return
+		}
+
+		graph, err := relater.Traverse(parsedRecid, recordFetcher(searcher.NewClient(server)), relater.Options{Depth: depth, Fields: fields, Server: server})
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+			os.Exit(1)
+		}
+
+		if outputFormat == "json" {
+			jsonBytes, err := json.MarshalIndent(graph, "", "  ")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+				os.Exit(1)
+			}
+			printer.DisplayOutput(string(jsonBytes))
+			return
+		}
+
+		output, err := graph.Format(outputFormat)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format graph: %v", err))
+			os.Exit(1)
+		}
+		printer.DisplayOutput(output)
+	},
+}
+
+// recordFetcher returns a relater.Fetcher getting record metadata with the
+// client.
+func recordFetcher(client *searcher.Client) relater.Fetcher {
+	return func(recid int) (map[string]any, error) {
+		record, err := client.GetRecord(recid)
+		if err != nil {
+			return nil, err
+		}
+		return record.Metadata, nil
+	}
+}
+
+func init() {
+	relatedCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
+	relatedCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
+	relatedCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
+	relatedCmd.Flags().Int("depth", 1, "Number of reference hops to follow from the record")
+	relatedCmd.Flags().StringArray("field", nil, "Only follow references found in this top-level metadata field (can be repeated)")
+	relatedCmd.Flags().StringP("format", "m", "text", "Output format (text|json|dot)")
+	relatedCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(relatedCmd)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/querier"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+var remoteChecksumCmd = &cobra.Command{
+	Use:   "remote-checksum",
+	Short: "Compare metadata checksums with the checksums stored on EOSPUBLIC",
+	Long: `Compare metadata checksums with the checksums stored on EOSPUBLIC.
+
+Select a CERN Open Data bibliographic record by a record ID, a DOI, or a
+title and ask the XRootD server for the checksum it stores for each data
+file of the record, without downloading the files. Each checksum is
+compared with the checksum in the record metadata, which finds
+inconsistencies between the metadata and the storage before spending
+hours downloading.
+
+The files are selected with the same flags as download-files, except that
+files stored on tape are included by default: the storage knows their
+checksums without staging them.
+
+Examples:
+
+     $ cernopendata-client remote-checksum --recid 5500
+
+     $ cernopendata-client remote-checksum --recid 6004 --filter-range 1-2
+
+     $ cernopendata-client remote-checksum --recid 5500 --jobs 8 --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		selection := parseFileSelection(cmd, "query")
+
+		if outputFormat != "text" && outputFormat != "json" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json')", outputFormat))
+			os.Exit(1)
+		}
+
+		if jobs < 0 {
+			printer.DisplayMessage(printer.Error, "--jobs must not be negative")
+			os.Exit(1)
+		}
+		if jobs == 0 {
+			jobs = runtime.NumCPU()
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+		if !ok {
+			return
+		}
+
+		client := searcher.NewClient(server)
+		record, err := client.GetRecord(parsedRecid)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+			os.Exit(1)
+		}
+
+		fileList, _, _, _ := selection.apply(client, record, parsedRecid, "xrootd")
+
+		results := compareRemoteChecksums(cmd, fileList, jobs)
+
+		if outputFormat == "json" {
+			jsonBytes, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+				os.Exit(1)
+			}
+			printer.DisplayOutput(string(jsonBytes))
+		} else {
+			printer.DisplayOutput(querier.Text(results))
+		}
+
+		if _, mismatched, failed := querier.Count(results); mismatched > 0 || failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// compareRemoteChecksums queries the XRootD checksums of the files, given
+// with root:// URIs, and compares them with the metadata checksums.
+func compareRemoteChecksums(cmd *cobra.Command, fileList []any, jobs int) []querier.Result {
+	q := querier.NewQuerier()
+	defer func() {
+		_ = q.Close()
+	}()
+	return querier.Compare(cmd.Context(), fileList, q.Checksum, jobs)
+}
+
+func init() {
+	remoteChecksumCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
+	remoteChecksumCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
+	remoteChecksumCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
+	remoteChecksumCmd.Flags().StringP("filter-name", "n", "", "Query files matching exactly the file name")
+	remoteChecksumCmd.Flags().StringP("filter-regexp", "e", "", "Query files matching the regular expression")
+	remoteChecksumCmd.Flags().String("filter-range", "", "Query files from a specified list range (i-j)")
+	remoteChecksumCmd.Flags().BoolP("expand", "x", true, "Expand file indexes?")
+	remoteChecksumCmd.Flags().Bool("no-expand", false, "Don't expand file indexes")
+	remoteChecksumCmd.Flags().StringP("file-availability", "", "all", "Filter files by their availability status [online, all]")
+	remoteChecksumCmd.Flags().StringArray("index", nil, "Query only files from the file index with this key, key without extension, or glob (can be repeated)")
+	remoteChecksumCmd.Flags().IntP("jobs", "j", 4, "Number of checksum queries in flight (0 for one per CPU)")
+	remoteChecksumCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	remoteChecksumCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(remoteChecksumCmd)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+// printCandidates prints record candidates as tab-separated text or JSON.
+func printCandidates(candidates []searcher.Candidate, outputFormat string) error {
+	if outputFormat == "json" {
+		jsonBytes, err := json.MarshalIndent(candidates, "", "  ")
+		if err != nil {
+			return err
+		}
+		printer.DisplayOutput(string(jsonBytes))
+		return nil
+	}
+
+	for _, c := range candidates {
+		if c.Score > 0 {
+			printer.DisplayOutput(fmt.Sprintf("%d\t%s\t%s\t%.2f", c.Recid, c.Type, c.Title, c.Score))
+		} else {
+			printer.DisplayOutput(fmt.Sprintf("%d\t%s\t%s", c.Recid, c.Type, c.Title))
+		}
+	}
+	return nil
+}
+
+// addResolveFlags registers the flags controlling ambiguous DOI/title lookups.
+func addResolveFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all-matches", false, "List all records matching the DOI or title and exit")
+	cmd.Flags().String("pick", "", "Choose among several matching records [first, newest, oldest]")
+	cmd.Flags().Bool("fuzzy", false, "Match titles approximately instead of exactly")
+}
+
+// resolveRecid resolves the record selected by --recid/--doi/--title honouring
+// the flags added by addResolveFlags. It returns false when --all-matches was
+// given and the candidates have been listed instead.
+func resolveRecid(cmd *cobra.Command, server, doi, title string, recid int) (int, bool) {
+	allMatches, _ := cmd.Flags().GetBool("all-matches")
+	pick, _ := cmd.Flags().GetString("pick")
+	fuzzy, _ := cmd.Flags().GetBool("fuzzy")
+
+	if allMatches && recid <= 0 {
+		candidates, err := searcher.FindRecidCandidates(server, doi, title, fuzzy)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+			os.Exit(1)
+		}
+		if len(candidates) == 0 {
+			printer.DisplayMessage(printer.Error, "No matching record found")
+			os.Exit(1)
+		}
+		if err := printCandidates(candidates, "text"); err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to print candidates: %v", err))
+			os.Exit(1)
+		}
+		return 0, false
+	}
+
+	parsedRecid, err := searcher.ResolveRecid(server, doi, title, recid, pick, fuzzy)
+	if err != nil {
+		message := fmt.Sprintf("Failed to find record: %v", err)
+		if errors.Is(err, searcher.ErrAmbiguous) {
+			message += " (use --all-matches or --pick to choose one)"
+		}
+		printer.DisplayMessage(printer.Error, message)
+		os.Exit(1)
+	}
+	return parsedRecid, true
+}
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Resolve a DOI or title to matching record IDs",
+	Long: `Resolve a DOI or title to matching record IDs.
+
+List all records matching a DOI or title together with their record
+ID, type and title. Use --fuzzy to match titles approximately and
+--pick to print only the record ID of a deterministically chosen
+candidate for use in scripts.
+
+Examples:
+
+     $ cernopendata-client resolve --doi 10.7483/OPENDATA.CMS.A342.9982
+
+     $ cernopendata-client resolve --title "Higgs-to-four-lepton analysis example" --fuzzy
+
+     $ cernopendata-client resolve --title "Higgs-to-four-lepton analysis example" --fuzzy --pick newest
+
+     $ cernopendata-client resolve --title "Higgs-to-four-lepton analysis example" --fuzzy --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		fuzzy, _ := cmd.Flags().GetBool("fuzzy")
+		pick, _ := cmd.Flags().GetString("pick")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		if outputFormat != "text" && outputFormat != "json" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json')", outputFormat))
+			os.Exit(1)
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		candidates, err := searcher.FindRecidCandidates(server, doi, title, fuzzy)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+			os.Exit(1)
+		}
+
+		if len(candidates) == 0 {
+			printer.DisplayMessage(printer.Error, "No matching record found")
+			os.Exit(1)
+		}
+
+		if pick != "" {
+			candidate, err := searcher.PickCandidate(candidates, pick)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to pick record: %v", err))
+				os.Exit(1)
+			}
+			candidates = []searcher.Candidate{candidate}
+			if outputFormat == "text" {
+				printer.DisplayOutput(fmt.Sprintf("%d", candidate.Recid))
+				return
+			}
+		}
+
+		if err := printCandidates(candidates, outputFormat); err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to print candidates: %v", err))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	resolveCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier")
+	resolveCmd.Flags().StringP("title", "t", "", "Record title")
+	resolveCmd.Flags().Bool("fuzzy", false, "Match titles approximately instead of exactly")
+	resolveCmd.Flags().String("pick", "", "Print only the record ID of one candidate [first, newest, oldest]")
+	resolveCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	resolveCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+}
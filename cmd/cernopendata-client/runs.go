@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/masker"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/relater"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Work with validated run lists",
+	Long: `Work with validated run lists (luminosity masks).
+
+Select a CERN Open Data record by a record ID, a DOI, or a title, or give
+a local file. For a dataset, the validated runs record it references is
+looked up and its run list is fetched; a validated runs record can also be
+selected directly. The run list can then be combined with local files
+(union, intersection, subtraction, applied in this order) and restricted
+to a range of runs.
+
+The result is summarized as text, or written in the standard CMS JSON
+format that can be used as a lumi mask in analysis code.
+
+Examples:
+
+     $ cernopendata-client runs --recid 6004
+
+     $ cernopendata-client runs --recid 6004 --format json > Cert_2012B_JSON.txt
+
+     $ cernopendata-client runs --recid 6004 --run-range 194000-195000 --per-run
+
+     $ cernopendata-client runs --input golden.json --intersect muon.json --output analysis.json
+
+     $ cernopendata-client runs --input golden.json --subtract processed.json --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		input, _ := cmd.Flags().GetString("input")
+		filterName, _ := cmd.Flags().GetString("filter-name")
+		unions, _ := cmd.Flags().GetStringArray("union")
+		intersects, _ := cmd.Flags().GetStringArray("intersect")
+		subtracts, _ := cmd.Flags().GetStringArray("subtract")
+		runRange, _ := cmd.Flags().GetString("run-range")
+		perRun, _ := cmd.Flags().GetBool("per-run")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		server, _ := cmd.Flags().GetString("server")
+
+		if outputFormat != "text" && outputFormat != "json" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json')", outputFormat))
+			os.Exit(1)
+		}
+
+		var firstRun, lastRun int
+		if runRange != "" {
+			ranges, err := utils.ParseRanges([]string{runRange})
+			if err != nil || len(ranges) != 1 {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid run range: %s (use first-last)", runRange))
+				os.Exit(1)
+			}
+			firstRun, lastRun = ranges[0][0], ranges[0][1]
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		var mask masker.LumiMask
+		var source string
+		if input != "" {
+			mask, err = masker.Load(input)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load run list: %v", err))
+				os.Exit(1)
+			}
+			source = input
+		} else {
+			parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+			if !ok {
+				return
+			}
+			mask, source, err = fetchValidatedRuns(searcher.NewClient(server), parsedRecid, filterName)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get validated runs: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		operands := []struct {
+			paths []string
+			apply func(masker.LumiMask, masker.LumiMask) masker.LumiMask
+		}{
+			{unions, masker.LumiMask.Union},
+			{intersects, masker.LumiMask.Intersect},
+			{subtracts, masker.LumiMask.Subtract},
+		}
+		for _, operand := range operands {
+			for _, path := range operand.paths {
+				other, err := masker.Load(path)
+				if err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load run list: %v", err))
+					os.Exit(1)
+				}
+				mask = operand.apply(mask, other)
+			}
+		}
+
+		if runRange != "" {
+			mask = mask.FilterRuns(firstRun, lastRun)
+		}
+
+		if output != "" {
+			if err := os.WriteFile(output, []byte(mask.JSON()+"\n"), 0600); err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to write run list: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		if outputFormat == "json" {
+			printer.DisplayOutput(mask.JSON())
+			return
+		}
+		printer.DisplayOutput(fmt.Sprintf("Source:        %s", source))
+		printer.DisplayOutput(mask.Text(perRun))
+		if output != "" {
+			printer.DisplayOutput(fmt.Sprintf("\nRun list written to %s", output))
+		}
+	},
+}
+
+// fetchValidatedRuns returns the run list of the validated runs record
+// referenced by a record, or of the record itself if it is one. Files that
+// are not run lists are ignored; if several remain, filterName (a glob on
+// the file name) has to select one. The second return value describes where
+// the run list comes from.
+func fetchValidatedRuns(client *searcher.Client, recid int, filterName string) (masker.LumiMask, string, error) {
+	graph, err := relater.Traverse(recid, recordFetcher(client), relater.Options{Depth: 1, Server: client.Server()})
+	if err != nil {
+		return nil, "", err
+	}
+
+	var candidates []relater.Node
+	if graph.Nodes[0].HasType("Validation") {
+		candidates = graph.Nodes[:1]
+	} else {
+		for _, node := range graph.Related() {
+			if node.Error == "" && node.HasType("Validation") {
+				candidates = append(candidates, node)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("record %d references no validated runs record", recid)
+	}
+
+	type runList struct {
+		recid int
+		name  string
+		mask  masker.LumiMask
+	}
+	var found []runList
+	for _, node := range candidates {
+		record := &searcher.RecordResponse{ID: strconv.Itoa(node.Recid), Metadata: node.Metadata}
+		files, err := client.GetFilesList(record, "http", false)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, file := range files {
+			name := filepath.Base(file.URI)
+			ext := strings.ToLower(filepath.Ext(name))
+			if ext != ".json" && ext != ".txt" {
+				continue
+			}
+			if filterName != "" {
+				if ok, _ := filepath.Match(filterName, name); !ok {
+					continue
+				}
+			}
+			data, err := client.FetchFile(file.URI)
+			if err != nil {
+				return nil, "", err
+			}
+			mask, err := masker.Parse(data)
+			if err != nil {
+				continue
+			}
+			found = append(found, runList{recid: node.Recid, name: name, mask: mask})
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, "", fmt.Errorf("no run list found in validated runs record %d", candidates[0].Recid)
+	case 1:
+		return found[0].mask, fmt.Sprintf("record %d, %s", found[0].recid, found[0].name), nil
+	default:
+		var names []string
+		for _, f := range found {
+			names = append(names, fmt.Sprintf("%s (record %d)", f.name, f.recid))
+		}
+		return nil, "", fmt.Errorf("several run lists found, choose one with --filter-name: %s", strings.Join(names, ", "))
+	}
+}
+
+func init() {
+	runsCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
+	runsCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
+	runsCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
+	runsCmd.Flags().StringP("input", "i", "", "Local run list in CMS JSON format")
+	runsCmd.Flags().StringP("filter-name", "n", "", "Run list file name (glob) if the validated runs record has several")
+	runsCmd.Flags().StringArray("union", nil, "Add the lumisections of this run list file (can be repeated)")
+	runsCmd.Flags().StringArray("intersect", nil, "Keep only the lumisections also in this run list file (can be repeated)")
+	runsCmd.Flags().StringArray("subtract", nil, "Remove the lumisections in this run list file (can be repeated)")
+	runsCmd.Flags().String("run-range", "", "Keep only the runs in this range (first-last)")
+	runsCmd.Flags().Bool("per-run", false, "List each run with its lumisections in the text summary")
+	runsCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	runsCmd.Flags().StringP("output", "o", "", "Also write the run list in CMS JSON format to this file")
+	runsCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(runsCmd)
+}
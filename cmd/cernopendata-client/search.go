@@ -5,6 +5,7 @@ import (
 	"maps"
 	"os"
 	"strings"
+	"text/template"
 
 	"github.com/spf13/cobra"
 
@@ -26,6 +27,11 @@ or by copying URLs directly from the web portal.
 Search patterns support AND, OR operators and field-specific queries.
 See https://opendata.cern.ch/docs/cod-search-tips for syntax details.
 
+The --output-value option accepts a dot path or a query expression, which
+is evaluated against each record (see get-metadata --help). The --template
+option formats each record, or each --output-value result, with a Go
+text/template.
+
 Examples:
 
      $ cernopendata-client search --query-pattern "Higgs"
@@ -36,14 +42,20 @@ Examples:
 
      $ cernopendata-client search --query-pattern "title.tokens:*muon*" --output-value title
 
+     $ cernopendata-client search --query-pattern "Higgs" --output-value '{recid, title}' --format json
+
+     $ cernopendata-client search --query-pattern "Higgs" --template '{{.recid}} {{.title}} {{.doi | default "-"}}'
+
      $ cernopendata-client search --query-pattern "Higgs" --size -1`,
 	Run: func(cmd *cobra.Command, args []string) {
 		query, _ := cmd.Flags().GetString("query")
 		queryPattern, _ := cmd.Flags().GetString("query-pattern")
 		queryFacets, _ := cmd.Flags().GetStringArray("query-facet")
 		outputValue, _ := cmd.Flags().GetString("output-value")
-		filterStr, _ := cmd.Flags().GetString("filter")
+		filters, _ := cmd.Flags().GetStringArray("filter")
+		strictFilter, _ := cmd.Flags().GetBool("strict-filter")
 		outputFormat, _ := cmd.Flags().GetString("format")
+		templateText, _ := cmd.Flags().GetString("template")
 		server, _ := cmd.Flags().GetString("server")
 		page, _ := cmd.Flags().GetInt("page")
 		size, _ := cmd.Flags().GetInt("size")
@@ -79,11 +91,26 @@ Examples:
 			return
 		}
 
-		if filterStr != "" && outputValue == "" {
+		if len(filters) > 0 && outputValue == "" {
 			printer.DisplayMessage(printer.Error, "--filter can only be used with --output-value")
 			os.Exit(1)
 		}
 
+		if outputFormat != "pretty" && outputFormat != "json" && outputFormat != "yaml" && outputFormat != "toml" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'pretty', 'json', 'yaml', 'toml')", outputFormat))
+			os.Exit(1)
+		}
+
+		var tmpl *template.Template
+		if templateText != "" {
+			var err error
+			tmpl, err = metadater.ParseTemplate(templateText)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid template: %v", err))
+				os.Exit(1)
+			}
+		}
+
 		// Build query from parameters
 		facetsMap := make(map[string]string)
 
@@ -140,13 +167,18 @@ Examples:
 			return
 		}
 
-		var filters []string
-		if filterStr != "" {
-			filters = []string{filterStr}
-		}
-
 		// Output handling
-		if outputValue == "" {
+		if outputValue == "" && tmpl != nil {
+			// Format each record with the template
+			for _, hit := range searchResp.Hits.Hits {
+				output, err := metadater.FormatTemplate(tmpl, hit.Metadata)
+				if err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format output: %v", err))
+					os.Exit(1)
+				}
+				printer.DisplayOutput(output)
+			}
+		} else if outputValue == "" {
 			// Default: print record titles
 			for _, hit := range searchResp.Hits.Hits {
 				if title, ok := hit.Metadata["title"].(string); ok {
@@ -166,14 +198,14 @@ Examples:
 			// Extract specific field from each record
 			var results []any
 			for _, hit := range searchResp.Hits.Hits {
-				value, err := metadater.ExtractNestedField(hit.Metadata, outputValue)
+				value, err := metadater.Query(hit.Metadata, outputValue)
 				if err == nil && value != nil {
 					results = append(results, value)
 				}
 			}
 
 			if len(filters) > 0 {
-				filtered, err := metadater.FilterArray(results, filters)
+				filtered, err := metadater.FilterItems(results, filters, strictFilter)
 				if err != nil {
 					printer.DisplayMessage(printer.Error, fmt.Sprintf("Filter error: %v", err))
 					os.Exit(1)
@@ -181,7 +213,7 @@ Examples:
 				results = filtered
 			}
 
-			if outputFormat == "json" {
+			if tmpl == nil && outputFormat != "pretty" {
 				output, err := metadater.FormatOutput(results, outputFormat)
 				if err != nil {
 					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format output: %v", err))
@@ -189,9 +221,14 @@ Examples:
 				}
 				printer.DisplayOutput(output)
 			} else {
-				// Pretty format: print each result on a line
+				// Pretty format or template: print each result separately
 				for _, result := range results {
-					printer.DisplayOutput(fmt.Sprintf("%v", result))
+					output, err := metadater.Render(result, outputFormat, tmpl)
+					if err != nil {
+						printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to format output: %v", err))
+						os.Exit(1)
+					}
+					printer.DisplayOutput(output)
 				}
 			}
 		}
@@ -202,9 +239,11 @@ func init() {
 	searchCmd.Flags().StringP("query", "q", "", "Full URL or query string from CERN Open Data portal")
 	searchCmd.Flags().String("query-pattern", "", "Free text search pattern (see https://opendata.cern.ch/docs/cod-search-tips)")
 	searchCmd.Flags().StringArrayP("query-facet", "f", []string{}, "Facet filter in key=value format (can be repeated)")
-	searchCmd.Flags().StringP("output-value", "o", "", "Extract specific metadata field from results")
-	searchCmd.Flags().String("filter", "", "Filter array results (requires --output-value)")
-	searchCmd.Flags().StringP("format", "m", "pretty", "Output format (pretty|json)")
+	searchCmd.Flags().StringP("output-value", "o", "", "Extract specific metadata field or query expression from results")
+	searchCmd.Flags().StringArray("filter", nil, "Filter array results, e.g. 'size>1GB' (requires --output-value, can be repeated)")
+	searchCmd.Flags().Bool("strict-filter", false, "Exclude results lacking a filtered field")
+	searchCmd.Flags().StringP("format", "m", "pretty", "Output format (pretty|json|yaml|toml)")
+	searchCmd.Flags().String("template", "", "Format each record or --output-value result with a Go template, e.g. '{{.recid}} {{.title}}'")
 	searchCmd.Flags().StringP("server", "s", "", "CERN Open Data server URL [default=http://opendata.cern.ch]")
 	searchCmd.Flags().IntP("page", "p", 1, "Page number")
 	searchCmd.Flags().Int("size", 10, "Page size (-1 for all results)")
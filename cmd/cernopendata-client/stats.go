@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/aggregator"
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the size of matching records",
+	Long: `Summarize the size of records matching a search.
+
+Search CERN Open Data records and add up their total size, number of files
+and number of events, and count them by file availability (online or on
+demand). The totals can be grouped by search facets (experiment, year,
+type, subtype, category, collision_type, collision_energy, file_type,
+keywords) or by any metadata path. Records with several values for a group,
+such as several experiments, are counted in each of them.
+
+Only the search results are read, page by page, so the file lists of the
+records are never fetched.
+
+Examples:
+
+     $ cernopendata-client stats --query-facet experiment=CMS --query-facet type=Dataset --query-facet year=2012
+
+     $ cernopendata-client stats --query-facet experiment=CMS --query-facet type=Dataset --group-by year
+
+     $ cernopendata-client stats --query-pattern "Run2012" --group-by subtype --group-by collision_energy --sort size
+
+     $ cernopendata-client stats --query-facet experiment=CMS --group-by distribution.formats --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		queryPattern, _ := cmd.Flags().GetString("query-pattern")
+		queryFacets, _ := cmd.Flags().GetStringArray("query-facet")
+		groupBy, _ := cmd.Flags().GetStringArray("group-by")
+		sortBy, _ := cmd.Flags().GetString("sort")
+		rawBytes, _ := cmd.Flags().GetBool("bytes")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		if outputFormat != "text" && outputFormat != "json" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json')", outputFormat))
+			os.Exit(1)
+		}
+
+		switch sortBy {
+		case "key", "records", "size", "files", "events":
+		default:
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid sort order: %s (choose from 'key', 'records', 'size', 'files', 'events')", sortBy))
+			os.Exit(1)
+		}
+
+		facetsMap := make(map[string]string)
+		for _, qf := range queryFacets {
+			parts := strings.SplitN(qf, "=", 2)
+			if len(parts) != 2 {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid facet format: %s (expected key=value)", qf))
+				os.Exit(1)
+			}
+			facetsMap[parts[0]] = parts[1]
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		client := searcher.NewClient(server)
+		agg := aggregator.NewAggregator(groupBy)
+		total, err := client.EachRecord(queryPattern, facetsMap, "", func(hit searcher.SearchHit) error {
+			agg.Add(hit.Metadata)
+			return nil
+		})
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Search failed: %v", err))
+			os.Exit(1)
+		}
+
+		if total == 0 {
+			printer.DisplayMessage(printer.Info, "No records found.")
+			return
+		}
+
+		result, err := agg.Result(sortBy)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to group records: %v", err))
+			os.Exit(1)
+		}
+
+		if outputFormat == "json" {
+			jsonBytes, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+				os.Exit(1)
+			}
+			printer.DisplayOutput(string(jsonBytes))
+			return
+		}
+
+		printer.DisplayOutput(result.Table(rawBytes))
+	},
+}
+
+func init() {
+	statsCmd.Flags().String("query-pattern", "", "Free text search pattern (see https://opendata.cern.ch/docs/cod-search-tips)")
+	statsCmd.Flags().StringArrayP("query-facet", "f", []string{}, "Facet filter in key=value format (can be repeated)")
+	statsCmd.Flags().StringArrayP("group-by", "g", nil, "Group by a facet or metadata path (can be repeated)")
+	statsCmd.Flags().String("sort", "key", "Order of the groups (key|records|size|files|events)")
+	statsCmd.Flags().Bool("bytes", false, "Show sizes in bytes instead of human-readable units")
+	statsCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	statsCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+	"github.com/clelange/cernopendata-client-go/internal/linter"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+// validationResult holds the findings for one record.
+type validationResult struct {
+	Source   string           `json:"source"`
+	Errors   int              `json:"errors"`
+	Warnings int              `json:"warnings"`
+	Findings []linter.Finding `json:"findings"`
+}
+
+var validateMetadataCmd = &cobra.Command{
+	Use:   "validate-metadata",
+	Short: "Validate record metadata",
+	Long: `Validate record metadata against a JSON Schema and consistency rules.
+
+Check metadata saved with get-metadata --format json, or a live record
+selected by a record ID, a DOI, or a title. The metadata is validated
+against the bundled schema of the core record fields, or against another
+schema given as a file or URL; with --schema record, the schema named in
+the record's $schema field is fetched. The consistency rules check that
+files have sizes, that checksums are <algorithm>:<hex digest> values of a
+supported algorithm, that the DOI is well-formed, and that the file index
+totals match the distribution.
+
+Each finding is reported with the JSON pointer of the offending value. The
+command exits with status 1 if any errors are found; warnings do not
+affect the exit status.
+
+Examples:
+
+     $ cernopendata-client validate-metadata --recid 6004
+
+     $ cernopendata-client validate-metadata --input record.json --input other.json
+
+     $ cernopendata-client validate-metadata --input record.json --schema record-v1.0.0.json
+
+     $ cernopendata-client validate-metadata --recid 6004 --schema record --no-rules --format json
+
+     $ cernopendata-client validate-metadata --list-rules`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recid, err := cmd.Flags().GetInt("recid")
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid recid: %v", err))
+			os.Exit(1)
+		}
+		doi, _ := cmd.Flags().GetString("doi")
+		title, _ := cmd.Flags().GetString("title")
+		inputs, _ := cmd.Flags().GetStringArray("input")
+		schemaSource, _ := cmd.Flags().GetString("schema")
+		noSchema, _ := cmd.Flags().GetBool("no-schema")
+		noRules, _ := cmd.Flags().GetBool("no-rules")
+		listRules, _ := cmd.Flags().GetBool("list-rules")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		server, _ := cmd.Flags().GetString("server")
+
+		if listRules {
+			for _, rule := range linter.Rules {
+				printer.DisplayOutput(fmt.Sprintf("%-20s %s", rule.Name, rule.Description))
+			}
+			return
+		}
+
+		if outputFormat != "text" && outputFormat != "json" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', 'json')", outputFormat))
+			os.Exit(1)
+		}
+
+		if noSchema && noRules {
+			printer.DisplayMessage(printer.Error, "Cannot specify both --no-schema and --no-rules")
+			os.Exit(1)
+		}
+
+		if server == "" {
+			server = config.ServerHTTPURI
+		}
+
+		client := searcher.NewClient(server)
+
+		type source struct {
+			name     string
+			metadata map[string]any
+		}
+		var sources []source
+		for _, input := range inputs {
+			record, err := searcher.LoadRecordFile(input)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load record: %v", err))
+				os.Exit(1)
+			}
+			sources = append(sources, source{input, record.Metadata})
+		}
+		if len(inputs) == 0 {
+			parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+			if !ok {
+				return
+			}
+			record, err := client.GetRecord(parsedRecid)
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+				os.Exit(1)
+			}
+			sources = append(sources, source{fmt.Sprintf("record %d", parsedRecid), record.Metadata})
+		}
+
+		schemas := make(map[string]*linter.Schema)
+		getSchema := func(location string) (*linter.Schema, error) {
+			if schema, ok := schemas[location]; ok {
+				return schema, nil
+			}
+			schema, err := loadSchema(client, location)
+			if err != nil {
+				return nil, err
+			}
+			schemas[location] = schema
+			return schema, nil
+		}
+
+		var results []validationResult
+		failed := false
+		for _, src := range sources {
+			var schema *linter.Schema
+			switch {
+			case noSchema:
+			case schemaSource == "":
+				schema = linter.DefaultSchema()
+			default:
+				location := schemaSource
+				if schemaSource == "record" {
+					location, _ = src.metadata["$schema"].(string)
+					if location == "" {
+						printer.DisplayMessage(printer.Error, fmt.Sprintf("%s has no $schema field", src.name))
+						os.Exit(1)
+					}
+				}
+				schema, err = getSchema(location)
+				if err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to load schema: %v", err))
+					os.Exit(1)
+				}
+			}
+
+			findings := linter.Lint(src.metadata, schema, !noRules)
+			errors, warnings := linter.Count(findings)
+			if errors > 0 {
+				failed = true
+			}
+			if findings == nil {
+				findings = []linter.Finding{}
+			}
+			results = append(results, validationResult{Source: src.name, Errors: errors, Warnings: warnings, Findings: findings})
+		}
+
+		if outputFormat == "json" {
+			jsonBytes, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to marshal JSON: %v", err))
+				os.Exit(1)
+			}
+			printer.DisplayOutput(string(jsonBytes))
+		} else {
+			for _, result := range results {
+				printer.DisplayOutput(linter.Text(result.Source, result.Findings))
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// loadSchema reads a JSON Schema from a file or an http(s) URL.
+func loadSchema(client *searcher.Client, location string) (*linter.Schema, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = client.FetchFile(location)
+	} else {
+		data, err = os.ReadFile(location) // #nosec G304
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema, err := linter.ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", location, err)
+	}
+	return schema, nil
+}
+
+func init() {
+	validateMetadataCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
+	validateMetadataCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
+	validateMetadataCmd.Flags().StringP("title", "t", "", "Record title (exact match, no wildcards)")
+	validateMetadataCmd.Flags().StringArrayP("input", "i", nil, "Metadata file saved with get-metadata --format json (can be repeated)")
+	validateMetadataCmd.Flags().String("schema", "", "JSON Schema file or URL, or 'record' for the record's $schema [default: bundled schema]")
+	validateMetadataCmd.Flags().Bool("no-schema", false, "Only apply the consistency rules")
+	validateMetadataCmd.Flags().Bool("no-rules", false, "Only validate against the schema")
+	validateMetadataCmd.Flags().Bool("list-rules", false, "List the consistency rules")
+	validateMetadataCmd.Flags().StringP("format", "m", "text", "Output format (text|json)")
+	validateMetadataCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(validateMetadataCmd)
+}
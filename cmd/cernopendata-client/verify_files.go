@@ -1,15 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
 	"github.com/clelange/cernopendata-client-go/internal/config"
-	"github.com/clelange/cernopendata-client-go/internal/downloader"
 	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/querier"
+	"github.com/clelange/cernopendata-client-go/internal/repairer"
+	"github.com/clelange/cernopendata-client-go/internal/reporter"
 	"github.com/clelange/cernopendata-client-go/internal/searcher"
 	"github.com/clelange/cernopendata-client-go/internal/verifier"
 )
@@ -23,9 +32,86 @@ Select a CERN Open Data bibliographic record by a record ID, a
 DOI, or a title and verify integrity of downloaded data files
 belonging to this record.
 
+The files are selected with the same flags as download-files: file
+indexes are expanded unless --no-expand is given, files stored on tape
+are skipped unless --file-availability all is given, and --index,
+--filter-name, --filter-regexp and --filter-range select a subset, so any
+download selection can be verified with the flags used to download it.
+
+Files are hashed by --jobs workers in parallel (0 for one per CPU); the
+results are still reported in the order of the record's file list. Use
+--progress to display the bytes hashed and the estimated time left.
+
+Computed checksums are cached in a .cernopendata-checksums.json file in
+each directory, together with the size, modification time and inode of
+the file. Unchanged files are not hashed again on the next run unless
+--rehash is given. download-files uses the cached checksums to decide
+whether an existing file needs to be downloaded again.
+
+The expected checksums are <algorithm>:<hex digest> values; adler32,
+crc32c, md5 and sha256 are supported. A checksum with another or no
+algorithm prefix is reported as an error rather than as a mismatch. With
+--algorithm, checksums of other algorithms are computed in the same read
+pass, shown for the verified files and cached, e.g. for manifests.
+
+With --repair, files that are missing or fail verification are downloaded
+again with the selected download engine and verified once more. Files
+that are shorter than expected are resumed, files with extra trailing
+bytes are truncated if the remaining bytes have the expected checksum,
+and other corrupt files are moved aside to <name>.corrupt before being
+downloaded again. A report lists the outcome for every repaired file.
+
+After verification, the tree below the input directory is walked for
+files that do not belong to the record: orphans, duplicates of record
+files in subdirectories, zero-byte files and .part leftovers of
+interrupted downloads. Subdirectories named after a record ID, where
+download-files --with-related stores related records, are not walked.
+Extra files are reported as warnings and do not fail the verification;
+--prune removes them, together with subdirectories left empty, after
+listing them and asking for confirmation; --yes removes them without
+asking. Since everything else below the input directory counts as
+extra, --prune refuses to run if none of the record files were found or
+if there are more orphans than verified files. --prune-dry-run only
+lists the files --prune would remove.
+
+With --format json, junit or tap, the result of every file, the summary
+and the extra files are written to standard output as a report for CI
+systems and dashboards, instead of the messages about individual files.
+
+The exit status tells the failure categories apart. It is 0 if all files
+were verified and 1 for errors such as invalid flags or an unknown
+record. Otherwise it is the sum of 2 if files are missing or unreadable,
+4 for wrong sizes, 8 for wrong checksums and 16 for checksums that cannot
+be verified; with --repair, only files that could not be repaired count.
+With --remote, it is 1 if any checksum mismatches or cannot be queried.
+
+With --remote, no local files are read: the checksums stored on EOSPUBLIC
+are queried over XRootD and compared with the metadata checksums, like
+remote-checksum does.
+
 Examples:
 
-     $ cernopendata-client verify-files --recid 5500`,
+     $ cernopendata-client verify-files --recid 5500
+
+     $ cernopendata-client verify-files --recid 6004 --filter-range 1-2
+
+     $ cernopendata-client verify-files --recid 5500 --no-expand
+
+     $ cernopendata-client verify-files --recid 5500 --jobs 8 --progress
+
+     $ cernopendata-client verify-files --recid 5500 --rehash
+
+     $ cernopendata-client verify-files --recid 5500 --repair --download-engine xrootd
+
+     $ cernopendata-client verify-files --recid 5500 --algorithm sha256 --algorithm md5
+
+     $ cernopendata-client verify-files --recid 5500 --remote
+
+     $ cernopendata-client verify-files --recid 5500 --prune-dry-run
+
+     $ cernopendata-client verify-files --recid 5500 --prune --yes
+
+     $ cernopendata-client verify-files --recid 5500 --format junit > report.xml`,
 	Run: func(cmd *cobra.Command, args []string) {
 		recid, err := cmd.Flags().GetInt("recid")
 		if err != nil {
@@ -35,93 +121,266 @@ Examples:
 		doi, _ := cmd.Flags().GetString("doi")
 		title, _ := cmd.Flags().GetString("title")
 		inputDir, _ := cmd.Flags().GetString("input-dir")
-		filterName, _ := cmd.Flags().GetString("filter-name")
-		filterRegexp, _ := cmd.Flags().GetString("filter-regexp")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		showProgress, _ := cmd.Flags().GetBool("progress")
+		rehash, _ := cmd.Flags().GetBool("rehash")
+		repair, _ := cmd.Flags().GetBool("repair")
+		remote, _ := cmd.Flags().GetBool("remote")
+		prune, _ := cmd.Flags().GetBool("prune")
+		pruneDryRun, _ := cmd.Flags().GetBool("prune-dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+		outputFormat, _ := cmd.Flags().GetString("format")
+		algorithms, _ := cmd.Flags().GetStringArray("algorithm")
+		downloadEngine, _ := cmd.Flags().GetString("download-engine")
+		retryLimit, _ := cmd.Flags().GetInt("retry-limit")
+		retrySleep, _ := cmd.Flags().GetInt("retry-sleep")
 		server, _ := cmd.Flags().GetString("server")
 
-		if server == "" {
-			server = config.ServerHTTPURI
+		selection := parseFileSelection(cmd, "verify")
+
+		if jobs < 0 {
+			printer.DisplayMessage(printer.Error, "--jobs must not be negative")
+			os.Exit(1)
+		}
+		if jobs == 0 {
+			jobs = runtime.NumCPU()
 		}
 
-		parsedRecid, err := searcher.GetRecid(server, doi, title, recid)
-		if err != nil {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to find record: %v", err))
+		for _, algorithm := range algorithms {
+			if _, err := checksum.New(algorithm); err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid algorithm: %v", err))
+				os.Exit(1)
+			}
+		}
+
+		if outputFormat != "text" && !slices.Contains(reporter.Formats, outputFormat) {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid format: %s (choose from 'text', '%s')", outputFormat, strings.Join(reporter.Formats, "', '")))
 			os.Exit(1)
 		}
+		report := outputFormat != "text"
 
-		if inputDir == "" {
-			inputDir = fmt.Sprintf("%d", parsedRecid)
+		if report && (remote || repair) {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Cannot use --format %s with --remote or --repair", outputFormat))
+			os.Exit(1)
 		}
 
-		client := searcher.NewClient(server)
-		record, err := client.GetRecord(parsedRecid)
-		if err != nil {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
+		if remote && repair {
+			printer.DisplayMessage(printer.Error, "Cannot specify both --remote and --repair")
 			os.Exit(1)
 		}
 
-		files, err := client.GetFilesList(record, "http", false)
-		if err != nil {
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get files list: %v", err))
+		if downloadEngine != "" && downloadEngine != "http" && downloadEngine != "xrootd" {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Invalid download engine: %s (choose from 'http', 'xrootd')", downloadEngine))
 			os.Exit(1)
 		}
 
-		var fileList []any
-		for _, file := range files {
-			fileList = append(fileList, map[string]any{
-				"uri":      file.URI,
-				"size":     float64(file.Size),
-				"checksum": file.Checksum,
-			})
+		protocol := "http"
+		if downloadEngine == "xrootd" || remote {
+			protocol = "xrootd"
 		}
 
-		if filterName != "" {
-			nameFilters := strings.Split(filterName, ",")
-			for i, filter := range nameFilters {
-				nameFilters[i] = strings.TrimSpace(filter)
-			}
-			fileList = downloader.FilterFilesByMultipleNames(fileList, nameFilters)
+		if server == "" {
+			server = config.ServerHTTPURI
 		}
 
-		if filterRegexp != "" {
-			fileList = downloader.FilterFilesByRegex(fileList, filterRegexp)
+		parsedRecid, ok := resolveRecid(cmd, server, doi, title, recid)
+		if !ok {
+			return
 		}
 
-		if len(fileList) == 0 {
-			printer.DisplayMessage(printer.Error, "No files matching filters")
+		if inputDir == "" {
+			inputDir = fmt.Sprintf("%d", parsedRecid)
+		}
+
+		client := searcher.NewClient(server)
+		record, err := client.GetRecord(parsedRecid)
+		if err != nil {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get record: %v", err))
 			os.Exit(1)
 		}
 
+		fileList, _, _, tapeFilesSkipped := selection.apply(client, record, parsedRecid, protocol)
+
+		if remote {
+			printer.DisplayMessage(printer.Info, fmt.Sprintf("Querying checksums of %d files of record %d on EOSPUBLIC...", len(fileList), parsedRecid))
+			results := compareRemoteChecksums(cmd, fileList, jobs)
+			printer.DisplayOutput(querier.Text(results))
+			if _, mismatched, failed := querier.Count(results); mismatched > 0 || failed > 0 {
+				os.Exit(1)
+			}
+			printer.DisplayMessage(printer.Info, "Success!")
+			return
+		}
+
 		verifier := verifier.NewVerifier()
+		verifier.Jobs = jobs
+		verifier.ShowProgress = showProgress
+		verifier.Rehash = rehash
+		verifier.Algorithms = algorithms
+		verifier.Quiet = report
 		stats, err := verifier.VerifyFiles(inputDir, fileList)
 		if err != nil {
 			printer.DisplayMessage(printer.Error, fmt.Sprintf("Verification failed: %v", err))
 			os.Exit(1)
 		}
 
+		// Files of the record outside the selection are not extra files.
+		allFiles, err := recordFiles(client, record, protocol)
+		if err != nil {
+			printer.DisplayMessage(printer.Warning, fmt.Sprintf("Failed to look for extra files: %v", err))
+		} else if err := verifier.FindExtraFiles(inputDir, allFiles, stats); err != nil {
+			printer.DisplayMessage(printer.Warning, fmt.Sprintf("Failed to look for extra files: %v", err))
+		}
+		if (prune || pruneDryRun) && len(stats.Extras) > 0 {
+			pruneExtras(verifier, inputDir, stats, pruneDryRun, yes)
+		}
+
+		if report {
+			err := reporter.Write(os.Stdout, outputFormat, reporter.Report{
+				Recid:       parsedRecid,
+				Directory:   inputDir,
+				TapeSkipped: tapeFilesSkipped,
+				Stats:       stats,
+			})
+			if err != nil {
+				printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to write report: %v", err))
+				os.Exit(1)
+			}
+			if len(fileList) != len(stats.Results) {
+				os.Exit(1)
+			}
+			os.Exit(reporter.ExitCode(stats.Results))
+		}
+
 		printer.DisplayMessage(printer.Info, fmt.Sprintf("Verifying number of files for record %d...", parsedRecid))
-		printer.DisplayMessage(printer.Note, fmt.Sprintf("Expected %d, found %d", len(fileList), stats.VerifiedFiles+stats.SizeFailed+stats.ChecksumFailed+stats.MissingFiles))
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("Expected %d, found %d", len(fileList), len(stats.Results)))
 
-		if len(fileList) != (stats.VerifiedFiles + stats.SizeFailed + stats.ChecksumFailed + stats.MissingFiles) {
+		if len(fileList) != len(stats.Results) {
 			printer.DisplayMessage(printer.Error, "File count does not match.")
 			os.Exit(1)
 		}
 
-		printer.DisplayMessage(printer.Info, "\nVerification summary:")
-		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Total files:     %d", stats.TotalFiles))
-		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Verified:        %d", stats.VerifiedFiles))
-		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Size errors:     %d", stats.SizeFailed))
-		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Checksum errors: %d", stats.ChecksumFailed))
-		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Missing files:   %d", stats.MissingFiles))
+		printVerificationSummary(stats, tapeFilesSkipped)
 
-		if stats.SizeFailed > 0 || stats.ChecksumFailed > 0 || stats.MissingFiles > 0 {
-			os.Exit(1)
+		if repair && len(stats.Failures) > 0 {
+			printer.DisplayMessage(printer.Info, fmt.Sprintf("\nRepairing %d files...", len(stats.Failures)))
+			download := func(files []any, directory string) error {
+				downloadStats := runDownload(cmd, downloadEngine, files, directory, retryLimit, retrySleep, false, false)
+				if downloadStats.FailedFiles > 0 {
+					return fmt.Errorf("%d of %d files failed to download", downloadStats.FailedFiles, downloadStats.TotalFiles)
+				}
+				return nil
+			}
+			repairs := repairer.New(download, verifier).Repair(inputDir, fileList, stats.Failures)
+
+			printer.DisplayMessage(printer.Info, "\nRepair report:")
+			printer.DisplayOutput(repairer.Text(repairs))
+
+			if code := reporter.ExitCode(unrepaired(stats.Results, repairs)); code != 0 {
+				os.Exit(code)
+			}
+			printer.DisplayMessage(printer.Info, "Success!")
+			return
+		}
+
+		if code := reporter.ExitCode(stats.Results); code != 0 {
+			os.Exit(code)
 		}
 
 		printer.DisplayMessage(printer.Info, "Success!")
 	},
 }
 
+// printVerificationSummary prints the counters of a verification.
+func printVerificationSummary(stats *verifier.VerificationStats, tapeFilesSkipped int) {
+	printer.DisplayMessage(printer.Info, "\nVerification summary:")
+	printer.DisplayMessage(printer.Note, fmt.Sprintf("  Total files:     %d", stats.TotalFiles))
+	printer.DisplayMessage(printer.Note, fmt.Sprintf("  Verified:        %d", stats.VerifiedFiles))
+	printer.DisplayMessage(printer.Note, fmt.Sprintf("  Size errors:     %d", stats.SizeFailed))
+	printer.DisplayMessage(printer.Note, fmt.Sprintf("  Checksum errors: %d", stats.ChecksumFailed))
+	printer.DisplayMessage(printer.Note, fmt.Sprintf("  Missing files:   %d", stats.MissingFiles))
+	if stats.UnsupportedChecksums > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Unverifiable:    %d", stats.UnsupportedChecksums))
+	}
+	if stats.CachedFiles > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  From cache:      %d", stats.CachedFiles))
+	}
+	if tapeFilesSkipped > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Skipped (tape):  %d", tapeFilesSkipped))
+	}
+	if stats.OrphanFiles > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Orphan files:    %d", stats.OrphanFiles))
+	}
+	if stats.DuplicateFiles > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Duplicates:      %d", stats.DuplicateFiles))
+	}
+	if stats.ZeroByteFiles > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Zero-byte files: %d", stats.ZeroByteFiles))
+	}
+	if stats.PartFiles > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Partial files:   %d", stats.PartFiles))
+	}
+	if stats.PrunedFiles > 0 {
+		printer.DisplayMessage(printer.Note, fmt.Sprintf("  Pruned:          %d", stats.PrunedFiles))
+	}
+}
+
+// pruneExtras removes the extra files found below inputDir, or only lists
+// them with dryRun. Unless yes is set, the files are listed and removed
+// only if the user confirms on the terminal.
+func pruneExtras(v *verifier.Verifier, inputDir string, stats *verifier.VerificationStats, dryRun, yes bool) {
+	if err := verifier.CheckPrune(stats); err != nil {
+		if !dryRun {
+			printer.DisplayMessage(printer.Error, fmt.Sprintf("Refusing to prune: %v. Is the input directory right?", err))
+			return
+		}
+		printer.DisplayMessage(printer.Warning, fmt.Sprintf("--prune would refuse to run: %v", err))
+	}
+	if !dryRun && !yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) { // #nosec G115
+			printer.DisplayMessage(printer.Error, "Refusing to prune without confirmation; check the files with --prune-dry-run and use --yes")
+			return
+		}
+		if !confirmPrune(os.Stdin, os.Stderr, inputDir, stats.Extras) {
+			printer.DisplayMessage(printer.Info, "Nothing removed.")
+			return
+		}
+	}
+	if err := v.Prune(inputDir, stats, dryRun); err != nil {
+		printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to prune extra files: %v", err))
+	}
+}
+
+// confirmPrune lists the extra files on out and reports whether the
+// answer read from in is yes.
+func confirmPrune(in io.Reader, out io.Writer, inputDir string, extras []verifier.ExtraFile) bool {
+	_, _ = fmt.Fprintln(out, "Files to remove:")
+	for _, extra := range extras {
+		rel, _ := filepath.Rel(filepath.Clean(inputDir), extra.Path)
+		_, _ = fmt.Fprintf(out, "  %s (%s)\n", rel, extra.Kind)
+	}
+	_, _ = fmt.Fprintf(out, "Remove these %d files? [y/N] ", len(extras))
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// unrepaired returns the results of the files that were not repaired.
+// Files that could not be read are not repaired.
+func unrepaired(results []verifier.VerificationResult, repairs []repairer.Repair) []verifier.VerificationResult {
+	repaired := make(map[string]bool)
+	for _, r := range repairs {
+		repaired[r.Path] = r.Repaired
+	}
+	var remaining []verifier.VerificationResult
+	for _, result := range results {
+		if !repaired[result.Path] {
+			remaining = append(remaining, result)
+		}
+	}
+	return remaining
+}
+
 func init() {
 	verifyFilesCmd.Flags().IntP("recid", "r", 0, "Record ID (exact match)")
 	verifyFilesCmd.Flags().StringP("doi", "d", "", "Digital Object Identifier (exact match)")
@@ -129,5 +388,24 @@ func init() {
 	verifyFilesCmd.Flags().StringP("input-dir", "i", "", "Input directory containing files to verify")
 	verifyFilesCmd.Flags().StringP("filter-name", "n", "", "Verify files matching exactly the file name")
 	verifyFilesCmd.Flags().StringP("filter-regexp", "e", "", "Verify files matching the regular expression")
+	verifyFilesCmd.Flags().String("filter-range", "", "Verify files from a specified list range (i-j)")
+	verifyFilesCmd.Flags().BoolP("expand", "x", true, "Expand file indexes?")
+	verifyFilesCmd.Flags().Bool("no-expand", false, "Don't expand file indexes")
+	verifyFilesCmd.Flags().StringP("file-availability", "", "", "Filter files by their availability status [online, all]")
+	verifyFilesCmd.Flags().StringArray("index", nil, "Verify only files from the file index with this key, key without extension, or glob (can be repeated)")
+	verifyFilesCmd.Flags().IntP("jobs", "j", 1, "Number of files to hash in parallel (0 for one per CPU)")
+	verifyFilesCmd.Flags().BoolP("progress", "P", false, "Show the bytes hashed and the estimated time left")
+	verifyFilesCmd.Flags().Bool("rehash", false, "Compute all checksums again instead of using the checksum cache")
+	verifyFilesCmd.Flags().StringArrayP("algorithm", "a", nil, "Also compute checksums with this algorithm (adler32|crc32c|md5|sha256, can be repeated)")
+	verifyFilesCmd.Flags().Bool("remote", false, "Compare the metadata checksums with the checksums stored on EOSPUBLIC instead of local files")
+	verifyFilesCmd.Flags().Bool("prune", false, "Remove files below the input directory that do not belong to the record")
+	verifyFilesCmd.Flags().Bool("prune-dry-run", false, "List the files --prune would remove without removing them")
+	verifyFilesCmd.Flags().Bool("yes", false, "Remove the files with --prune without asking for confirmation")
+	verifyFilesCmd.Flags().StringP("format", "m", "text", "Output format (text|json|junit|tap)")
+	verifyFilesCmd.Flags().Bool("repair", false, "Download files that fail verification again and verify them once more")
+	verifyFilesCmd.Flags().String("download-engine", "", "Download engine to use when repairing (http|xrootd)")
+	verifyFilesCmd.Flags().IntP("retry-limit", "y", 10, "Number of retries when downloading a file")
+	verifyFilesCmd.Flags().IntP("retry-sleep", "Y", 5, "Sleep time in seconds before retrying downloads")
 	verifyFilesCmd.Flags().StringP("server", "s", "", "Which CERN Open Data server to query? [default=http://opendata.cern.ch]")
+	addResolveFlags(verifyFilesCmd)
 }
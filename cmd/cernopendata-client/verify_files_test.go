@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/verifier"
+)
+
+func TestVerifyFilesFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		shorthand string
+		defValue  string
+	}{
+		{name: "recid", shorthand: "r", defValue: "0"},
+		{name: "doi", shorthand: "d"},
+		{name: "title", shorthand: "t"},
+		{name: "input-dir", shorthand: "i"},
+		{name: "filter-name", shorthand: "n"},
+		{name: "filter-regexp", shorthand: "e"},
+		{name: "filter-range"},
+		{name: "expand", shorthand: "x", defValue: "true"},
+		{name: "no-expand", defValue: "false"},
+		{name: "file-availability"},
+		{name: "index", defValue: "[]"},
+		{name: "jobs", shorthand: "j", defValue: "1"},
+		{name: "progress", shorthand: "P", defValue: "false"},
+		{name: "rehash", defValue: "false"},
+		{name: "algorithm", shorthand: "a", defValue: "[]"},
+		{name: "remote", defValue: "false"},
+		{name: "prune", defValue: "false"},
+		{name: "prune-dry-run", defValue: "false"},
+		{name: "yes", defValue: "false"},
+		{name: "format", shorthand: "m", defValue: "text"},
+		{name: "repair", defValue: "false"},
+		{name: "download-engine"},
+		{name: "retry-limit", shorthand: "y", defValue: "10"},
+		{name: "retry-sleep", shorthand: "Y", defValue: "5"},
+		{name: "server", shorthand: "s"},
+		{name: "all-matches", defValue: "false"},
+		{name: "pick"},
+		{name: "fuzzy", defValue: "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag := verifyFilesCmd.Flags().Lookup(tt.name)
+			if flag == nil {
+				t.Fatalf("verify-files has no --%s flag", tt.name)
+			}
+			if flag.Shorthand != tt.shorthand {
+				t.Errorf("--%s shorthand = %q, want %q", tt.name, flag.Shorthand, tt.shorthand)
+			}
+			if flag.DefValue != tt.defValue {
+				t.Errorf("--%s default = %q, want %q", tt.name, flag.DefValue, tt.defValue)
+			}
+		})
+	}
+
+	if err := verifyFilesCmd.ParseFlags([]string{"--recid", "5500", "--format", "json"}); err != nil {
+		t.Errorf("ParseFlags() error = %v", err)
+	}
+}
+
+func TestConfirmPrune(t *testing.T) {
+	dir := filepath.Join("data", "5500")
+	extras := []verifier.ExtraFile{
+		{Path: filepath.Join(dir, "notes.txt"), Kind: verifier.Orphan},
+		{Path: filepath.Join(dir, "copy", "a.root"), Kind: verifier.Duplicate},
+	}
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{answer: "y\n", want: true},
+		{answer: "YES\n", want: true},
+		{answer: "n\n", want: false},
+		{answer: "\n", want: false},
+		{answer: "", want: false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := confirmPrune(strings.NewReader(tt.answer), &out, dir, extras); got != tt.want {
+			t.Errorf("confirmPrune(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+		for _, want := range []string{"notes.txt (orphan)", filepath.Join("copy", "a.root") + " (duplicate)", "Remove these 2 files? [y/N]"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("output missing %q:\n%s", want, out.String())
+			}
+		}
+	}
+}
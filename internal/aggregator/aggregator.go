@@ -0,0 +1,310 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/metadater"
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+// None is the group key of records lacking the grouped field.
+const None = "(none)"
+
+// FacetPaths maps search facet names to the metadata paths holding their
+// values. Other group names are used as metadata paths directly.
+var FacetPaths = map[string]string{
+	"experiment":       "experiment",
+	"year":             "date_created",
+	"type":             "type.primary",
+	"subtype":          "type.secondary",
+	"category":         "categories.primary",
+	"collision_type":   "collision_information.type",
+	"collision_energy": "collision_information.energy",
+	"file_type":        "distribution.formats",
+	"keywords":         "keywords",
+}
+
+// Totals are the summed distribution statistics of a set of records.
+type Totals struct {
+	Records      int            `json:"records"`
+	Size         int64          `json:"size"`
+	Files        int64          `json:"files"`
+	Events       int64          `json:"events"`
+	Availability map[string]int `json:"availability"`
+}
+
+func (t *Totals) add(size, files, events int64, availability string) {
+	t.Records++
+	t.Size += size
+	t.Files += files
+	t.Events += events
+	if t.Availability == nil {
+		t.Availability = make(map[string]int)
+	}
+	t.Availability[availability]++
+}
+
+// Group holds the totals of the records sharing the same group values.
+type Group struct {
+	Key []string `json:"key"`
+	Totals
+}
+
+// Aggregator sums the statistics of records, one record at a time.
+type Aggregator struct {
+	GroupBy []string
+	Total   Totals
+	groups  map[string]*Group
+}
+
+// NewAggregator returns an aggregator grouping by the given facets or
+// metadata paths. Without groups only the total is computed.
+func NewAggregator(groupBy []string) *Aggregator {
+	return &Aggregator{GroupBy: groupBy, groups: make(map[string]*Group)}
+}
+
+func toInt64(value any) int64 {
+	switch v := value.(type) {
+	case float64:
+		return int64(v)
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case string:
+		n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n
+	}
+	return 0
+}
+
+// Availability returns whether the files of a record are "online", "on
+// demand" (on tape) or "partial", from the record availability or from the
+// per-state file counts in its distribution.
+func Availability(metadata map[string]any) string {
+	if s, ok := metadata["availability"].(string); ok && s != "" {
+		return s
+	}
+	distribution, _ := metadata["distribution"].(map[string]any)
+	counts, ok := distribution["availability"].(map[string]any)
+	if !ok || len(counts) == 0 {
+		return "unknown"
+	}
+	online, offline := int64(0), int64(0)
+	for state, count := range counts {
+		if state == "online" {
+			online += toInt64(count)
+		} else {
+			offline += toInt64(count)
+		}
+	}
+	switch {
+	case offline == 0:
+		return "online"
+	case online == 0:
+		return "on demand"
+	default:
+		return "partial"
+	}
+}
+
+// groupValues returns the values of a record for one group. Lists count the
+// record once for each of their values.
+func groupValues(metadata map[string]any, group string) []string {
+	path := group
+	if p, ok := FacetPaths[group]; ok {
+		path = p
+	}
+	value, err := metadater.Query(metadata, path)
+	if err != nil || value == nil {
+		return []string{None}
+	}
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+	seen := make(map[string]bool)
+	var values []string
+	for _, item := range items {
+		var s string
+		switch v := item.(type) {
+		case string:
+			s = v
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			data, _ := json.Marshal(v)
+			s = string(data)
+		}
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		values = append(values, s)
+	}
+	if len(values) == 0 {
+		return []string{None}
+	}
+	return values
+}
+
+// Add adds a record to the total and to each of its groups.
+func (a *Aggregator) Add(metadata map[string]any) {
+	distribution, _ := metadata["distribution"].(map[string]any)
+	size := toInt64(distribution["size"])
+	files := toInt64(distribution["number_files"])
+	events := toInt64(distribution["number_events"])
+	availability := Availability(metadata)
+
+	a.Total.add(size, files, events, availability)
+	if len(a.GroupBy) == 0 {
+		return
+	}
+
+	keys := [][]string{nil}
+	for _, group := range a.GroupBy {
+		var next [][]string
+		for _, key := range keys {
+			for _, value := range groupValues(metadata, group) {
+				next = append(next, append(append([]string{}, key...), value))
+			}
+		}
+		keys = next
+	}
+	for _, key := range keys {
+		id := strings.Join(key, "\x00")
+		g, ok := a.groups[id]
+		if !ok {
+			g = &Group{Key: key}
+			a.groups[id] = g
+		}
+		g.add(size, files, events, availability)
+	}
+}
+
+// Groups returns the groups ordered by sortBy: "key" (default), or
+// "records", "size", "files" or "events" in descending order.
+func (a *Aggregator) Groups(sortBy string) ([]Group, error) {
+	groups := make([]Group, 0, len(a.groups))
+	for _, g := range a.groups {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.Join(groups[i].Key, "\x00") < strings.Join(groups[j].Key, "\x00")
+	})
+
+	var metric func(Group) int64
+	switch sortBy {
+	case "", "key":
+		return groups, nil
+	case "records":
+		metric = func(g Group) int64 { return int64(g.Records) }
+	case "size":
+		metric = func(g Group) int64 { return g.Size }
+	case "files":
+		metric = func(g Group) int64 { return g.Files }
+	case "events":
+		metric = func(g Group) int64 { return g.Events }
+	default:
+		return nil, fmt.Errorf("unknown sort order: %s", sortBy)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return metric(groups[i]) > metric(groups[j]) })
+	return groups, nil
+}
+
+// Result is the JSON representation of the statistics.
+type Result struct {
+	GroupBy []string `json:"group_by,omitempty"`
+	Groups  []Group  `json:"groups,omitempty"`
+	Total   Totals   `json:"total"`
+}
+
+// Result returns the statistics with the groups ordered by sortBy.
+func (a *Aggregator) Result(sortBy string) (*Result, error) {
+	groups, err := a.Groups(sortBy)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{GroupBy: a.GroupBy, Groups: groups, Total: a.Total}, nil
+}
+
+// states returns the availability states seen, online first.
+func (r *Result) states() []string {
+	var states []string
+	for state := range r.Total.Availability {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if (states[i] == "online") != (states[j] == "online") {
+			return states[i] == "online"
+		}
+		return states[i] < states[j]
+	})
+	return states
+}
+
+// Table renders the statistics as an aligned text table with a total row.
+// Sizes are shown in human-readable units unless rawBytes is set.
+func (r *Result) Table(rawBytes bool) string {
+	states := r.states()
+	header := append(append([]string{}, r.GroupBy...), "Records", "Files", "Size", "Events")
+	header = append(header, states...)
+
+	row := func(key []string, t Totals) []string {
+		size := utils.FormatBytes(float64(t.Size))
+		if rawBytes {
+			size = strconv.FormatInt(t.Size, 10)
+		}
+		cells := append(append([]string{}, key...), strconv.Itoa(t.Records), strconv.FormatInt(t.Files, 10), size, strconv.FormatInt(t.Events, 10))
+		for _, state := range states {
+			cells = append(cells, strconv.Itoa(t.Availability[state]))
+		}
+		return cells
+	}
+
+	rows := [][]string{header}
+	for _, g := range r.Groups {
+		rows = append(rows, row(g.Key, g.Totals))
+	}
+	totalKey := make([]string, len(r.GroupBy))
+	if len(totalKey) > 0 {
+		totalKey[0] = "Total"
+	}
+	rows = append(rows, row(totalKey, r.Total))
+
+	widths := make([]int, len(header))
+	for _, cells := range rows {
+		for i, cell := range cells {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+
+	var b strings.Builder
+	for n, cells := range rows {
+		if n == len(rows)-1 && len(r.Groups) > 0 {
+			total := 0
+			for _, w := range widths {
+				total += w + 2
+			}
+			b.WriteString(strings.Repeat("-", total-2) + "\n")
+		}
+		var line strings.Builder
+		for i, cell := range cells {
+			pad := strings.Repeat(" ", widths[i]-len([]rune(cell)))
+			if i < len(r.GroupBy) {
+				line.WriteString(cell + pad)
+			} else {
+				line.WriteString(pad + cell)
+			}
+			if i < len(cells)-1 {
+				line.WriteString("  ")
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,148 @@
+package aggregator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRecords() []map[string]any {
+	return []map[string]any{
+		{
+			"recid":        "6004",
+			"experiment":   []any{"CMS"},
+			"date_created": []any{"2012"},
+			"type":         map[string]any{"primary": "Dataset", "secondary": []any{"Collision"}},
+			"distribution": map[string]any{
+				"size": float64(1000), "number_files": float64(10), "number_events": float64(500),
+				"availability": map[string]any{"online": float64(10)},
+			},
+		},
+		{
+			"recid":        "6021",
+			"experiment":   []any{"CMS"},
+			"date_created": []any{"2012"},
+			"type":         map[string]any{"primary": "Dataset", "secondary": []any{"Collision"}},
+			"distribution": map[string]any{
+				"size": "3000", "number_files": "20", "number_events": "1500",
+				"availability": map[string]any{"online": float64(5), "on demand": float64(15)},
+			},
+		},
+		{
+			"recid":        "1",
+			"experiment":   []any{"CMS", "ALICE"},
+			"date_created": []any{"2011"},
+			"type":         map[string]any{"primary": "Software"},
+			"availability": "online",
+		},
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	records := testRecords()
+	want := []string{"online", "partial", "online"}
+	for i, record := range records {
+		if got := Availability(record); got != want[i] {
+			t.Errorf("Availability(record %d) = %q, want %q", i, got, want[i])
+		}
+	}
+	offline := map[string]any{"distribution": map[string]any{"availability": map[string]any{"on demand": float64(3)}}}
+	if got := Availability(offline); got != "on demand" {
+		t.Errorf("Availability(offline) = %q", got)
+	}
+	if got := Availability(map[string]any{}); got != "unknown" {
+		t.Errorf("Availability(empty) = %q", got)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	tests := []struct {
+		name    string
+		groupBy []string
+		sortBy  string
+		want    []Group
+	}{
+		{
+			name:    "year",
+			groupBy: []string{"year"},
+			want: []Group{
+				{Key: []string{"2011"}, Totals: Totals{Records: 1, Availability: map[string]int{"online": 1}}},
+				{Key: []string{"2012"}, Totals: Totals{Records: 2, Size: 4000, Files: 30, Events: 2000, Availability: map[string]int{"online": 1, "partial": 1}}},
+			},
+		},
+		{
+			name:    "list values",
+			groupBy: []string{"experiment"},
+			sortBy:  "records",
+			want: []Group{
+				{Key: []string{"CMS"}, Totals: Totals{Records: 3, Size: 4000, Files: 30, Events: 2000, Availability: map[string]int{"online": 2, "partial": 1}}},
+				{Key: []string{"ALICE"}, Totals: Totals{Records: 1, Availability: map[string]int{"online": 1}}},
+			},
+		},
+		{
+			name:    "path and missing values",
+			groupBy: []string{"type.primary", "subtype"},
+			sortBy:  "size",
+			want: []Group{
+				{Key: []string{"Dataset", "Collision"}, Totals: Totals{Records: 2, Size: 4000, Files: 30, Events: 2000, Availability: map[string]int{"online": 1, "partial": 1}}},
+				{Key: []string{"Software", None}, Totals: Totals{Records: 1, Availability: map[string]int{"online": 1}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := NewAggregator(tt.groupBy)
+			for _, record := range testRecords() {
+				agg.Add(record)
+			}
+			got, err := agg.Groups(tt.sortBy)
+			if err != nil {
+				t.Fatalf("Groups failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Groups() = %+v, want %+v", got, tt.want)
+			}
+			if agg.Total.Records != 3 || agg.Total.Size != 4000 {
+				t.Errorf("Total = %+v", agg.Total)
+			}
+		})
+	}
+
+	if _, err := NewAggregator(nil).Groups("name"); err == nil {
+		t.Error("expected error for unknown sort order")
+	}
+}
+
+func TestTable(t *testing.T) {
+	agg := NewAggregator([]string{"year"})
+	for _, record := range testRecords() {
+		agg.Add(record)
+	}
+	result, _ := agg.Result("key")
+
+	table := result.Table(true)
+	lines := strings.Split(table, "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), table)
+	}
+	for _, want := range []string{
+		"year   Records  Files  Size  Events  online  partial",
+		"2012         2     30  4000    2000       1        1",
+		"Total        3     30  4000    2000       2        1",
+	} {
+		if !strings.Contains(table, want) {
+			t.Errorf("table missing %q:\n%s", want, table)
+		}
+	}
+	if !strings.Contains(result.Table(false), "3.9 KB") {
+		t.Errorf("table should show human-readable sizes:\n%s", result.Table(false))
+	}
+
+	total := NewAggregator(nil)
+	total.Add(testRecords()[0])
+	result, _ = total.Result("")
+	if got := result.Table(true); got != "Records  Files  Size  Events  online\n      1     10  1000     500       1" {
+		t.Errorf("total table = %q", got)
+	}
+}
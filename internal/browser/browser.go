@@ -0,0 +1,676 @@
+package browser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/metadater"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+// Backend is the part of the API client used by the browser.
+// *searcher.Client implements it.
+type Backend interface {
+	SearchRecords(q string, facets map[string]string, page, size int, sort string) (*searcher.SearchResponse, error)
+	GetRecord(recid int) (*searcher.RecordResponse, error)
+	GetFilesList(record *searcher.RecordResponse, protocol string, expand bool) ([]searcher.FileInfo, error)
+	GetFileIndices(record *searcher.RecordResponse, protocol string) ([]searcher.FileIndex, error)
+	GetFileIndexFiles(index searcher.FileIndex, protocol string) ([]searcher.FileInfo, error)
+}
+
+// DownloadFunc downloads the selected files into a directory.
+type DownloadFunc func(files []searcher.FileInfo, dir string) error
+
+// Browser is a line-based interactive browser for records and files. It
+// reads one command per line from its input, so it works the same on a
+// terminal and with commands piped in from a script or a test. It also
+// holds the state of the full-screen TUI.
+type Browser struct {
+	// Interactive enables the prompt and the help hint at start. It is
+	// meant to be set when the input is a terminal.
+	Interactive bool
+	PageSize    int
+	Protocol    string
+	OnDownload  DownloadFunc
+
+	backend Backend
+	in      *bufio.Scanner
+	out     io.Writer
+
+	query   string
+	facets  map[string]string
+	page    int
+	total   int
+	results []searcher.SearchHit
+
+	record   *searcher.RecordResponse
+	recid    int
+	indices  []searcher.FileIndex
+	files    []searcher.FileInfo
+	filesOf  string
+	selected []searcher.FileInfo
+	chosen   map[string]bool
+	failures int
+}
+
+// New returns a browser reading commands from in and writing to out.
+func New(backend Backend, in io.Reader, out io.Writer) *Browser {
+	return &Browser{
+		PageSize: 10,
+		Protocol: "http",
+		backend:  backend,
+		in:       bufio.NewScanner(in),
+		out:      out,
+		facets:   make(map[string]string),
+		page:     1,
+		chosen:   make(map[string]bool),
+	}
+}
+
+// IsTerminal reports whether f is a character device such as a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+const help = `Commands:
+  search <pattern>       search records (also: /<pattern>)
+  facet <key>=<value>    add a facet filter; facet -<key> removes it
+  facets                 list the available facets and their values
+  clear                  remove the search pattern and all facet filters
+  next, prev             show the next or previous page of results
+  open <n>               open search result n (also: just <n>)
+  record <recid>         open a record by its record ID
+  meta [path]            show the metadata of the record, or one field
+  files                  list the files of the record and its file indexes
+  index <n>              list the files of file index n
+  select <list>          select files, e.g. 1-3,7 or all
+  unselect <list>        unselect files from the current list
+  selection              show the selected files
+  download [dir]         download the selected files
+  back                   go back to the search results
+  help                   show this help
+  quit                   leave the browser`
+
+func (b *Browser) printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(b.out, format, args...)
+}
+
+// Run executes commands until quit or the end of the input. In
+// non-interactive mode it returns an error if any command failed.
+func (b *Browser) Run() error {
+	if b.Interactive {
+		b.printf("CERN Open Data browser. Type 'help' for the list of commands.\n")
+	}
+	for {
+		if b.Interactive {
+			b.printf("browse> ")
+		}
+		if !b.in.Scan() {
+			if b.Interactive {
+				b.printf("\n")
+			}
+			break
+		}
+		quit, err := b.Execute(b.in.Text())
+		if err != nil {
+			b.failures++
+			b.printf("error: %v\n", err)
+		}
+		if quit {
+			break
+		}
+	}
+	if err := b.in.Err(); err != nil {
+		return err
+	}
+	if !b.Interactive && b.failures > 0 {
+		return fmt.Errorf("%d command(s) failed", b.failures)
+	}
+	return nil
+}
+
+// Search runs a search with the given pattern and facet filters, as the
+// starting point of a session.
+func (b *Browser) Search(pattern string, facets map[string]string) error {
+	for key, value := range facets {
+		b.facets[key] = value
+	}
+	return b.search(pattern)
+}
+
+// Execute runs one command line. It reports whether the browser should quit.
+func (b *Browser) Execute(line string) (bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return false, nil
+	}
+	if strings.HasPrefix(line, "/") {
+		return false, b.search(strings.TrimSpace(line[1:]))
+	}
+	if n, err := strconv.Atoi(line); err == nil {
+		return false, b.open(n)
+	}
+
+	command, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch command {
+	case "quit", "exit", "q":
+		return true, nil
+	case "help", "?":
+		b.printf("%s\n", help)
+		return false, nil
+	case "search", "s":
+		return false, b.search(arg)
+	case "facet", "f":
+		return false, b.facet(arg)
+	case "facets":
+		return false, b.listFacets()
+	case "clear":
+		b.query = ""
+		b.facets = make(map[string]string)
+		b.results = nil
+		b.total = 0
+		b.printf("Search cleared.\n")
+		return false, nil
+	case "next", "n":
+		return false, b.turnPage(1)
+	case "prev", "p":
+		return false, b.turnPage(-1)
+	case "open", "o":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return false, fmt.Errorf("usage: open <n>")
+		}
+		return false, b.open(n)
+	case "record", "r":
+		recid, err := strconv.Atoi(arg)
+		if err != nil {
+			return false, fmt.Errorf("usage: record <recid>")
+		}
+		return false, b.openRecord(recid)
+	case "meta", "m":
+		return false, b.meta(arg)
+	case "files":
+		return false, b.listFiles()
+	case "index", "i":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return false, fmt.Errorf("usage: index <n>")
+		}
+		return false, b.openIndex(n)
+	case "select", "sel":
+		return false, b.selectFiles(arg, true)
+	case "unselect", "unsel":
+		return false, b.selectFiles(arg, false)
+	case "selection":
+		b.showSelection()
+		return false, nil
+	case "download", "d":
+		return false, b.download(arg)
+	case "back", "b":
+		b.record = nil
+		b.files = nil
+		b.showResults()
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown command %q (type 'help' for the list of commands)", command)
+	}
+}
+
+func (b *Browser) search(pattern string) error {
+	b.query = pattern
+	b.page = 1
+	return b.runSearch()
+}
+
+func (b *Browser) runSearch() error {
+	resp, err := b.backend.SearchRecords(b.query, b.facets, b.page, b.PageSize, "")
+	if err != nil {
+		return err
+	}
+	b.total = resp.Hits.Total
+	b.results = resp.Hits.Hits
+	b.showResults()
+	return nil
+}
+
+func (b *Browser) facet(arg string) error {
+	if strings.HasPrefix(arg, "-") {
+		key := strings.TrimPrefix(arg, "-")
+		if _, ok := b.facets[key]; !ok {
+			return fmt.Errorf("no facet filter %q", key)
+		}
+		delete(b.facets, key)
+	} else {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("usage: facet <key>=<value> or facet -<key>")
+		}
+		b.facets[key] = value
+	}
+	b.page = 1
+	return b.runSearch()
+}
+
+func (b *Browser) listFacets() error {
+	values, err := b.loadFacets()
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		if i == 0 || value.name != values[i-1].name {
+			b.printf("%s:\n", value.name)
+		}
+		b.printf("  %s (%d)\n", value.value, value.count)
+	}
+	return nil
+}
+
+// facetValue is one value of a facet with the number of matching records.
+type facetValue struct {
+	name  string
+	value string
+	count int
+}
+
+// loadFacets returns the values of the facets available for the current
+// search, sorted by facet name.
+func (b *Browser) loadFacets() ([]facetValue, error) {
+	resp, err := b.backend.SearchRecords(b.query, b.facets, 1, 1, "")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(resp.Aggregations))
+	for name := range resp.Aggregations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var values []facetValue
+	for _, name := range names {
+		for _, bucket := range resp.Aggregations[name].Buckets {
+			values = append(values, facetValue{name: name, value: fmt.Sprint(bucket.Key), count: bucket.DocCount})
+		}
+	}
+	return values, nil
+}
+
+func (b *Browser) turnPage(delta int) error {
+	if b.results == nil {
+		return fmt.Errorf("no search results, use 'search <pattern>' first")
+	}
+	page := b.page + delta
+	if page < 1 || (page-1)*b.PageSize >= b.total {
+		return fmt.Errorf("no more results")
+	}
+	b.page = page
+	return b.runSearch()
+}
+
+func (b *Browser) describeSearch() string {
+	desc := fmt.Sprintf("%q", b.query)
+	if len(b.facets) > 0 {
+		keys := make([]string, 0, len(b.facets))
+		for key := range b.facets {
+			keys = append(keys, key+"="+b.facets[key])
+		}
+		sort.Strings(keys)
+		desc += " [" + strings.Join(keys, ", ") + "]"
+	}
+	return desc
+}
+
+func (b *Browser) showResults() {
+	if b.results == nil {
+		b.printf("No search yet. Use 'search <pattern>' to find records.\n")
+		return
+	}
+	pages := (b.total + b.PageSize - 1) / b.PageSize
+	b.printf("Search %s: %d records, page %d/%d\n", b.describeSearch(), b.total, b.page, max(pages, 1))
+	for i, hit := range b.results {
+		title, _ := hit.Metadata["title"].(string)
+		b.printf("%4d  %-7s %s%s\n", i+1, hit.ID, title, typeSuffix(hit.Metadata))
+	}
+}
+
+func typeSuffix(metadata map[string]any) string {
+	recordType, ok := metadata["type"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	primary, _ := recordType["primary"].(string)
+	if primary == "" {
+		return ""
+	}
+	return " [" + primary + "]"
+}
+
+func (b *Browser) open(n int) error {
+	if n < 1 || n > len(b.results) {
+		return fmt.Errorf("no search result %d", n)
+	}
+	recid, err := strconv.Atoi(b.results[n-1].ID)
+	if err != nil {
+		return fmt.Errorf("invalid record ID %q", b.results[n-1].ID)
+	}
+	return b.openRecord(recid)
+}
+
+func (b *Browser) openRecord(recid int) error {
+	if err := b.loadRecord(recid); err != nil {
+		return err
+	}
+	for _, line := range b.recordSummary() {
+		b.printf("%s\n", line)
+	}
+	return nil
+}
+
+// loadRecord fetches a record and its file indexes and makes it the open
+// record.
+func (b *Browser) loadRecord(recid int) error {
+	record, err := b.backend.GetRecord(recid)
+	if err != nil {
+		return err
+	}
+	indices, err := b.backend.GetFileIndices(record, b.Protocol)
+	if err != nil {
+		return err
+	}
+	b.record = record
+	b.recid = recid
+	b.indices = indices
+	b.files = nil
+	return nil
+}
+
+// recordSummary describes the open record in a few lines.
+func (b *Browser) recordSummary() []string {
+	title, _ := b.record.Metadata["title"].(string)
+	lines := []string{fmt.Sprintf("Record %d: %s%s", b.recid, title, typeSuffix(b.record.Metadata))}
+	for _, field := range []struct{ label, path string }{
+		{"Experiment", "experiment"},
+		{"DOI", "doi"},
+		{"Year", "date_created"},
+		{"Files", "distribution.number_files"},
+		{"Events", "distribution.number_events"},
+	} {
+		if value, err := metadater.Query(b.record.Metadata, field.path); err == nil && value != nil {
+			lines = append(lines, fmt.Sprintf("  %-11s %s", field.label+":", scalar(value)))
+		}
+	}
+	if value, err := metadater.Query(b.record.Metadata, "distribution.size"); err == nil {
+		if size, ok := value.(float64); ok {
+			lines = append(lines, fmt.Sprintf("  %-11s %s", "Size:", utils.FormatBytes(size)))
+		}
+	}
+	return append(lines, fmt.Sprintf("  %-11s %d", "Indexes:", len(b.indices)))
+}
+
+func scalar(value any) string {
+	switch v := value.(type) {
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = scalar(item)
+		}
+		return strings.Join(parts, ", ")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (b *Browser) requireRecord() error {
+	if b.record == nil {
+		return fmt.Errorf("no record open, use 'open <n>' or 'record <recid>' first")
+	}
+	return nil
+}
+
+func (b *Browser) meta(path string) error {
+	if err := b.requireRecord(); err != nil {
+		return err
+	}
+	var data any = b.record.Metadata
+	if path != "" {
+		var err error
+		data, err = metadater.Query(b.record.Metadata, path)
+		if err != nil {
+			return err
+		}
+	}
+	output, err := metadater.FormatTree(data)
+	if err != nil {
+		return err
+	}
+	b.printf("%s\n", output)
+	return nil
+}
+
+func (b *Browser) listFiles() error {
+	if err := b.loadRecordFiles(); err != nil {
+		return err
+	}
+	b.showFiles()
+	if len(b.indices) > 0 {
+		b.printf("File indexes:\n")
+		for i, index := range b.indices {
+			b.printf("%4d  %s\n", i+1, indexLine(index))
+		}
+	}
+	return nil
+}
+
+// loadRecordFiles makes the files of the open record the current file list.
+func (b *Browser) loadRecordFiles() error {
+	if err := b.requireRecord(); err != nil {
+		return err
+	}
+	files, err := b.backend.GetFilesList(b.record, b.Protocol, false)
+	if err != nil {
+		return err
+	}
+	b.files = files
+	b.filesOf = fmt.Sprintf("record %d", b.recid)
+	return nil
+}
+
+func indexLine(index searcher.FileIndex) string {
+	return fmt.Sprintf("%6d files  %10s  %s", index.NumberFiles, utils.FormatBytes(float64(index.FilesSize)), index.Key)
+}
+
+func (b *Browser) openIndex(n int) error {
+	if err := b.loadIndexFiles(n); err != nil {
+		return err
+	}
+	b.showFiles()
+	return nil
+}
+
+// loadIndexFiles makes the files of file index n of the open record the
+// current file list.
+func (b *Browser) loadIndexFiles(n int) error {
+	if err := b.requireRecord(); err != nil {
+		return err
+	}
+	if n < 1 || n > len(b.indices) {
+		return fmt.Errorf("no file index %d", n)
+	}
+	index := b.indices[n-1]
+	files := index.Files
+	if len(files) == 0 {
+		var err error
+		files, err = b.backend.GetFileIndexFiles(index, b.Protocol)
+		if err != nil {
+			return err
+		}
+	}
+	b.files = files
+	b.filesOf = "file index " + index.Key
+	return nil
+}
+
+func (b *Browser) isSelected(file searcher.FileInfo) bool {
+	return b.chosen[file.URI]
+}
+
+// fileLine shows whether file is selected, its size, its availability
+// and its name.
+func (b *Browser) fileLine(file searcher.FileInfo) string {
+	mark := "[ ]"
+	if b.isSelected(file) {
+		mark = "[x]"
+	}
+	availability := file.Availability
+	if availability == "" {
+		availability = "online"
+	}
+	return fmt.Sprintf("%s %10s  %-9s  %s", mark, utils.FormatBytes(float64(file.Size)), availability, filepath.Base(file.URI))
+}
+
+func (b *Browser) showFiles() {
+	b.printf("Files of %s: %d\n", b.filesOf, len(b.files))
+	for i, file := range b.files {
+		b.printf("%4d %s\n", i+1, b.fileLine(file))
+	}
+}
+
+// parseList parses a list of 1-based positions such as "1-3,7" or "all".
+func parseList(list string, n int) ([]int, error) {
+	if list == "all" || list == "*" {
+		positions := make([]int, n)
+		for i := range positions {
+			positions[i] = i + 1
+		}
+		return positions, nil
+	}
+	var positions []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil {
+				return nil, fmt.Errorf("invalid selection %q", part)
+			}
+		}
+		if start < 1 || end > n || start > end {
+			return nil, fmt.Errorf("selection %q is out of range 1-%d", part, n)
+		}
+		for i := start; i <= end; i++ {
+			positions = append(positions, i)
+		}
+	}
+	return positions, nil
+}
+
+func (b *Browser) selectFiles(list string, selected bool) error {
+	if b.files == nil {
+		return fmt.Errorf("no file list, use 'files' or 'index <n>' first")
+	}
+	if list == "" {
+		return fmt.Errorf("usage: select <list>, e.g. 1-3,7 or all")
+	}
+	positions, err := parseList(list, len(b.files))
+	if err != nil {
+		return err
+	}
+	files := make([]searcher.FileInfo, len(positions))
+	for i, pos := range positions {
+		files[i] = b.files[pos-1]
+	}
+	b.setSelected(files, selected)
+	b.printf("%s\n", b.selectionSummary())
+	return nil
+}
+
+// setSelected adds files to or removes them from the selection, which
+// keeps the order in which files were selected.
+func (b *Browser) setSelected(files []searcher.FileInfo, selected bool) {
+	removed := false
+	for _, file := range files {
+		if b.chosen[file.URI] == selected {
+			continue
+		}
+		if selected {
+			b.chosen[file.URI] = true
+			b.selected = append(b.selected, file)
+		} else {
+			delete(b.chosen, file.URI)
+			removed = true
+		}
+	}
+	if removed {
+		kept := b.selected[:0]
+		for _, file := range b.selected {
+			if b.chosen[file.URI] {
+				kept = append(kept, file)
+			}
+		}
+		b.selected = kept
+	}
+}
+
+func (b *Browser) selectionSummary() string {
+	var size int64
+	offline := 0
+	for _, file := range b.selected {
+		size += file.Size
+		if file.Availability != "" && file.Availability != "online" {
+			offline++
+		}
+	}
+	summary := fmt.Sprintf("Selected: %d files, %s", len(b.selected), utils.FormatBytes(float64(size)))
+	if offline > 0 {
+		summary += fmt.Sprintf(" (%d on tape)", offline)
+	}
+	return summary
+}
+
+func (b *Browser) showSelection() {
+	for i, file := range b.selected {
+		b.printf("%4d %10s  %s\n", i+1, utils.FormatBytes(float64(file.Size)), file.URI)
+	}
+	b.printf("%s\n", b.selectionSummary())
+}
+
+func (b *Browser) download(dir string) error {
+	if len(b.selected) == 0 {
+		return fmt.Errorf("no files selected")
+	}
+	if b.OnDownload == nil {
+		return fmt.Errorf("downloading is not available")
+	}
+	if dir == "" {
+		dir = b.downloadDir()
+	}
+	if err := b.OnDownload(b.selected, dir); err != nil {
+		return err
+	}
+	b.selected = nil
+	b.chosen = make(map[string]bool)
+	return nil
+}
+
+// downloadDir is the default download directory: the record ID of the open
+// record, or the current directory.
+func (b *Browser) downloadDir() string {
+	if b.record != nil {
+		return strconv.Itoa(b.recid)
+	}
+	return "."
+}
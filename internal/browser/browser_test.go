@@ -0,0 +1,240 @@
+package browser
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+type fakeBackend struct {
+	searches []string
+}
+
+func (f *fakeBackend) SearchRecords(q string, facets map[string]string, page, size int, sort string) (*searcher.SearchResponse, error) {
+	f.searches = append(f.searches, fmt.Sprintf("%s %v page=%d", q, facets, page))
+	if q == "fail" {
+		return nil, fmt.Errorf("search failed")
+	}
+	var hits []searcher.SearchHit
+	for i := (page-1)*size + 1; i <= min(page*size, 3); i++ {
+		hits = append(hits, searcher.SearchHit{
+			ID:       fmt.Sprintf("%d", 6000+i),
+			Metadata: map[string]any{"title": fmt.Sprintf("Dataset %d", i), "type": map[string]any{"primary": "Dataset"}},
+		})
+	}
+	return &searcher.SearchResponse{
+		Hits: searcher.SearchHits{Total: 3, Hits: hits},
+		Aggregations: map[string]searcher.Aggregation{
+			"experiment": {Buckets: []searcher.AggregationBucket{{Key: "CMS", DocCount: 3}}},
+		},
+	}, nil
+}
+
+func (f *fakeBackend) GetRecord(recid int) (*searcher.RecordResponse, error) {
+	if recid == 404 {
+		return nil, fmt.Errorf("record %d not found", recid)
+	}
+	return &searcher.RecordResponse{ID: fmt.Sprintf("%d", recid), Metadata: map[string]any{
+		"recid":        fmt.Sprintf("%d", recid),
+		"title":        fmt.Sprintf("Dataset %d", recid),
+		"experiment":   []any{"CMS"},
+		"distribution": map[string]any{"number_files": float64(3), "size": float64(3072)},
+	}}, nil
+}
+
+func (f *fakeBackend) GetFilesList(record *searcher.RecordResponse, protocol string, expand bool) ([]searcher.FileInfo, error) {
+	return []searcher.FileInfo{{URI: "http://server/record/6001/files/index.txt", Size: 100}}, nil
+}
+
+func (f *fakeBackend) GetFileIndices(record *searcher.RecordResponse, protocol string) ([]searcher.FileIndex, error) {
+	return []searcher.FileIndex{
+		{Key: "index.txt", NumberFiles: 2, FilesSize: 3000, Files: []searcher.FileInfo{
+			{URI: "http://server/eos/a.root", Size: 1000, Availability: "online"},
+			{URI: "http://server/eos/b.root", Size: 2000, Availability: "on demand"},
+		}},
+		{Key: "remote.txt", URI: "http://server/record/6001/file_index/remote.txt"},
+	}, nil
+}
+
+func (f *fakeBackend) GetFileIndexFiles(index searcher.FileIndex, protocol string) ([]searcher.FileInfo, error) {
+	return []searcher.FileInfo{{URI: "http://server/eos/c.root", Size: 500}}, nil
+}
+
+func run(t *testing.T, backend Backend, script string, onDownload DownloadFunc) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	b := New(backend, strings.NewReader(script), &out)
+	b.PageSize = 2
+	b.OnDownload = onDownload
+	err := b.Run()
+	return out.String(), err
+}
+
+func TestBrowseSearch(t *testing.T) {
+	backend := &fakeBackend{}
+	out, err := run(t, backend, "search muon\nfacet experiment=CMS\nnext\nprev\nfacet -experiment\nfacets\n", nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		`Search "muon": 3 records, page 1/2`,
+		"   1  6001    Dataset 1 [Dataset]",
+		`Search "muon" [experiment=CMS]: 3 records, page 2/2`,
+		"   1  6003    Dataset 3 [Dataset]",
+		"experiment:\n  CMS (3)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	wantSearches := []string{
+		"muon map[] page=1",
+		"muon map[experiment:CMS] page=1",
+		"muon map[experiment:CMS] page=2",
+		"muon map[experiment:CMS] page=1",
+		"muon map[] page=1",
+		"muon map[] page=1",
+	}
+	if !reflect.DeepEqual(backend.searches, wantSearches) {
+		t.Errorf("searches = %q, want %q", backend.searches, wantSearches)
+	}
+}
+
+func TestBrowseSelectAndDownload(t *testing.T) {
+	var downloaded []searcher.FileInfo
+	var downloadDir string
+	onDownload := func(files []searcher.FileInfo, dir string) error {
+		downloaded = files
+		downloadDir = dir
+		return nil
+	}
+
+	script := strings.Join([]string{
+		"/muon",
+		"2",
+		"meta experiment",
+		"files",
+		"index 1",
+		"select all",
+		"unselect 1",
+		"index 2",
+		"select 1",
+		"selection",
+		"download",
+		"quit",
+		"select 1",
+	}, "\n")
+	out, err := run(t, &fakeBackend{}, script, onDownload)
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out)
+	}
+
+	for _, want := range []string{
+		"Record 6002: Dataset 6002",
+		"  Size:       3.0 KB",
+		"  Indexes:    2",
+		"CMS",
+		"Files of record 6002: 1",
+		"   1       2 files      2.9 KB  index.txt",
+		"Files of file index index.txt: 2",
+		"   2 [ ]     2.0 KB  on demand  b.root",
+		"Selected: 2 files, 2.9 KB (1 on tape)",
+		"Selected: 1 files, 2.0 KB (1 on tape)",
+		"Files of file index remote.txt: 1",
+		"Selected: 2 files, 2.4 KB (1 on tape)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if downloadDir != "6002" || len(downloaded) != 2 || downloaded[0].URI != "http://server/eos/b.root" || downloaded[1].URI != "http://server/eos/c.root" {
+		t.Errorf("downloaded %v into %q", downloaded, downloadDir)
+	}
+}
+
+func TestBrowseErrors(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "open 1", want: "no search result 1"},
+		{command: "meta", want: "no record open"},
+		{command: "select 1", want: "no file list"},
+		{command: "download", want: "no files selected"},
+		{command: "next", want: "no search results"},
+		{command: "record 404", want: "record 404 not found"},
+		{command: "search fail", want: "search failed"},
+		{command: "frobnicate", want: `unknown command "frobnicate"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			out, err := run(t, &fakeBackend{}, tt.command+"\n", nil)
+			if err == nil {
+				t.Error("expected Run to report the failed command")
+			}
+			if !strings.Contains(out, "error: "+tt.want) {
+				t.Errorf("output missing %q:\n%s", tt.want, out)
+			}
+		})
+	}
+}
+
+func TestBrowseInteractive(t *testing.T) {
+	var out bytes.Buffer
+	b := New(&fakeBackend{}, strings.NewReader("frobnicate\nhelp\n"), &out)
+	b.Interactive = true
+	if err := b.Run(); err != nil {
+		t.Errorf("interactive Run should not fail on command errors: %v", err)
+	}
+	if !strings.Contains(out.String(), "browse> ") || !strings.Contains(out.String(), "download [dir]") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "all", want: []int{1, 2, 3, 4}},
+		{list: "2", want: []int{2}},
+		{list: "1-2, 4", want: []int{1, 2, 4}},
+		{list: "3-5", wantErr: true},
+		{list: "0", wantErr: true},
+		{list: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseList(tt.list, 4)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseList(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseList(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestBrowseInitialSearch(t *testing.T) {
+	backend := &fakeBackend{}
+	var out bytes.Buffer
+	b := New(backend, strings.NewReader("next\n"), &out)
+	b.PageSize = 2
+	if err := b.Search("muon", map[string]string{"experiment": "CMS"}); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if err := b.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := []string{"muon map[experiment:CMS] page=1", "muon map[experiment:CMS] page=2"}
+	if !reflect.DeepEqual(backend.searches, want) {
+		t.Errorf("searches = %q, want %q", backend.searches, want)
+	}
+}
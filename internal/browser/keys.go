@@ -0,0 +1,91 @@
+package browser
+
+import (
+	"bufio"
+	"unicode/utf8"
+)
+
+// Key is a key pressed on the terminal. Printable characters, including
+// the space, are the character itself; other keys have the names below.
+// Keys the TUI does not know are read as the empty key.
+type Key string
+
+const (
+	KeyUp        Key = "up"
+	KeyDown      Key = "down"
+	KeyLeft      Key = "left"
+	KeyRight     Key = "right"
+	KeyPageUp    Key = "pgup"
+	KeyPageDown  Key = "pgdown"
+	KeyHome      Key = "home"
+	KeyEnd       Key = "end"
+	KeyEnter     Key = "enter"
+	KeyEscape    Key = "esc"
+	KeyBackspace Key = "backspace"
+	KeyInterrupt Key = "ctrl-c"
+)
+
+// escapeKeys maps the final part of the escape sequences sent by terminals
+// in raw mode to keys.
+var escapeKeys = map[string]Key{
+	"A": KeyUp, "B": KeyDown, "C": KeyRight, "D": KeyLeft,
+	"H": KeyHome, "F": KeyEnd, "1~": KeyHome, "7~": KeyHome, "4~": KeyEnd, "8~": KeyEnd,
+	"5~": KeyPageUp, "6~": KeyPageDown,
+}
+
+// ReadKey reads one key from the input of a terminal in raw mode.
+func ReadKey(r *bufio.Reader) (Key, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case c == 0x1b:
+		return readEscape(r)
+	case c == '\r' || c == '\n':
+		return KeyEnter, nil
+	case c == 0x7f || c == 0x08:
+		return KeyBackspace, nil
+	case c == 0x03 || c == 0x04:
+		return KeyInterrupt, nil
+	case c < 0x20:
+		return "", nil
+	case c < utf8.RuneSelf:
+		return Key(c), nil
+	}
+	if err := r.UnreadByte(); err != nil {
+		return "", err
+	}
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	return Key(ch), nil
+}
+
+// readEscape reads the rest of an escape sequence. An escape byte that is
+// not followed by a sequence in the same read is the escape key.
+func readEscape(r *bufio.Reader) (Key, error) {
+	if r.Buffered() == 0 {
+		return KeyEscape, nil
+	}
+	if next, err := r.Peek(1); err != nil || (next[0] != '[' && next[0] != 'O') {
+		return KeyEscape, nil
+	}
+	_, _ = r.ReadByte()
+
+	var seq []byte
+	for r.Buffered() > 0 {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		seq = append(seq, c)
+		// Parameters are digits and ';', the sequence ends with the first
+		// other byte.
+		if (c < '0' || c > '9') && c != ';' {
+			break
+		}
+	}
+	return escapeKeys[string(seq)], nil
+}
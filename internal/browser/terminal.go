@@ -0,0 +1,64 @@
+package browser
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	// enterScreen switches to the alternate screen and hides the cursor,
+	// leaveScreen undoes it.
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// RunTerminal runs t full screen on the terminal with input in and output
+// out, until it is quit. The terminal is put in raw mode and restored on
+// return, as well as during downloads.
+func RunTerminal(t *TUI, in, out *os.File) error {
+	fd := int(in.Fd()) // #nosec G115
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	_, _ = fmt.Fprint(out, enterScreen)
+	defer func() {
+		_, _ = fmt.Fprint(out, leaveScreen)
+		_ = term.Restore(fd, state)
+	}()
+
+	t.Suspend = func(download func() error) error {
+		_, _ = fmt.Fprint(out, leaveScreen)
+		_ = term.Restore(fd, state)
+		err := download()
+		_, _ = fmt.Fprint(out, "Press Enter to return to the browser.")
+		t.waitForEnter()
+		if _, rawErr := term.MakeRaw(fd); rawErr != nil && err == nil {
+			err = rawErr
+		}
+		_, _ = fmt.Fprint(out, enterScreen)
+		return err
+	}
+
+	size := func() (int, int) {
+		width, height, err := term.GetSize(int(out.Fd())) // #nosec G115
+		if err != nil {
+			return 80, 24
+		}
+		return width, height
+	}
+	return t.Run(in, out, size)
+}
+
+// waitForEnter reads the input of the terminal in normal mode up to the
+// end of the line.
+func (t *TUI) waitForEnter() {
+	for {
+		c, err := t.keys.ReadByte()
+		if err != nil || c == '\n' {
+			return
+		}
+	}
+}
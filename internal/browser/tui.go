@@ -0,0 +1,576 @@
+package browser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/metadater"
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+// screen is one of the views of the TUI.
+type screen int
+
+const (
+	screenResults screen = iota
+	screenFacets
+	screenRecord
+	screenMeta
+	screenFiles
+	screenSelection
+	screenHelp
+)
+
+// hints lists the main keys of each screen.
+var hints = map[screen]string{
+	screenResults:   "enter open  / search  f facets  c clear  s selection  ? help  q quit",
+	screenFacets:    "enter add or remove filter  esc back",
+	screenRecord:    "enter list files  m metadata  s selection  esc back",
+	screenMeta:      "up/down scroll  esc back",
+	screenFiles:     "space select  a all  u none  d download  s selection  esc back",
+	screenSelection: "space unselect  u unselect all  d download  esc back",
+	screenHelp:      "esc back",
+}
+
+var tuiHelp = strings.Split(`up, down, k, j          move the cursor
+page up, page down      move the cursor by a screen
+home, end, g, G         go to the first or last line
+enter, right, l         open the result, file list or facet under the cursor
+esc, left, h            go back to the previous screen
+/                       search records
+f                       show the facets of the search, to filter by them
+c                       remove the search pattern and all facet filters
+m                       show the metadata of the open record
+space, x                select or unselect the file under the cursor
+a, u                    select or unselect all files of the list
+s                       show the selected files
+d                       download the selected files
+?                       show this help
+q, ctrl-c               leave the browser`, "\n")
+
+// prompt is a line of text being entered at the bottom of the screen.
+type prompt struct {
+	label string
+	text  []rune
+	done  func(text string) error
+}
+
+// TUI is a full-screen browser for terminals. It works on the state of a
+// Browser and is driven one key at a time: Run reads the keys and redraws
+// the screen after each of them, and RunTerminal connects it to a terminal.
+type TUI struct {
+	// Suspend, when set, runs a download. It is meant to switch the
+	// terminal back to normal mode, so that the progress of the download
+	// is shown.
+	Suspend func(download func() error) error
+
+	b           *Browser
+	keys        *bufio.Reader
+	screen      screen
+	history     []screen
+	cursor      map[screen]int
+	offset      map[screen]int
+	rows        int
+	searched    bool
+	hits        []searcher.SearchHit
+	facetValues []facetValue
+	meta        []string
+	prompt      *prompt
+	message     string
+}
+
+// NewTUI returns a TUI on the state of b.
+func NewTUI(b *Browser) *TUI {
+	return &TUI{
+		b:      b,
+		cursor: make(map[screen]int),
+		offset: make(map[screen]int),
+		rows:   10,
+	}
+}
+
+// Search runs a search with the given pattern and facet filters, as the
+// starting point of a session.
+func (t *TUI) Search(pattern string, facets map[string]string) error {
+	for key, value := range facets {
+		t.b.facets[key] = value
+	}
+	t.b.query = pattern
+	return t.search()
+}
+
+// Run draws the TUI on out and handles the keys read from in until it is
+// quit or the input ends. size returns the width and height of the screen.
+func (t *TUI) Run(in io.Reader, out io.Writer, size func() (int, int)) error {
+	t.keys = bufio.NewReader(in)
+	for {
+		t.draw(out, size)
+		key, err := ReadKey(t.keys)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if t.HandleKey(key) {
+			return nil
+		}
+	}
+}
+
+func (t *TUI) draw(out io.Writer, size func() (int, int)) {
+	width, height := size()
+	var sb strings.Builder
+	sb.WriteString("\x1b[H")
+	for i, line := range t.View(width, height) {
+		if i > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(line)
+		sb.WriteString("\x1b[K")
+	}
+	sb.WriteString("\x1b[J")
+	_, _ = io.WriteString(out, sb.String())
+}
+
+// HandleKey handles one key. It reports whether the browser should quit.
+func (t *TUI) HandleKey(key Key) bool {
+	if t.prompt != nil {
+		t.edit(key)
+		return false
+	}
+	if key == "" {
+		return false
+	}
+
+	t.message = ""
+	var err error
+	switch key {
+	case KeyInterrupt, "q":
+		return true
+	case KeyUp, "k":
+		t.move(-1)
+	case KeyDown, "j":
+		t.move(1)
+	case KeyPageUp:
+		t.move(-t.rows)
+	case KeyPageDown:
+		t.move(t.rows)
+	case KeyHome, "g":
+		t.move(-t.count())
+	case KeyEnd, "G":
+		t.move(t.count())
+	case KeyEnter, KeyRight, "l":
+		err = t.enter()
+	case KeyEscape, KeyBackspace, KeyLeft, "h":
+		t.back()
+	case "/":
+		t.ask("Search", t.b.query, func(text string) error {
+			t.b.query = strings.TrimSpace(text)
+			return t.search()
+		})
+	case "f":
+		err = t.openFacets()
+	case "c":
+		t.b.query = ""
+		t.b.facets = make(map[string]string)
+		t.b.total = 0
+		t.hits = nil
+		t.searched = false
+		t.home()
+	case "m":
+		err = t.openMeta()
+	case " ", "x":
+		err = t.toggle()
+	case "a":
+		err = t.selectAll(true)
+	case "u":
+		err = t.selectAll(false)
+	case "s":
+		t.open(screenSelection)
+	case "d":
+		err = t.askDownload()
+	case "?":
+		t.open(screenHelp)
+	}
+	if err != nil {
+		t.message = "error: " + err.Error()
+	}
+	return false
+}
+
+// View returns the lines of the screen for the given terminal size: a
+// header, the scrolled list of the screen, a status line and the main keys.
+func (t *TUI) View(width, height int) []string {
+	header := t.header()
+	t.rows = max(height-len(header)-2, 1)
+
+	items := t.items()
+	cursor := t.cursor[t.screen]
+	offset := t.offset[t.screen]
+	if t.isText() {
+		offset = cursor
+	} else {
+		offset = min(offset, cursor)
+		if cursor >= offset+t.rows {
+			offset = cursor - t.rows + 1
+		}
+	}
+	t.offset[t.screen] = offset
+
+	lines := header
+	for i := offset; i < offset+t.rows; i++ {
+		line := ""
+		switch {
+		case i >= len(items):
+		case t.isText():
+			line = items[i]
+		case i == cursor:
+			line = "> " + items[i]
+		default:
+			line = "  " + items[i]
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, t.status(), hints[t.screen])
+
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return lines
+}
+
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if width <= 0 || len(runes) <= width {
+		return line
+	}
+	return string(runes[:width])
+}
+
+// isText reports whether the screen shows text that is scrolled, rather
+// than a list with a cursor.
+func (t *TUI) isText() bool {
+	return t.screen == screenMeta || t.screen == screenHelp
+}
+
+func (t *TUI) header() []string {
+	switch t.screen {
+	case screenResults:
+		if !t.searched {
+			return []string{"CERN Open Data browser. Press / to search records, ? for help."}
+		}
+		return []string{fmt.Sprintf("Search %s: %d records", t.b.describeSearch(), t.b.total)}
+	case screenFacets:
+		return []string{fmt.Sprintf("Facets of search %s", t.b.describeSearch())}
+	case screenRecord:
+		return t.b.recordSummary()
+	case screenMeta:
+		return []string{fmt.Sprintf("Metadata of record %d", t.b.recid)}
+	case screenFiles:
+		return []string{fmt.Sprintf("Files of %s: %d", t.b.filesOf, len(t.b.files))}
+	case screenSelection:
+		return []string{"Selected files"}
+	default:
+		return []string{"Keys"}
+	}
+}
+
+func (t *TUI) count() int {
+	switch t.screen {
+	case screenResults:
+		return len(t.hits)
+	case screenFacets:
+		return len(t.facetValues)
+	case screenRecord:
+		return 1 + len(t.b.indices)
+	case screenMeta:
+		return len(t.meta)
+	case screenFiles:
+		return len(t.b.files)
+	case screenSelection:
+		return len(t.b.selected)
+	default:
+		return len(tuiHelp)
+	}
+}
+
+func (t *TUI) items() []string {
+	var items []string
+	switch t.screen {
+	case screenResults:
+		for _, hit := range t.hits {
+			title, _ := hit.Metadata["title"].(string)
+			items = append(items, fmt.Sprintf("%-7s %s%s", hit.ID, title, typeSuffix(hit.Metadata)))
+		}
+	case screenFacets:
+		for _, value := range t.facetValues {
+			mark := "[ ]"
+			if filter, ok := t.b.facets[value.name]; ok && filter == value.value {
+				mark = "[x]"
+			}
+			items = append(items, fmt.Sprintf("%s %s: %s (%d)", mark, value.name, value.value, value.count))
+		}
+	case screenRecord:
+		items = append(items, "Files of the record")
+		for _, index := range t.b.indices {
+			items = append(items, indexLine(index))
+		}
+	case screenMeta:
+		items = t.meta
+	case screenFiles:
+		for _, file := range t.b.files {
+			items = append(items, t.b.fileLine(file))
+		}
+	case screenSelection:
+		for _, file := range t.b.selected {
+			items = append(items, fmt.Sprintf("%10s  %s", utils.FormatBytes(float64(file.Size)), file.URI))
+		}
+	default:
+		items = tuiHelp
+	}
+	return items
+}
+
+func (t *TUI) status() string {
+	if t.prompt != nil {
+		return t.prompt.label + ": " + string(t.prompt.text) + "_"
+	}
+	if t.message != "" {
+		return t.message
+	}
+	return t.b.selectionSummary()
+}
+
+// move moves the cursor, or scrolls text, by delta lines. Moving onto the
+// last search result loads the next page of results.
+func (t *TUI) move(delta int) {
+	last := t.count() - 1
+	if t.isText() {
+		last = t.count() - t.rows
+	}
+	cursor := min(max(t.cursor[t.screen]+delta, 0), max(last, 0))
+	t.cursor[t.screen] = cursor
+
+	if t.screen == screenResults && cursor == len(t.hits)-1 && len(t.hits) < t.b.total {
+		if err := t.loadMore(); err != nil {
+			t.message = "error: " + err.Error()
+		}
+	}
+}
+
+// open shows screen s, which goes back to the current screen.
+func (t *TUI) open(s screen) {
+	t.history = append(t.history, t.screen)
+	t.screen = s
+	t.cursor[s] = 0
+	t.offset[s] = 0
+}
+
+func (t *TUI) back() {
+	if len(t.history) == 0 {
+		return
+	}
+	t.screen = t.history[len(t.history)-1]
+	t.history = t.history[:len(t.history)-1]
+}
+
+// home shows the search results at the top.
+func (t *TUI) home() {
+	t.screen = screenResults
+	t.history = nil
+	t.cursor[screenResults] = 0
+	t.offset[screenResults] = 0
+}
+
+func (t *TUI) search() error {
+	resp, err := t.b.backend.SearchRecords(t.b.query, t.b.facets, 1, t.b.PageSize, "")
+	if err != nil {
+		return err
+	}
+	t.b.page = 1
+	t.b.total = resp.Hits.Total
+	t.hits = resp.Hits.Hits
+	t.searched = true
+	t.home()
+	return nil
+}
+
+func (t *TUI) loadMore() error {
+	resp, err := t.b.backend.SearchRecords(t.b.query, t.b.facets, t.b.page+1, t.b.PageSize, "")
+	if err != nil {
+		return err
+	}
+	t.b.page++
+	t.b.total = resp.Hits.Total
+	t.hits = append(t.hits, resp.Hits.Hits...)
+	return nil
+}
+
+func (t *TUI) enter() error {
+	cursor := t.cursor[t.screen]
+	switch t.screen {
+	case screenResults:
+		if cursor >= len(t.hits) {
+			return nil
+		}
+		recid, err := strconv.Atoi(t.hits[cursor].ID)
+		if err != nil {
+			return fmt.Errorf("invalid record ID %q", t.hits[cursor].ID)
+		}
+		if err := t.b.loadRecord(recid); err != nil {
+			return err
+		}
+		t.open(screenRecord)
+	case screenFacets:
+		if cursor >= len(t.facetValues) {
+			return nil
+		}
+		value := t.facetValues[cursor]
+		if filter, ok := t.b.facets[value.name]; ok && filter == value.value {
+			delete(t.b.facets, value.name)
+		} else {
+			t.b.facets[value.name] = value.value
+		}
+		return t.search()
+	case screenRecord:
+		var err error
+		if cursor == 0 {
+			err = t.b.loadRecordFiles()
+		} else {
+			err = t.b.loadIndexFiles(cursor)
+		}
+		if err != nil {
+			return err
+		}
+		t.open(screenFiles)
+	case screenFiles:
+		return t.toggle()
+	}
+	return nil
+}
+
+func (t *TUI) openFacets() error {
+	values, err := t.b.loadFacets()
+	if err != nil {
+		return err
+	}
+	t.facetValues = values
+	t.open(screenFacets)
+	return nil
+}
+
+func (t *TUI) openMeta() error {
+	if t.b.record == nil {
+		return fmt.Errorf("no record open")
+	}
+	output, err := metadater.FormatTree(t.b.record.Metadata)
+	if err != nil {
+		return err
+	}
+	t.meta = strings.Split(output, "\n")
+	t.open(screenMeta)
+	return nil
+}
+
+func (t *TUI) toggle() error {
+	cursor := t.cursor[t.screen]
+	switch t.screen {
+	case screenFiles:
+		if cursor < len(t.b.files) {
+			file := t.b.files[cursor]
+			t.b.setSelected([]searcher.FileInfo{file}, !t.b.isSelected(file))
+			t.move(1)
+		}
+	case screenSelection:
+		if cursor < len(t.b.selected) {
+			t.b.setSelected([]searcher.FileInfo{t.b.selected[cursor]}, false)
+			t.move(0)
+		}
+	default:
+		return fmt.Errorf("no file list, open a record and its files first")
+	}
+	return nil
+}
+
+func (t *TUI) selectAll(selected bool) error {
+	switch t.screen {
+	case screenFiles:
+		t.b.setSelected(t.b.files, selected)
+	case screenSelection:
+		if !selected {
+			t.b.setSelected(t.b.selected, false)
+			t.move(0)
+		}
+	default:
+		return fmt.Errorf("no file list, open a record and its files first")
+	}
+	return nil
+}
+
+func (t *TUI) askDownload() error {
+	if len(t.b.selected) == 0 {
+		return fmt.Errorf("no files selected")
+	}
+	t.ask("Download into", t.b.downloadDir(), t.download)
+	return nil
+}
+
+func (t *TUI) download(dir string) error {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = t.b.downloadDir()
+	}
+	n := len(t.b.selected)
+	download := func() error { return t.b.download(dir) }
+	var err error
+	if t.Suspend != nil {
+		err = t.Suspend(download)
+	} else {
+		err = download()
+	}
+	if err != nil {
+		return err
+	}
+	if t.screen == screenSelection {
+		t.move(0)
+	}
+	t.message = fmt.Sprintf("Download of %d files into %s done", n, dir)
+	return nil
+}
+
+// ask prompts for a line of text, starting with text, and passes it to
+// done when it is entered.
+func (t *TUI) ask(label, text string, done func(text string) error) {
+	t.prompt = &prompt{label: label, text: []rune(text), done: done}
+}
+
+func (t *TUI) edit(key Key) {
+	p := t.prompt
+	switch key {
+	case KeyEnter:
+		t.prompt = nil
+		t.message = ""
+		if err := p.done(string(p.text)); err != nil {
+			t.message = "error: " + err.Error()
+		}
+	case KeyEscape, KeyInterrupt:
+		t.prompt = nil
+	case KeyBackspace:
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+	default:
+		if runes := []rune(string(key)); len(runes) == 1 {
+			p.text = append(p.text, runes[0])
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package browser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+func newTUI(backend Backend, onDownload DownloadFunc) *TUI {
+	b := New(backend, strings.NewReader(""), &bytes.Buffer{})
+	b.PageSize = 2
+	b.OnDownload = onDownload
+	return NewTUI(b)
+}
+
+func press(t *TUI, keys ...Key) {
+	for _, key := range keys {
+		if t.HandleKey(key) {
+			return
+		}
+	}
+}
+
+func typeText(t *TUI, text string) {
+	for _, r := range text {
+		t.HandleKey(Key(r))
+	}
+}
+
+func screenText(t *TUI) string {
+	return strings.Join(t.View(80, 12), "\n")
+}
+
+func TestReadKey(t *testing.T) {
+	input := "q\x1b[A\x1b[B\x1bOC\x1b[D\x1b[5~\x1b[6~\x1b[H\x1b[4~\r\x7f\x03 é\x1b[3~\x01\x1b"
+	want := []Key{"q", KeyUp, KeyDown, KeyRight, KeyLeft, KeyPageUp, KeyPageDown, KeyHome, KeyEnd,
+		KeyEnter, KeyBackspace, KeyInterrupt, " ", "é", "", "", KeyEscape}
+
+	r := bufio.NewReader(strings.NewReader(input))
+	var got []Key
+	for {
+		key, err := ReadKey(r)
+		if err != nil {
+			break
+		}
+		got = append(got, key)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadKey() = %q, want %q", got, want)
+	}
+}
+
+func TestTUISearchAndScroll(t *testing.T) {
+	backend := &fakeBackend{}
+	tui := newTUI(backend, nil)
+
+	if !strings.Contains(screenText(tui), "Press / to search") {
+		t.Errorf("unexpected start screen:\n%s", screenText(tui))
+	}
+
+	press(tui, "/")
+	typeText(tui, "muonx")
+	press(tui, KeyBackspace)
+	if !strings.Contains(screenText(tui), "Search: muon_") {
+		t.Errorf("prompt not shown:\n%s", screenText(tui))
+	}
+	press(tui, KeyEnter)
+	text := screenText(tui)
+	for _, want := range []string{`Search "muon": 3 records`, "> 6001    Dataset 1 [Dataset]", "  6002    Dataset 2 [Dataset]"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("screen missing %q:\n%s", want, text)
+		}
+	}
+
+	// Moving onto the last loaded result loads the next page.
+	press(tui, KeyDown, KeyDown)
+	if !strings.Contains(screenText(tui), "> 6003    Dataset 3 [Dataset]") {
+		t.Errorf("next page not loaded:\n%s", screenText(tui))
+	}
+
+	press(tui, "f")
+	if !strings.Contains(screenText(tui), "> [ ] experiment: CMS (3)") {
+		t.Errorf("facets not shown:\n%s", screenText(tui))
+	}
+	press(tui, KeyEnter)
+	if !strings.Contains(screenText(tui), `Search "muon" [experiment=CMS]: 3 records`) {
+		t.Errorf("facet filter not applied:\n%s", screenText(tui))
+	}
+
+	want := []string{
+		"muon map[] page=1",
+		"muon map[] page=2",
+		"muon map[] page=1",
+		"muon map[experiment:CMS] page=1",
+	}
+	if !reflect.DeepEqual(backend.searches, want) {
+		t.Errorf("searches = %q, want %q", backend.searches, want)
+	}
+}
+
+func TestTUIScrollsLongLists(t *testing.T) {
+	tui := newTUI(&fakeBackend{}, nil)
+	tui.b.record = &searcher.RecordResponse{}
+	for i := range 100 {
+		tui.b.files = append(tui.b.files, searcher.FileInfo{URI: fmt.Sprintf("http://server/eos/%03d.root", i), Size: 10})
+	}
+	tui.open(screenFiles)
+
+	tui.View(80, 12)
+	press(tui, KeyPageDown, KeyPageDown, KeyDown)
+	lines := tui.View(80, 12)
+	if len(lines) != 12 {
+		t.Fatalf("View() returned %d lines, want 12", len(lines))
+	}
+	if want := "> [ ]       10 B  online     019.root"; lines[len(lines)-3] != want {
+		t.Errorf("cursor line = %q, want %q", lines[len(lines)-3], want)
+	}
+
+	press(tui, KeyEnd)
+	if !strings.Contains(screenText(tui), "> [ ]       10 B  online     099.root") {
+		t.Errorf("end not shown:\n%s", screenText(tui))
+	}
+}
+
+func TestTUISelectAndDownload(t *testing.T) {
+	var downloaded []searcher.FileInfo
+	var downloadDir string
+	suspended := 0
+	tui := newTUI(&fakeBackend{}, func(files []searcher.FileInfo, dir string) error {
+		downloaded = files
+		downloadDir = dir
+		return nil
+	})
+	tui.Suspend = func(download func() error) error {
+		suspended++
+		return download()
+	}
+
+	if err := tui.Search("muon", nil); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	press(tui, KeyDown, KeyEnter)
+	text := screenText(tui)
+	for _, want := range []string{"Record 6002: Dataset 6002", "> Files of the record", "       2 files      2.9 KB  index.txt"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("record screen missing %q:\n%s", want, text)
+		}
+	}
+
+	press(tui, "m")
+	if !strings.Contains(screenText(tui), "Metadata of record 6002") {
+		t.Errorf("metadata not shown:\n%s", screenText(tui))
+	}
+	press(tui, KeyEscape)
+
+	// Select both files of the first index, then unselect the first one.
+	press(tui, KeyDown, KeyEnter, "a", KeyHome, " ")
+	text = screenText(tui)
+	for _, want := range []string{"  [ ]     1000 B  online     a.root", "> [x]     2.0 KB  on demand  b.root", "Selected: 1 files, 2.0 KB (1 on tape)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("files screen missing %q:\n%s", want, text)
+		}
+	}
+
+	press(tui, KeyEscape, KeyDown, KeyEnter, "x", "s")
+	if !strings.Contains(screenText(tui), ">     2.0 KB  http://server/eos/b.root") {
+		t.Errorf("selection not shown:\n%s", screenText(tui))
+	}
+
+	press(tui, "d")
+	if !strings.Contains(screenText(tui), "Download into: 6002_") {
+		t.Errorf("download prompt not shown:\n%s", screenText(tui))
+	}
+	press(tui, KeyBackspace, KeyBackspace, KeyBackspace, KeyBackspace)
+	typeText(tui, "out")
+	press(tui, KeyEnter)
+
+	if suspended != 1 || downloadDir != "out" || len(downloaded) != 2 ||
+		downloaded[0].URI != "http://server/eos/b.root" || downloaded[1].URI != "http://server/eos/c.root" {
+		t.Errorf("downloaded %v into %q, suspended %d times", downloaded, downloadDir, suspended)
+	}
+	if !strings.Contains(screenText(tui), "Download of 2 files into out done") {
+		t.Errorf("download not reported:\n%s", screenText(tui))
+	}
+}
+
+func TestTUIErrors(t *testing.T) {
+	tests := []struct {
+		keys []Key
+		want string
+	}{
+		{keys: []Key{"m"}, want: "error: no record open"},
+		{keys: []Key{" "}, want: "error: no file list"},
+		{keys: []Key{"d"}, want: "error: no files selected"},
+		{keys: []Key{"/", "f", "a", "i", "l", KeyEnter}, want: "error: search failed"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.keys[0]), func(t *testing.T) {
+			tui := newTUI(&fakeBackend{}, nil)
+			press(tui, tt.keys...)
+			if !strings.Contains(screenText(tui), tt.want) {
+				t.Errorf("screen missing %q:\n%s", tt.want, screenText(tui))
+			}
+		})
+	}
+}
+
+func TestTUIRun(t *testing.T) {
+	backend := &fakeBackend{}
+	tui := newTUI(backend, nil)
+	var out bytes.Buffer
+	err := tui.Run(strings.NewReader("/higgs\r\x1b[B?q/never\r"), &out, func() (int, int) { return 40, 8 })
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if want := []string{"higgs map[] page=1", "higgs map[] page=2"}; !reflect.DeepEqual(backend.searches, want) {
+		t.Errorf("searches = %q, want %q", backend.searches, want)
+	}
+	if !strings.Contains(out.String(), "\x1b[H") || !strings.Contains(out.String(), "esc back") {
+		t.Errorf("unexpected output:\n%q", out.String())
+	}
+}
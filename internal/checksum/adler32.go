@@ -1,12 +1,15 @@
 package checksum
 
 import (
-	"fmt"
-	"hash/adler32"
 	"io"
 	"os"
 )
 
+// BufferSize is the read buffer size used when hashing files. It is much
+// larger than the io.Copy default so that large files are read in few
+// system calls.
+const BufferSize = 1 << 20
+
 func CalculateChecksum(filePath string) (string, error) {
 	file, err := os.Open(filePath) // #nosec G304
 	if err != nil {
@@ -14,12 +17,17 @@ func CalculateChecksum(filePath string) (string, error) {
 	}
 	defer func() { _ = file.Close() }()
 
-	hasher := adler32.New()
-	if _, err := io.Copy(hasher, file); err != nil {
+	return CalculateReaderChecksum(file)
+}
+
+// CalculateReaderChecksum returns the adler32 checksum of everything read
+// from r, in the same format as CalculateChecksum.
+func CalculateReaderChecksum(r io.Reader) (string, error) {
+	sums, err := Calculate(r, DefaultAlgorithm)
+	if err != nil {
 		return "", err
 	}
-
-	return fmt.Sprintf("adler32:%08x", hasher.Sum32()), nil
+	return sums[DefaultAlgorithm], nil
 }
 
 func GetFileSize(filePath string) (int64, error) {
@@ -1,8 +1,11 @@
 package checksum
 
 import (
+	"fmt"
+	"hash/adler32"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -68,3 +71,17 @@ func TestGetFileSize(t *testing.T) {
 		t.Errorf("GetFileSize() = %d, want %d", size, expected)
 	}
 }
+
+func TestCalculateReaderChecksum(t *testing.T) {
+	content := strings.Repeat("cernopendata", BufferSize/4)
+
+	got, err := CalculateReaderChecksum(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("CalculateReaderChecksum() error = %v", err)
+	}
+
+	want := fmt.Sprintf("adler32:%08x", adler32.Checksum([]byte(content)))
+	if got != want {
+		t.Errorf("CalculateReaderChecksum() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package checksum
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheFileName is the name of the sidecar file in which Cache keeps the
+// checksums of the files of a directory.
+const CacheFileName = ".cernopendata-checksums.json"
+
+// ErrFileChanged is returned by Cache.Store when the file changed while its
+// checksums were computed.
+var ErrFileChanged = errors.New("file changed while it was hashed")
+
+// CacheEntry holds the cached checksums of a file, keyed by algorithm,
+// together with the file attributes they were computed for.
+type CacheEntry struct {
+	Size      int64             `json:"size"`
+	ModTime   int64             `json:"mtime"`
+	Inode     uint64            `json:"inode,omitempty"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+// Cache remembers computed checksums in a sidecar file per directory, so
+// that unchanged files need not be hashed again. An entry is only used
+// while the file's size, modification time and inode are unchanged. It is
+// safe for concurrent use.
+type Cache struct {
+	mu   sync.Mutex
+	dirs map[string]*cacheDir
+}
+
+// cacheDir holds the entries of one directory, keyed by file name.
+type cacheDir struct {
+	entries map[string]CacheEntry
+	dirty   bool
+}
+
+// NewCache creates an empty Cache. Sidecar files are read when a
+// directory is first used.
+func NewCache() *Cache {
+	return &Cache{dirs: make(map[string]*cacheDir)}
+}
+
+// Lookup returns the cached checksum of the file computed with the
+// algorithm, if the file has not changed since it was stored.
+func (c *Cache) Lookup(filePath, algorithm string) (string, bool) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.dir(filepath.Dir(filePath)).entries[filepath.Base(filePath)]
+	if !ok || !entry.matches(info) {
+		return "", false
+	}
+	sum, ok := entry.Checksums[algorithm]
+	return sum, ok
+}
+
+// Matches reports whether the file has a valid cached checksum of the
+// algorithm of expected and, if so, whether it equals expected.
+func (c *Cache) Matches(filePath, expected string) (cached, match bool) {
+	algorithm, digest, err := Parse(expected)
+	if err != nil {
+		return false, false
+	}
+	sum, ok := c.Lookup(filePath, algorithm)
+	if !ok {
+		return false, false
+	}
+	return true, sum == algorithm+":"+digest
+}
+
+// Store records checksum values such as adler32:0a1b2c3d of the file with
+// info, the attributes the file had before it was hashed. Nothing is
+// stored if the file no longer has these attributes. Checksums of other
+// algorithms stored earlier are kept while the file is unchanged.
+func (c *Cache) Store(filePath string, info os.FileInfo, checksums ...string) error {
+	current, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if !sameAttributes(info, current) {
+		return fmt.Errorf("%w: %s", ErrFileChanged, filePath)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dir := c.dir(filepath.Dir(filePath))
+	entry, ok := dir.entries[filepath.Base(filePath)]
+	if !ok || !entry.matches(info) {
+		entry = CacheEntry{
+			Size:      info.Size(),
+			ModTime:   info.ModTime().UnixNano(),
+			Inode:     inode(info),
+			Checksums: make(map[string]string),
+		}
+	}
+	if entry.Checksums == nil {
+		entry.Checksums = make(map[string]string)
+	}
+	for _, value := range checksums {
+		algorithm, digest, err := Parse(value)
+		if err != nil {
+			return err
+		}
+		entry.Checksums[algorithm] = algorithm + ":" + digest
+	}
+	dir.entries[filepath.Base(filePath)] = entry
+	dir.dirty = true
+	return nil
+}
+
+// Forget removes the cached checksum of the file.
+func (c *Cache) Forget(filePath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dir := c.dir(filepath.Dir(filePath))
+	if _, ok := dir.entries[filepath.Base(filePath)]; ok {
+		delete(dir.entries, filepath.Base(filePath))
+		dir.dirty = true
+	}
+}
+
+// Save writes the sidecar files of the directories that changed, removing
+// the sidecar files of directories that no longer have entries.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, dir := range c.dirs {
+		if !dir.dirty {
+			continue
+		}
+		if len(dir.entries) == 0 {
+			// Do not leave an empty sidecar behind in a directory that
+			// may be removed.
+			if err := os.Remove(filepath.Join(path, CacheFileName)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to save checksum cache: %w", err)
+			}
+			dir.dirty = false
+			continue
+		}
+		data, err := json.MarshalIndent(dir.entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		// Write to a temporary file first so that an interrupted save
+		// never leaves a truncated sidecar behind.
+		tmp := filepath.Join(path, CacheFileName+".tmp")
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return fmt.Errorf("failed to save checksum cache: %w", err)
+		}
+		if err := os.Rename(tmp, filepath.Join(path, CacheFileName)); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("failed to save checksum cache: %w", err)
+		}
+		dir.dirty = false
+	}
+	return nil
+}
+
+// dir returns the entries of a directory, reading its sidecar file on
+// first use. A missing or unreadable sidecar yields an empty cache.
+func (c *Cache) dir(path string) *cacheDir {
+	if dir, ok := c.dirs[path]; ok {
+		return dir
+	}
+	dir := &cacheDir{entries: make(map[string]CacheEntry)}
+	if data, err := os.ReadFile(filepath.Join(path, CacheFileName)); err == nil { // #nosec G304
+		if err := json.Unmarshal(data, &dir.entries); err != nil {
+			dir.entries = make(map[string]CacheEntry)
+		}
+	}
+	c.dirs[path] = dir
+	return dir
+}
+
+// matches reports whether the entry was stored for a file with the given
+// attributes.
+func (e CacheEntry) matches(info os.FileInfo) bool {
+	return e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano() && e.Inode == inode(info)
+}
+
+// sameAttributes reports whether a and b describe the same, unchanged file.
+func sameAttributes(a, b os.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime()) && inode(a) == inode(b)
+}
@@ -0,0 +1,161 @@
+package checksum
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func stat(t *testing.T, path string) os.FileInfo {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.root")
+	if err := os.WriteFile(testFile, []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewCache()
+	if _, ok := cache.Lookup(testFile, "adler32"); ok {
+		t.Fatal("Lookup() found an entry in an empty cache")
+	}
+	if err := cache.Store(testFile, stat(t, testFile), "adler32:045d01c1"); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, CacheFileName)); err != nil {
+		t.Fatalf("sidecar file not written: %v", err)
+	}
+
+	// A new cache reads the sidecar written by the first one.
+	cache = NewCache()
+	if got, ok := cache.Lookup(testFile, "adler32"); !ok || got != "adler32:045d01c1" {
+		t.Errorf("Lookup() = %q, %v, want cached checksum", got, ok)
+	}
+
+	// Changing the modification time invalidates the entry.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(testFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Lookup(testFile, "adler32"); ok {
+		t.Error("Lookup() used an entry of a modified file")
+	}
+
+	// Changing the size invalidates the entry.
+	if err := cache.Store(testFile, stat(t, testFile), "adler32:045d01c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(testFile, []byte("tests"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(testFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Lookup(testFile, "adler32"); ok {
+		t.Error("Lookup() used an entry of a resized file")
+	}
+
+	cache.Forget(testFile)
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := NewCache().Lookup(testFile, "adler32"); ok {
+		t.Error("Forget() did not remove the entry")
+	}
+}
+
+func TestCacheCorruptSidecar(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.root")
+	if err := os.WriteFile(testFile, []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, CacheFileName), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewCache()
+	if _, ok := cache.Lookup(testFile, "adler32"); ok {
+		t.Error("Lookup() found an entry in a corrupt sidecar")
+	}
+	if err := cache.Store(testFile, stat(t, testFile), "adler32:045d01c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got, ok := NewCache().Lookup(testFile, "adler32"); !ok || got != "adler32:045d01c1" {
+		t.Errorf("Lookup() = %q, %v after overwriting a corrupt sidecar", got, ok)
+	}
+}
+
+func TestCacheAlgorithms(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.root")
+	if err := os.WriteFile(testFile, []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewCache()
+	if err := cache.Store(testFile, stat(t, testFile), "adler32:045d01c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Store(testFile, stat(t, testFile), "MD5:098F6BCD4621D373CADE4E832627B4F6"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cache.Lookup(testFile, "adler32"); !ok || got != "adler32:045d01c1" {
+		t.Errorf("Lookup(adler32) = %q, %v", got, ok)
+	}
+	if got, ok := cache.Lookup(testFile, "md5"); !ok || got != "md5:098f6bcd4621d373cade4e832627b4f6" {
+		t.Errorf("Lookup(md5) = %q, %v", got, ok)
+	}
+	if _, ok := cache.Lookup(testFile, "sha256"); ok {
+		t.Error("Lookup(sha256) found an entry that was never stored")
+	}
+
+	if cached, match := cache.Matches(testFile, "md5:098f6bcd4621d373cade4e832627b4f6"); !cached || !match {
+		t.Errorf("Matches(md5) = %v, %v, want true, true", cached, match)
+	}
+	if cached, match := cache.Matches(testFile, "adler32:00000000"); !cached || match {
+		t.Errorf("Matches(wrong adler32) = %v, %v, want true, false", cached, match)
+	}
+	if cached, _ := cache.Matches(testFile, "blake3:00"); cached {
+		t.Error("Matches() with unknown algorithm reported a cached checksum")
+	}
+
+	if err := cache.Store(testFile, stat(t, testFile), "blake3:00"); err == nil {
+		t.Error("Store() accepted an unknown algorithm")
+	}
+}
+
+func TestCacheStoreChangedFile(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.root")
+	if err := os.WriteFile(testFile, []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info := stat(t, testFile)
+
+	// The file grows while it is hashed.
+	if err := os.WriteFile(testFile, []byte("tests"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache()
+	if err := cache.Store(testFile, info, "adler32:045d01c1"); !errors.Is(err, ErrFileChanged) {
+		t.Errorf("Store() error = %v, want ErrFileChanged", err)
+	}
+	if _, ok := cache.Lookup(testFile, "adler32"); ok {
+		t.Error("Lookup() found the checksum of a file that changed while hashed")
+	}
+}
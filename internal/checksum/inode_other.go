@@ -0,0 +1,11 @@
+//go:build !unix
+
+package checksum
+
+import "os"
+
+// inode returns 0 on platforms without inode numbers; cache entries are
+// then keyed by size and modification time only.
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,136 @@
+package checksum
+
+import (
+	"crypto/md5" // #nosec G501 -- md5 is used to verify files, not for security
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultAlgorithm is the algorithm of the checksums in CERN Open Data
+// records.
+const DefaultAlgorithm = "adler32"
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func() hash.Hash)
+)
+
+func init() {
+	Register("adler32", func() hash.Hash { return adler32.New() })
+	Register("crc32c", func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) })
+	Register("md5", md5.New)
+	Register("sha256", sha256.New)
+}
+
+// Register makes a checksum algorithm available under name, replacing
+// any algorithm registered under the same name. Checksum values of the
+// algorithm are written as name:<hex digest>.
+func Register(name string, newHash func() hash.Hash) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(name)] = newHash
+}
+
+// Algorithms returns the names of the registered algorithms, sorted.
+func Algorithms() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new hash of the named algorithm.
+func New(algorithm string) (hash.Hash, error) {
+	registryMu.RLock()
+	newHash, ok := registry[strings.ToLower(algorithm)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown checksum algorithm %q (supported: %s)", algorithm, strings.Join(Algorithms(), ", "))
+	}
+	return newHash(), nil
+}
+
+// Parse splits a checksum value such as adler32:0a1b2c3d into its
+// algorithm and lower-case hex digest. It fails if the value has no
+// algorithm prefix, the algorithm is not registered, or the digest is not
+// a hex string of the algorithm's length.
+func Parse(value string) (algorithm, digest string, err error) {
+	if value == "" {
+		return "", "", fmt.Errorf("no checksum")
+	}
+	algorithm, digest, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", "", fmt.Errorf("checksum %q has no algorithm prefix", value)
+	}
+	algorithm = strings.ToLower(algorithm)
+	h, err := New(algorithm)
+	if err != nil {
+		return "", "", err
+	}
+	digest = strings.ToLower(digest)
+	if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != h.Size() {
+		return "", "", fmt.Errorf("checksum %q is not a %s value of %d hex digits", value, algorithm, 2*h.Size())
+	}
+	return algorithm, digest, nil
+}
+
+// Format returns the checksum value of a finished hash.
+func Format(algorithm string, h hash.Hash) string {
+	return strings.ToLower(algorithm) + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Calculate computes the checksums of everything read from r with each of
+// the algorithms in a single read pass. The values are keyed by algorithm.
+func Calculate(r io.Reader, algorithms ...string) (map[string]string, error) {
+	hashes := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		algorithm = strings.ToLower(algorithm)
+		if _, ok := hashes[algorithm]; ok {
+			continue
+		}
+		h, err := New(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		hashes[algorithm] = h
+		writers = append(writers, h)
+	}
+
+	// Hide any WriterTo implementation (such as *os.File's) so that
+	// io.CopyBuffer really uses the tuned buffer.
+	if _, err := io.CopyBuffer(io.MultiWriter(writers...), struct{ io.Reader }{r}, make([]byte, BufferSize)); err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string, len(hashes))
+	for algorithm, h := range hashes {
+		sums[algorithm] = Format(algorithm, h)
+	}
+	return sums, nil
+}
+
+// CalculateFile computes the checksums of a file with each of the
+// algorithms in a single read pass.
+func CalculateFile(filePath string, algorithms ...string) (map[string]string, error) {
+	file, err := os.Open(filePath) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return Calculate(file, algorithms...)
+}
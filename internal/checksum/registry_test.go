@@ -0,0 +1,82 @@
+package checksum
+
+import (
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value         string
+		wantAlgorithm string
+		wantDigest    string
+		wantErr       string
+	}{
+		{value: "adler32:045d01c1", wantAlgorithm: "adler32", wantDigest: "045d01c1"},
+		{value: "ADLER32:045D01C1", wantAlgorithm: "adler32", wantDigest: "045d01c1"},
+		{value: "md5:098f6bcd4621d373cade4e832627b4f6", wantAlgorithm: "md5", wantDigest: "098f6bcd4621d373cade4e832627b4f6"},
+		{value: "", wantErr: "no checksum"},
+		{value: "045d01c1", wantErr: "no algorithm prefix"},
+		{value: "blake3:045d01c1", wantErr: `unknown checksum algorithm "blake3"`},
+		{value: "adler32:045d01", wantErr: "not a adler32 value of 8 hex digits"},
+		{value: "md5:xyz", wantErr: "not a md5 value of 32 hex digits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			algorithm, digest, err := Parse(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Parse(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.value, err)
+			}
+			if algorithm != tt.wantAlgorithm || digest != tt.wantDigest {
+				t.Errorf("Parse(%q) = %q, %q, want %q, %q", tt.value, algorithm, digest, tt.wantAlgorithm, tt.wantDigest)
+			}
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	got, err := Calculate(strings.NewReader("test"), "adler32", "crc32c", "MD5", "sha256", "adler32")
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+	want := map[string]string{
+		"adler32": "adler32:045d01c1",
+		"crc32c":  "crc32c:86a072c0",
+		"md5":     "md5:098f6bcd4621d373cade4e832627b4f6",
+		"sha256":  "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Calculate() = %v, want %v", got, want)
+	}
+
+	if _, err := Calculate(strings.NewReader("test"), "blake3"); err == nil || !strings.Contains(err.Error(), "supported: adler32, crc32c, md5, sha256") {
+		t.Errorf("Calculate() with unknown algorithm error = %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("fnv32", func() hash.Hash { return fnv.New32() })
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "fnv32")
+		registryMu.Unlock()
+	}()
+
+	sums, err := Calculate(strings.NewReader("test"), "fnv32")
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+	if _, _, err := Parse(sums["fnv32"]); err != nil {
+		t.Errorf("Parse(%q) error = %v", sums["fnv32"], err)
+	}
+}
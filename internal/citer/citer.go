@@ -0,0 +1,299 @@
+package citer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+// Citation holds the bibliographic fields of a record needed to cite it.
+type Citation struct {
+	Recid         int
+	DOI           string
+	Title         string
+	Authors       []string
+	Collaboration string
+	Experiment    string
+	Year          string
+	Publisher     string
+	Type          string
+	URL           string
+}
+
+var nonKeyChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// FromMetadata builds a Citation from record metadata as returned by the portal.
+func FromMetadata(metadata map[string]any) (*Citation, error) {
+	if metadata == nil {
+		return nil, fmt.Errorf("metadata is nil")
+	}
+
+	c := &Citation{}
+
+	switch v := metadata["recid"].(type) {
+	case float64:
+		c.Recid = int(v)
+	case int:
+		c.Recid = v
+	case string:
+		recid, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recid %q: %w", v, err)
+		}
+		c.Recid = recid
+	default:
+		return nil, fmt.Errorf("record metadata has no recid")
+	}
+
+	c.DOI, _ = metadata["doi"].(string)
+	c.Title, _ = metadata["title"].(string)
+	c.Publisher, _ = metadata["publisher"].(string)
+	if c.Publisher == "" {
+		c.Publisher = "CERN Open Data Portal"
+	}
+
+	if authors, ok := metadata["authors"].([]any); ok {
+		for _, a := range authors {
+			if authorMap, ok := a.(map[string]any); ok {
+				if name, ok := authorMap["name"].(string); ok && name != "" {
+					c.Authors = append(c.Authors, name)
+				}
+			}
+		}
+	}
+
+	if collaboration, ok := metadata["collaboration"].(map[string]any); ok {
+		c.Collaboration, _ = collaboration["name"].(string)
+	}
+
+	switch v := metadata["experiment"].(type) {
+	case string:
+		c.Experiment = v
+	case []any:
+		var experiments []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				experiments = append(experiments, s)
+			}
+		}
+		c.Experiment = strings.Join(experiments, ", ")
+	}
+
+	for _, field := range []string{"date_published", "date_created"} {
+		switch v := metadata[field].(type) {
+		case string:
+			if len(v) >= 4 {
+				c.Year = v[:4]
+			}
+		case []any:
+			if len(v) > 0 {
+				if s, ok := v[0].(string); ok && len(s) >= 4 {
+					c.Year = s[:4]
+				}
+			}
+		}
+		if c.Year != "" {
+			break
+		}
+	}
+
+	if recordType, ok := metadata["type"].(map[string]any); ok {
+		c.Type, _ = recordType["primary"].(string)
+	}
+
+	if c.DOI != "" {
+		c.URL = "https://doi.org/" + c.DOI
+	} else {
+		c.URL = fmt.Sprintf("%s/record/%d", config.ServerHTTPSURI, c.Recid)
+	}
+
+	return c, nil
+}
+
+// Deduplicate removes citations that share a DOI with an earlier citation.
+// Citations without a DOI are deduplicated by record ID.
+func Deduplicate(citations []*Citation) []*Citation {
+	seen := make(map[string]bool)
+	var result []*Citation
+	for _, c := range citations {
+		key := "recid:" + strconv.Itoa(c.Recid)
+		if c.DOI != "" {
+			key = "doi:" + strings.ToLower(c.DOI)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, c)
+	}
+	return result
+}
+
+// Key returns the citation key used in BibTeX entries.
+func (c *Citation) Key() string {
+	prefix := c.Collaboration
+	if prefix == "" {
+		prefix = c.Experiment
+	}
+	prefix = nonKeyChars.ReplaceAllString(prefix, "")
+	if prefix == "" {
+		prefix = "CERNOpenData"
+	}
+	if c.Year == "" {
+		return fmt.Sprintf("%s:%d", prefix, c.Recid)
+	}
+	return fmt.Sprintf("%s:%s:%d", prefix, c.Year, c.Recid)
+}
+
+// creators returns the author list, falling back to the collaboration name.
+func (c *Citation) creators() []string {
+	if len(c.Authors) > 0 {
+		return c.Authors
+	}
+	if c.Collaboration != "" {
+		return []string{c.Collaboration}
+	}
+	return nil
+}
+
+// Format renders citations in the given style (bibtex|ris|csl-json|text).
+func Format(citations []*Citation, style string) (string, error) {
+	switch style {
+	case "bibtex":
+		var entries []string
+		for _, c := range citations {
+			entries = append(entries, c.BibTeX())
+		}
+		return strings.Join(entries, "\n"), nil
+	case "ris":
+		var entries []string
+		for _, c := range citations {
+			entries = append(entries, c.RIS())
+		}
+		return strings.Join(entries, "\n"), nil
+	case "csl-json":
+		items := make([]map[string]any, 0, len(citations))
+		for _, c := range citations {
+			items = append(items, c.CSL())
+		}
+		jsonBytes, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(jsonBytes), nil
+	case "text":
+		var lines []string
+		for _, c := range citations {
+			lines = append(lines, c.Text())
+		}
+		return strings.Join(lines, "\n"), nil
+	default:
+		return "", fmt.Errorf("unknown citation style: %s", style)
+	}
+}
+
+// escapeBibTeX protects characters with special meaning in BibTeX values.
+func escapeBibTeX(s string) string {
+	replacer := strings.NewReplacer(`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`)
+	return replacer.Replace(s)
+}
+
+// BibTeX renders the citation as a BibTeX @misc entry.
+func (c *Citation) BibTeX() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@misc{%s,\n", c.Key())
+	if creators := c.creators(); len(creators) > 0 {
+		var escaped []string
+		for _, a := range creators {
+			escaped = append(escaped, "{"+escapeBibTeX(a)+"}")
+		}
+		fmt.Fprintf(&b, "  author = {%s},\n", strings.Join(escaped, " and "))
+	}
+	fmt.Fprintf(&b, "  title = {{%s}},\n", escapeBibTeX(c.Title))
+	fmt.Fprintf(&b, "  publisher = {%s},\n", escapeBibTeX(c.Publisher))
+	if c.Year != "" {
+		fmt.Fprintf(&b, "  year = {%s},\n", c.Year)
+	}
+	if c.DOI != "" {
+		fmt.Fprintf(&b, "  doi = {%s},\n", c.DOI)
+	}
+	fmt.Fprintf(&b, "  url = {%s}\n", c.URL)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// RIS renders the citation as an RIS dataset entry.
+func (c *Citation) RIS() string {
+	var b strings.Builder
+	b.WriteString("TY  - DATA\n")
+	for _, a := range c.creators() {
+		fmt.Fprintf(&b, "AU  - %s\n", a)
+	}
+	fmt.Fprintf(&b, "TI  - %s\n", c.Title)
+	fmt.Fprintf(&b, "PB  - %s\n", c.Publisher)
+	if c.Year != "" {
+		fmt.Fprintf(&b, "PY  - %s\n", c.Year)
+	}
+	if c.DOI != "" {
+		fmt.Fprintf(&b, "DO  - %s\n", c.DOI)
+	}
+	fmt.Fprintf(&b, "UR  - %s\n", c.URL)
+	b.WriteString("ER  - \n")
+	return b.String()
+}
+
+// CSL returns the citation as a CSL-JSON item.
+func (c *Citation) CSL() map[string]any {
+	item := map[string]any{
+		"id":        c.Key(),
+		"type":      "dataset",
+		"title":     c.Title,
+		"publisher": c.Publisher,
+		"URL":       c.URL,
+	}
+	if c.DOI != "" {
+		item["DOI"] = c.DOI
+	}
+	if c.Type != "" {
+		item["genre"] = c.Type
+	}
+	if c.Year != "" {
+		if year, err := strconv.Atoi(c.Year); err == nil {
+			item["issued"] = map[string]any{"date-parts": [][]int{{year}}}
+		}
+	}
+	if len(c.Authors) > 0 {
+		var authors []map[string]string
+		for _, a := range c.Authors {
+			if family, given, ok := strings.Cut(a, ","); ok {
+				authors = append(authors, map[string]string{"family": strings.TrimSpace(family), "given": strings.TrimSpace(given)})
+			} else {
+				authors = append(authors, map[string]string{"literal": a})
+			}
+		}
+		item["author"] = authors
+	} else if c.Collaboration != "" {
+		item["author"] = []map[string]string{{"literal": c.Collaboration}}
+	}
+	return item
+}
+
+// Text renders the citation as a single plain-text reference.
+func (c *Citation) Text() string {
+	var parts []string
+	if creators := c.creators(); len(creators) > 0 {
+		parts = append(parts, strings.Join(creators, "; "))
+	}
+	if c.Year != "" {
+		parts = append(parts, fmt.Sprintf("(%s)", c.Year))
+	}
+	head := strings.Join(parts, " ")
+	if head != "" {
+		head += ". "
+	}
+	return fmt.Sprintf("%s%s. %s. %s", head, c.Title, c.Publisher, c.URL)
+}
@@ -0,0 +1,131 @@
+package citer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testMetadata() map[string]any {
+	return map[string]any{
+		"recid":          float64(1),
+		"doi":            "10.7483/OPENDATA.CMS.A342.9982",
+		"title":          "/BTag/Run2011A-12Oct2013-v1/AOD",
+		"publisher":      "CERN Open Data Portal",
+		"date_published": "2014",
+		"collaboration":  map[string]any{"name": "CMS Collaboration"},
+		"experiment":     []any{"CMS"},
+		"type":           map[string]any{"primary": "Dataset"},
+	}
+}
+
+func TestFromMetadata(t *testing.T) {
+	c, err := FromMetadata(testMetadata())
+	if err != nil {
+		t.Fatalf("FromMetadata failed: %v", err)
+	}
+
+	if c.Recid != 1 {
+		t.Errorf("Recid = %d, want 1", c.Recid)
+	}
+	if c.Year != "2014" {
+		t.Errorf("Year = %q, want 2014", c.Year)
+	}
+	if c.Collaboration != "CMS Collaboration" {
+		t.Errorf("Collaboration = %q, want CMS Collaboration", c.Collaboration)
+	}
+	if c.URL != "https://doi.org/10.7483/OPENDATA.CMS.A342.9982" {
+		t.Errorf("URL = %q", c.URL)
+	}
+	if c.Key() != "CMSCollaboration:2014:1" {
+		t.Errorf("Key() = %q", c.Key())
+	}
+	undated := &Citation{Recid: 1, Experiment: "CMS"}
+	if undated.Key() != "CMS:1" {
+		t.Errorf("Key() without year = %q", undated.Key())
+	}
+
+	if _, err := FromMetadata(map[string]any{"title": "no recid"}); err == nil {
+		t.Error("expected error for metadata without recid")
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	citations := []*Citation{
+		{Recid: 1, DOI: "10.1/A"},
+		{Recid: 2, DOI: "10.1/a"},
+		{Recid: 3},
+		{Recid: 3},
+		{Recid: 4},
+	}
+
+	result := Deduplicate(citations)
+	if len(result) != 3 {
+		t.Fatalf("Deduplicate returned %d citations, want 3", len(result))
+	}
+	if result[0].Recid != 1 || result[1].Recid != 3 || result[2].Recid != 4 {
+		t.Errorf("unexpected order: %d, %d, %d", result[0].Recid, result[1].Recid, result[2].Recid)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	c, err := FromMetadata(testMetadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	citations := []*Citation{c}
+
+	tests := []struct {
+		style    string
+		contains []string
+	}{
+		{
+			style:    "bibtex",
+			contains: []string{"@misc{CMSCollaboration:2014:1,", "author = {{CMS Collaboration}}", "doi = {10.7483/OPENDATA.CMS.A342.9982}"},
+		},
+		{
+			style:    "ris",
+			contains: []string{"TY  - DATA", "AU  - CMS Collaboration", "PY  - 2014", "ER  - "},
+		},
+		{
+			style:    "text",
+			contains: []string{"CMS Collaboration (2014). /BTag/Run2011A-12Oct2013-v1/AOD. CERN Open Data Portal."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output, err := Format(citations, tt.style)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			for _, exp := range tt.contains {
+				if !strings.Contains(output, exp) {
+					t.Errorf("expected output to contain %q, got:\n%s", exp, output)
+				}
+			}
+		})
+	}
+
+	t.Run("csl-json", func(t *testing.T) {
+		output, err := Format(citations, "csl-json")
+		if err != nil {
+			t.Fatalf("Format failed: %v", err)
+		}
+		var items []map[string]any
+		if err := json.Unmarshal([]byte(output), &items); err != nil {
+			t.Fatalf("invalid CSL-JSON: %v", err)
+		}
+		if len(items) != 1 || items[0]["type"] != "dataset" || items[0]["DOI"] != c.DOI {
+			t.Errorf("unexpected CSL-JSON: %s", output)
+		}
+
+		if output, _ := Format(nil, "csl-json"); output != "[]" {
+			t.Errorf("CSL-JSON of no citations = %q, want []", output)
+		}
+	})
+
+	if _, err := Format(citations, "unknown"); err == nil {
+		t.Error("expected error for unknown style")
+	}
+}
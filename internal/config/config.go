@@ -14,4 +14,16 @@ const (
 	DownloadErrorPageChecksum = "adler32:a82d5324"
 
 	XRootDReadBufferSize = 16 * 1024 * 1024
+
+	FetchFileMaxSize = 32 * 1024 * 1024
+
+	ResumeVerifySize  = 64 * 1024
+	ResumeVerifyLimit = 16 * 1024 * 1024
+
+	APIRequestTimeout        = 30
+	APIRetryLimit            = 5
+	APIRetryBaseDelay        = 1
+	APIRetryMaxDelay         = 30
+	APIRequestsPerSecond     = 10
+	APIMaxConcurrentRequests = 4
 )
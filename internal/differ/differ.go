@@ -0,0 +1,220 @@
+package differ
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+// File change kinds reported in FileChange.Change.
+const (
+	FileAdded         = "added"
+	FileRemoved       = "removed"
+	FileResized       = "resized"
+	FileRechecksummed = "rechecksummed"
+)
+
+// Metadata change kinds reported in FieldChange.Change.
+const (
+	FieldAdded   = "added"
+	FieldRemoved = "removed"
+	FieldChanged = "changed"
+)
+
+// ignoredFields are metadata fields compared through the file list instead.
+var ignoredFields = map[string]bool{
+	"files":         true,
+	"_files":        true,
+	"_file_indices": true,
+}
+
+// FieldChange describes a metadata field that differs between two snapshots.
+// Old is nil for added fields and New is nil for removed fields; Change
+// tells these apart from fields whose value is null.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Change string `json:"change"`
+	Old    any    `json:"old"`
+	New    any    `json:"new"`
+}
+
+// FileChange describes a file that differs between two snapshots.
+type FileChange struct {
+	URI         string `json:"uri"`
+	Change      string `json:"change"`
+	OldSize     int64  `json:"old_size,omitempty"`
+	NewSize     int64  `json:"new_size,omitempty"`
+	OldChecksum string `json:"old_checksum,omitempty"`
+	NewChecksum string `json:"new_checksum,omitempty"`
+}
+
+// Result holds all differences between two record snapshots.
+type Result struct {
+	Metadata []FieldChange `json:"metadata"`
+	Files    []FileChange  `json:"files"`
+}
+
+// Empty reports whether the two snapshots were identical.
+func (r *Result) Empty() bool {
+	return len(r.Metadata) == 0 && len(r.Files) == 0
+}
+
+// flatten maps nested metadata objects to dot-separated paths. Arrays and
+// scalar values are kept as leaves.
+func flatten(prefix string, value any, out map[string]any) {
+	m, ok := value.(map[string]any)
+	if !ok || len(m) == 0 {
+		out[prefix] = value
+		return
+	}
+	for k, v := range m {
+		if prefix == "" && ignoredFields[k] {
+			continue
+		}
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		flatten(path, v, out)
+	}
+}
+
+// equal compares two decoded JSON values independent of their numeric types.
+func equal(a, b any) bool {
+	aBytes, errA := json.Marshal(a)
+	bBytes, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return bytes.Equal(aBytes, bBytes)
+}
+
+// DiffMetadata compares two metadata maps field by field, excluding the file lists.
+func DiffMetadata(oldMetadata, newMetadata map[string]any) []FieldChange {
+	oldFields := make(map[string]any)
+	newFields := make(map[string]any)
+	flatten("", oldMetadata, oldFields)
+	flatten("", newMetadata, newFields)
+	delete(oldFields, "")
+	delete(newFields, "")
+
+	var changes []FieldChange
+	for field, oldValue := range oldFields {
+		newValue, ok := newFields[field]
+		if !ok {
+			changes = append(changes, FieldChange{Field: field, Change: FieldRemoved, Old: oldValue})
+		} else if !equal(oldValue, newValue) {
+			changes = append(changes, FieldChange{Field: field, Change: FieldChanged, Old: oldValue, New: newValue})
+		}
+	}
+	for field, newValue := range newFields {
+		if _, ok := oldFields[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, Change: FieldAdded, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// DiffFiles compares two file lists keyed by URI.
+func DiffFiles(oldFiles, newFiles []searcher.FileInfo) []FileChange {
+	oldByURI := make(map[string]searcher.FileInfo)
+	for _, f := range oldFiles {
+		oldByURI[f.URI] = f
+	}
+	newByURI := make(map[string]searcher.FileInfo)
+	for _, f := range newFiles {
+		newByURI[f.URI] = f
+	}
+
+	var changes []FileChange
+	for uri, oldFile := range oldByURI {
+		newFile, ok := newByURI[uri]
+		if !ok {
+			changes = append(changes, FileChange{URI: uri, Change: FileRemoved, OldSize: oldFile.Size, OldChecksum: oldFile.Checksum})
+			continue
+		}
+		if oldFile.Size != newFile.Size {
+			changes = append(changes, FileChange{URI: uri, Change: FileResized, OldSize: oldFile.Size, NewSize: newFile.Size, OldChecksum: oldFile.Checksum, NewChecksum: newFile.Checksum})
+		} else if oldFile.Checksum != newFile.Checksum {
+			changes = append(changes, FileChange{URI: uri, Change: FileRechecksummed, OldSize: oldFile.Size, NewSize: newFile.Size, OldChecksum: oldFile.Checksum, NewChecksum: newFile.Checksum})
+		}
+	}
+	for uri, newFile := range newByURI {
+		if _, ok := oldByURI[uri]; !ok {
+			changes = append(changes, FileChange{URI: uri, Change: FileAdded, NewSize: newFile.Size, NewChecksum: newFile.Checksum})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].URI < changes[j].URI })
+	return changes
+}
+
+// Diff compares two record snapshots and their file lists.
+func Diff(oldMetadata, newMetadata map[string]any, oldFiles, newFiles []searcher.FileInfo) *Result {
+	return &Result{
+		Metadata: DiffMetadata(oldMetadata, newMetadata),
+		Files:    DiffFiles(oldFiles, newFiles),
+	}
+}
+
+// formatValue renders a metadata value compactly for text output.
+func formatValue(value any) string {
+	if value == nil {
+		return "<none>"
+	}
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(jsonBytes)
+}
+
+// FormatText renders a diff result in a human-readable form.
+func FormatText(r *Result) string {
+	if r.Empty() {
+		return "No differences found."
+	}
+
+	var lines []string
+	if len(r.Metadata) > 0 {
+		lines = append(lines, "Metadata:")
+		for _, c := range r.Metadata {
+			switch c.Change {
+			case FieldAdded:
+				lines = append(lines, fmt.Sprintf("  + %s: %s", c.Field, formatValue(c.New)))
+			case FieldRemoved:
+				lines = append(lines, fmt.Sprintf("  - %s: %s", c.Field, formatValue(c.Old)))
+			default:
+				lines = append(lines, fmt.Sprintf("  ~ %s: %s -> %s", c.Field, formatValue(c.Old), formatValue(c.New)))
+			}
+		}
+	}
+
+	if len(r.Files) > 0 {
+		lines = append(lines, "Files:")
+		for _, f := range r.Files {
+			switch f.Change {
+			case FileAdded:
+				lines = append(lines, fmt.Sprintf("  + %s (%s)", f.URI, utils.FormatBytes(float64(f.NewSize))))
+			case FileRemoved:
+				lines = append(lines, fmt.Sprintf("  - %s (%s)", f.URI, utils.FormatBytes(float64(f.OldSize))))
+			case FileResized:
+				lines = append(lines, fmt.Sprintf("  ~ %s: size %d -> %d", f.URI, f.OldSize, f.NewSize))
+			case FileRechecksummed:
+				lines = append(lines, fmt.Sprintf("  ~ %s: checksum %s -> %s", f.URI, f.OldChecksum, f.NewChecksum))
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,96 @@
+package differ
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+func TestDiffMetadata(t *testing.T) {
+	oldMetadata := map[string]any{
+		"recid":         float64(5500),
+		"title":         "Old title",
+		"doi":           "10.7483/test",
+		"system":        map[string]any{"global_tag": "FT_53_LV5_AN1", "release": "CMSSW_5_3_32"},
+		"files":         []any{map[string]any{"uri": "a"}},
+		"_file_indices": []any{},
+		"obsolete":      true,
+	}
+	newMetadata := map[string]any{
+		"recid":    5500,
+		"title":    "New title",
+		"doi":      "10.7483/test",
+		"system":   map[string]any{"global_tag": "FT_53_LV5_AN1", "release": "CMSSW_5_3_36"},
+		"files":    []any{map[string]any{"uri": "b"}},
+		"keywords": []any{"muon"},
+	}
+
+	changes := DiffMetadata(oldMetadata, newMetadata)
+
+	want := []FieldChange{
+		{Field: "keywords", Change: FieldAdded, New: []any{"muon"}},
+		{Field: "obsolete", Change: FieldRemoved, Old: true},
+		{Field: "system.release", Change: FieldChanged, Old: "CMSSW_5_3_32", New: "CMSSW_5_3_36"},
+		{Field: "title", Change: FieldChanged, Old: "Old title", New: "New title"},
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("DiffMetadata returned %d changes, want %d: %+v", len(changes), len(want), changes)
+	}
+	for i := range want {
+		if changes[i].Field != want[i].Field || changes[i].Change != want[i].Change || !equal(changes[i].Old, want[i].Old) || !equal(changes[i].New, want[i].New) {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	oldFiles := []searcher.FileInfo{
+		{URI: "root://eospublic.cern.ch//a.root", Size: 100, Checksum: "adler32:00000001"},
+		{URI: "root://eospublic.cern.ch//b.root", Size: 200, Checksum: "adler32:00000002"},
+		{URI: "root://eospublic.cern.ch//c.root", Size: 300, Checksum: "adler32:00000003"},
+		{URI: "root://eospublic.cern.ch//d.root", Size: 400, Checksum: "adler32:00000004"},
+	}
+	newFiles := []searcher.FileInfo{
+		{URI: "root://eospublic.cern.ch//a.root", Size: 100, Checksum: "adler32:00000001"},
+		{URI: "root://eospublic.cern.ch//b.root", Size: 250, Checksum: "adler32:00000022"},
+		{URI: "root://eospublic.cern.ch//c.root", Size: 300, Checksum: "adler32:00000033"},
+		{URI: "root://eospublic.cern.ch//e.root", Size: 500, Checksum: "adler32:00000005"},
+	}
+
+	changes := DiffFiles(oldFiles, newFiles)
+
+	want := map[string]string{
+		"root://eospublic.cern.ch//b.root": FileResized,
+		"root://eospublic.cern.ch//c.root": FileRechecksummed,
+		"root://eospublic.cern.ch//d.root": FileRemoved,
+		"root://eospublic.cern.ch//e.root": FileAdded,
+	}
+
+	if len(changes) != len(want) {
+		t.Fatalf("DiffFiles returned %d changes, want %d: %+v", len(changes), len(want), changes)
+	}
+	for _, c := range changes {
+		if want[c.URI] != c.Change {
+			t.Errorf("%s: change = %q, want %q", c.URI, c.Change, want[c.URI])
+		}
+	}
+}
+
+func TestFormatText(t *testing.T) {
+	if got := FormatText(&Result{}); got != "No differences found." {
+		t.Errorf("FormatText(empty) = %q", got)
+	}
+
+	result := &Result{
+		Metadata: []FieldChange{{Field: "title", Change: FieldChanged, Old: "a", New: "b"}, {Field: "note", Change: FieldAdded}},
+		Files:    []FileChange{{URI: "x.root", Change: FileAdded, NewSize: 2048}},
+	}
+	output := FormatText(result)
+	for _, exp := range []string{`~ title: "a" -> "b"`, "+ note: <none>", "+ x.root (2.0 KB)"} {
+		if !strings.Contains(output, exp) {
+			t.Errorf("expected output to contain %q, got:\n%s", exp, output)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,9 +10,11 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
 	"github.com/clelange/cernopendata-client-go/internal/config"
 	"github.com/clelange/cernopendata-client-go/internal/printer"
 	"github.com/clelange/cernopendata-client-go/internal/progress"
+	"github.com/clelange/cernopendata-client-go/internal/resumer"
 )
 
 type DownloadStats struct {
@@ -67,14 +70,34 @@ func (d *Downloader) DownloadFile(url, destPath string, resume bool, expectedSiz
 		if resume {
 			if fi, err := os.Stat(destPath); err == nil {
 				existingSize = fi.Size()
-				if existingSize > 0 {
-					printer.DisplayMessage(printer.Note, fmt.Sprintf("Resuming %s from %d bytes", destPath, existingSize))
-				}
 			} else if !os.IsNotExist(err) {
 				return nil, fmt.Errorf("error checking file: %w", err)
 			}
 		}
 
+		// Only append to the bytes that agree with the server.
+		if existingSize > 0 {
+			verified, err := resumer.VerifiedOffset(destPath, existingSize, d.fetchRange(url))
+			if errors.Is(err, errRangeIgnored) {
+				// The download below restarts from scratch.
+				verified = existingSize
+			} else if err != nil {
+				lastErr = err
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Failed to verify partial file: %v", err))
+				continue
+			}
+			if verified < existingSize {
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Partial file %s differs from the server after %d bytes", destPath, verified))
+				if err := os.Truncate(destPath, verified); err != nil {
+					return nil, fmt.Errorf("failed to truncate file: %w", err)
+				}
+				existingSize = verified
+			}
+			if existingSize > 0 {
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Resuming %s from %d bytes", destPath, existingSize))
+			}
+		}
+
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
@@ -174,6 +197,40 @@ func (d *Downloader) DownloadFile(url, destPath string, resume bool, expectedSiz
 	}, lastErr
 }
 
+// errRangeIgnored is returned when the server answers a range request with
+// the whole file.
+var errRangeIgnored = errors.New("server ignored the range request")
+
+// fetchRange returns a function that reads byte ranges of url, to compare
+// them with a partial file.
+func (d *Downloader) fetchRange(url string) resumer.FetchFunc {
+	return func(offset, length int64) ([]byte, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+		resp, err := d.client.Do(req) // #nosec G704
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			return io.ReadAll(io.LimitReader(resp.Body, length))
+		case http.StatusRequestedRangeNotSatisfiable:
+			// The remote file ends before offset.
+			return nil, nil
+		case http.StatusOK:
+			return nil, errRangeIgnored
+		default:
+			return nil, fmt.Errorf("server returned %d", resp.StatusCode)
+		}
+	}
+}
+
 func (d *Downloader) DownloadFiles(files []any, baseDir string, retry int, retrySleep int, verbose bool, dryRun bool, showProgress bool) DownloadStats {
 	d.retryLimit = retry
 	d.retrySleep = retrySleep
@@ -189,6 +246,9 @@ func (d *Downloader) DownloadFiles(files []any, baseDir string, retry int, retry
 		return stats
 	}
 
+	cache := checksum.NewCache()
+	defer func() { _ = cache.Save() }()
+
 	for i, file := range files {
 		fileMap, ok := file.(map[string]any)
 		if !ok {
@@ -199,14 +259,14 @@ func (d *Downloader) DownloadFiles(files []any, baseDir string, retry int, retry
 
 		uri, _ := fileMap["uri"].(string)
 		size, _ := fileMap["size"].(float64)
-		checksum, _ := fileMap["checksum"].(string)
+		expectedChecksum, _ := fileMap["checksum"].(string)
 
 		stats.TotalBytes += int64(size)
 
 		printer.DisplayMessage(printer.Info, fmt.Sprintf("Downloading file %d/%d: %s", i+1, stats.TotalFiles, filepath.Base(uri)))
 
 		if dryRun {
-			printer.DisplayMessage(printer.Note, fmt.Sprintf("Would download: %s (size: %d, checksum: %s)", uri, int64(size), checksum))
+			printer.DisplayMessage(printer.Note, fmt.Sprintf("Would download: %s (size: %d, checksum: %s)", uri, int64(size), expectedChecksum))
 			stats.DownloadedFiles++
 			stats.DownloadedBytes += int64(size)
 			continue
@@ -215,7 +275,22 @@ func (d *Downloader) DownloadFiles(files []any, baseDir string, retry int, retry
 		destPath := filepath.Join(baseDir, filepath.Base(uri))
 
 		if fi, err := os.Stat(destPath); err == nil {
-			if fi.Size() >= int64(size) {
+			// Prefer a checksum cached by verify-files over the size of
+			// the existing file. Shorter files are resumed instead.
+			if cached, match := cache.Matches(destPath, expectedChecksum); cached && expectedChecksum != "" && fi.Size() >= int64(size) {
+				if match {
+					printer.DisplayMessage(printer.Note, fmt.Sprintf("File already exists and is verified: %s", destPath))
+					stats.SkippedFiles++
+					continue
+				}
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Checksum of existing file does not match, downloading again: %s", destPath))
+				if err := os.Remove(destPath); err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to remove %s: %v", destPath, err))
+					stats.FailedFiles++
+					continue
+				}
+				cache.Forget(destPath)
+			} else if fi.Size() >= int64(size) {
 				printer.DisplayMessage(printer.Note, fmt.Sprintf("File already exists: %s", destPath))
 				stats.SkippedFiles++
 				continue
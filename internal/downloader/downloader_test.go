@@ -1,13 +1,18 @@
 package downloader
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
 	"github.com/clelange/cernopendata-client-go/internal/utils"
 )
 
@@ -384,6 +389,9 @@ func TestDownloadFileResumeRetryUsesUpdatedRange(t *testing.T) {
 
 	callCount := 0
 	handler := func(w http.ResponseWriter, r *http.Request) {
+		if serveProbe(w, r, content) {
+			return
+		}
 		callCount++
 		switch callCount {
 		case 1:
@@ -441,6 +449,132 @@ func TestDownloadFileResumeRetryUsesUpdatedRange(t *testing.T) {
 	}
 }
 
+// probePattern matches the closed ranges with which a partial file is
+// verified before it is resumed.
+var probePattern = regexp.MustCompile(`^bytes=\d+-\d+$`)
+
+// serveProbe answers a verification request for a partial file from content
+// and reports whether r was one.
+func serveProbe(w http.ResponseWriter, r *http.Request, content []byte) bool {
+	if !probePattern.MatchString(r.Header.Get("Range")) {
+		return false
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	return true
+}
+
+func TestDownloadFileResumeVerifiesPartialFile(t *testing.T) {
+	content := make([]byte, 200*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+
+	tests := []struct {
+		name      string
+		partial   func() []byte
+		wantRange string
+	}{
+		{
+			name:      "intact prefix",
+			partial:   func() []byte { return bytes.Clone(content[:150*1024]) },
+			wantRange: fmt.Sprintf("bytes=%d-", 150*1024),
+		},
+		{
+			name: "corrupt tail",
+			partial: func() []byte {
+				partial := bytes.Clone(content[:150*1024])
+				partial[140*1024] ^= 0xff
+				return partial
+			},
+			wantRange: fmt.Sprintf("bytes=%d-", 140*1024),
+		},
+		{
+			name: "corrupt before the tail",
+			partial: func() []byte {
+				partial := bytes.Clone(content[:150*1024])
+				for i := 10 * 1024; i < len(partial); i++ {
+					partial[i] = 0
+				}
+				return partial
+			},
+			wantRange: fmt.Sprintf("bytes=%d-", 10*1024),
+		},
+		{
+			name:      "different file",
+			partial:   func() []byte { return bytes.Repeat([]byte{0xff}, 150*1024) },
+			wantRange: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloadRange := "none"
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if serveProbe(w, r, content) {
+					return
+				}
+				downloadRange = r.Header.Get("Range")
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}
+			server := httptest.NewServer(http.HandlerFunc(handler))
+			defer server.Close()
+
+			destPath := filepath.Join(t.TempDir(), "testfile.dat")
+			if err := os.WriteFile(destPath, tt.partial(), 0600); err != nil {
+				t.Fatalf("Failed to create partial file: %v", err)
+			}
+
+			d := &Downloader{
+				client:     server.Client(),
+				retryLimit: 1,
+				retrySleep: 0,
+			}
+			result, err := d.DownloadFile(server.URL+"/testfile.dat", destPath, true, int64(len(content)))
+			if err != nil || !result.Success {
+				t.Fatalf("DownloadFile() error = %v", err)
+			}
+
+			if downloadRange != tt.wantRange {
+				t.Errorf("download Range header = %q, want %q", downloadRange, tt.wantRange)
+			}
+			final, err := os.ReadFile(destPath) // #nosec G304 -- test file path
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if !bytes.Equal(final, content) {
+				t.Errorf("resumed file differs from the server's")
+			}
+		})
+	}
+}
+
+func TestDownloadFileResumeRangeIgnored(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("full content"))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	destPath := filepath.Join(t.TempDir(), "testfile.txt")
+	if err := os.WriteFile(destPath, []byte("partial"), 0600); err != nil {
+		t.Fatalf("Failed to create partial file: %v", err)
+	}
+
+	d := &Downloader{
+		client:     server.Client(),
+		retryLimit: 1,
+		retrySleep: 0,
+	}
+	if _, err := d.DownloadFile(server.URL+"/testfile.txt", destPath, true, 0); err != nil {
+		t.Fatalf("DownloadFile() error = %v", err)
+	}
+
+	final, _ := os.ReadFile(destPath) // #nosec G304 -- test file path
+	if string(final) != "full content" {
+		t.Errorf("File content = %q, want 'full content'", string(final))
+	}
+}
+
 func TestDownloadFiles(t *testing.T) {
 	downloadCount := 0
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -574,6 +708,63 @@ func TestDownloadFilesSkipExisting(t *testing.T) {
 	}
 }
 
+func TestDownloadFilesCachedChecksum(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("new content"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	goodFile := filepath.Join(tmpDir, "good.txt")
+	badFile := filepath.Join(tmpDir, "bad.txt")
+	for _, path := range []string{goodFile, badFile} {
+		if err := os.WriteFile(path, []byte("new content"), 0600); err != nil {
+			t.Fatalf("Failed to create existing file: %v", err)
+		}
+	}
+
+	// Record the checksums as verify-files would.
+	cache := checksum.NewCache()
+	for path, sum := range map[string]string{goodFile: "adler32:19aa0466", badFile: "adler32:00000000"} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Store(path, info, sum); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []any{
+		map[string]any{"uri": server.URL + "/good.txt", "size": float64(11), "checksum": "adler32:19aa0466"},
+		map[string]any{"uri": server.URL + "/bad.txt", "size": float64(11), "checksum": "adler32:19aa0466"},
+	}
+
+	d := &Downloader{
+		client:     server.Client(),
+		retryLimit: 1,
+		retrySleep: 0,
+	}
+
+	stats := d.DownloadFiles(files, tmpDir, 1, 0, false, false, false)
+
+	if stats.SkippedFiles != 1 {
+		t.Errorf("SkippedFiles = %d, want 1 (verified file)", stats.SkippedFiles)
+	}
+	if stats.DownloadedFiles != 1 {
+		t.Errorf("DownloadedFiles = %d, want 1 (file with mismatching cached checksum)", stats.DownloadedFiles)
+	}
+	if _, ok := checksum.NewCache().Lookup(badFile, "adler32"); ok {
+		t.Error("Cache entry of the downloaded file was not removed")
+	}
+}
+
 func TestDownloadFilesInvalidEntry(t *testing.T) {
 	files := []any{
 		"not a map", // invalid entry
@@ -595,6 +786,9 @@ func TestDownloadFilesInvalidEntry(t *testing.T) {
 
 func TestDownloadFilesResumePartial(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
+		if serveProbe(w, r, []byte("existingresumed")) {
+			return
+		}
 		rangeHeader := r.Header.Get("Range")
 		if rangeHeader != "" {
 			// Expecting Range: bytes=8-
@@ -0,0 +1,252 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+// Supported fileset formats.
+const (
+	FormatCMSSW  = "cmssw"
+	FormatText   = "txt"
+	FormatCoffea = "coffea"
+	FormatTChain = "tchain"
+)
+
+// cmsswBlockSize is the number of file names per readFiles.extend call;
+// python functions accept at most 255 arguments.
+const cmsswBlockSize = 255
+
+// Dataset is a named list of files, typically the files of one record.
+type Dataset struct {
+	Name  string
+	Recid int
+	Files []searcher.FileInfo
+}
+
+// Extensions maps each format to the file extension used for its output.
+var Extensions = map[string]string{
+	FormatCMSSW:  ".py",
+	FormatText:   ".txt",
+	FormatCoffea: ".json",
+	FormatTChain: ".C",
+}
+
+// DatasetName returns a name for a record: its title, or else its record ID.
+func DatasetName(metadata map[string]any, recid int) string {
+	if title, ok := metadata["title"].(string); ok && title != "" {
+		return title
+	}
+	return fmt.Sprintf("recid_%d", recid)
+}
+
+// FilterFiles keeps the files whose base name matches one of the glob
+// patterns in names (if any) and whose URI matches pattern (if set).
+func FilterFiles(files []searcher.FileInfo, names []string, pattern string) ([]searcher.FileInfo, error) {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+	}
+
+	var result []searcher.FileInfo
+	for _, file := range files {
+		if len(names) > 0 {
+			matched := false
+			for _, name := range names {
+				if ok, _ := filepath.Match(name, filepath.Base(file.URI)); ok {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		if re != nil && !re.MatchString(file.URI) {
+			continue
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
+
+// TotalSize returns the total size of the files in the datasets.
+func TotalSize(datasets []Dataset) int64 {
+	var total int64
+	for _, dataset := range datasets {
+		for _, file := range dataset.Files {
+			total += file.Size
+		}
+	}
+	return total
+}
+
+// Split distributes the files of the datasets over n chunks of roughly equal
+// total size. Each chunk keeps the datasets and the file order of the input;
+// datasets without files in a chunk are left out of it.
+func Split(datasets []Dataset, n int) [][]Dataset {
+	if n <= 1 {
+		return [][]Dataset{datasets}
+	}
+
+	type ref struct {
+		dataset, file int
+		size          int64
+	}
+	var refs []ref
+	for i, dataset := range datasets {
+		for j, file := range dataset.Files {
+			refs = append(refs, ref{i, j, file.Size})
+		}
+	}
+	if n > len(refs) {
+		n = len(refs)
+	}
+	if n <= 1 {
+		return [][]Dataset{datasets}
+	}
+
+	// Assign the largest files first, each to the currently smallest chunk.
+	order := make([]int, len(refs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return refs[order[a]].size > refs[order[b]].size })
+
+	sizes := make([]int64, n)
+	assigned := make([]int, len(refs))
+	for _, idx := range order {
+		smallest := 0
+		for c := 1; c < n; c++ {
+			if sizes[c] < sizes[smallest] {
+				smallest = c
+			}
+		}
+		assigned[idx] = smallest
+		sizes[smallest] += refs[idx].size
+	}
+
+	chunks := make([][]Dataset, n)
+	for c := range chunks {
+		for i, dataset := range datasets {
+			part := Dataset{Name: dataset.Name, Recid: dataset.Recid}
+			for idx, r := range refs {
+				if r.dataset == i && assigned[idx] == c {
+					part.Files = append(part.Files, dataset.Files[r.file])
+				}
+			}
+			if len(part.Files) > 0 {
+				chunks[c] = append(chunks[c], part)
+			}
+		}
+	}
+	return chunks
+}
+
+// Format renders the datasets in the given format. treeName is the name of
+// the TTree read by coffea and TChain.
+func Format(datasets []Dataset, format, treeName string) (string, error) {
+	switch format {
+	case FormatCMSSW:
+		return formatCMSSW(datasets), nil
+	case FormatText:
+		return formatText(datasets), nil
+	case FormatCoffea:
+		return formatCoffea(datasets, treeName)
+	case FormatTChain:
+		return formatTChain(datasets, treeName), nil
+	default:
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func formatText(datasets []Dataset) string {
+	var b strings.Builder
+	for _, dataset := range datasets {
+		for _, file := range dataset.Files {
+			b.WriteString(file.URI + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func formatCMSSW(datasets []Dataset) string {
+	var b strings.Builder
+	b.WriteString("import FWCore.ParameterSet.Config as cms\n\n")
+	b.WriteString("readFiles = cms.untracked.vstring()\n")
+	for _, dataset := range datasets {
+		b.WriteString(fmt.Sprintf("\n# %s (recid %d)\n", dataset.Name, dataset.Recid))
+		for start := 0; start < len(dataset.Files); start += cmsswBlockSize {
+			end := min(start+cmsswBlockSize, len(dataset.Files))
+			b.WriteString("readFiles.extend([\n")
+			for _, file := range dataset.Files[start:end] {
+				b.WriteString(fmt.Sprintf("    '%s',\n", file.URI))
+			}
+			b.WriteString("])\n")
+		}
+	}
+	b.WriteString("\nsource = cms.Source(\"PoolSource\", fileNames=readFiles)")
+	return b.String()
+}
+
+func formatCoffea(datasets []Dataset, treeName string) (string, error) {
+	type coffeaDataset struct {
+		Files    map[string]string `json:"files"`
+		Metadata map[string]any    `json:"metadata"`
+	}
+	fileset := make(map[string]*coffeaDataset)
+	for _, dataset := range datasets {
+		entry, ok := fileset[dataset.Name]
+		if !ok {
+			entry = &coffeaDataset{
+				Files:    make(map[string]string),
+				Metadata: map[string]any{"recid": dataset.Recid},
+			}
+			fileset[dataset.Name] = entry
+		}
+		for _, file := range dataset.Files {
+			entry.Files[file.URI] = treeName
+		}
+	}
+	jsonBytes, err := json.MarshalIndent(fileset, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func formatTChain(datasets []Dataset, treeName string) string {
+	var b strings.Builder
+	b.WriteString("// Load with .L <file> and call makeChain(), e.g. ROOT::RDataFrame df(*makeChain());\n")
+	b.WriteString("TChain *makeChain()\n{\n")
+	b.WriteString(fmt.Sprintf("  TChain *chain = new TChain(\"%s\");\n", treeName))
+	for _, dataset := range datasets {
+		b.WriteString(fmt.Sprintf("  // %s (recid %d)\n", dataset.Name, dataset.Recid))
+		for _, file := range dataset.Files {
+			b.WriteString(fmt.Sprintf("  chain->Add(\"%s\");\n", file.URI))
+		}
+	}
+	b.WriteString("  return chain;\n}")
+	return b.String()
+}
+
+// ChunkPath returns the output path of chunk i out of n, inserting a
+// zero-padded index before the extension of path.
+func ChunkPath(path string, i, n int) string {
+	if n <= 1 {
+		return path
+	}
+	width := len(fmt.Sprintf("%d", n-1))
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%0*d%s", strings.TrimSuffix(path, ext), width, i, ext)
+}
@@ -0,0 +1,184 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/searcher"
+)
+
+func testDatasets() []Dataset {
+	return []Dataset{
+		{
+			Name:  "/DoubleMuParked/Run2012B-22Jan2013-v1/AOD",
+			Recid: 6004,
+			Files: []searcher.FileInfo{
+				{URI: "root://eospublic.cern.ch//eos/a.root", Size: 100},
+				{URI: "root://eospublic.cern.ch//eos/b.root", Size: 300},
+			},
+		},
+		{
+			Name:  "/DoubleElectron/Run2012B-22Jan2013-v1/AOD",
+			Recid: 6021,
+			Files: []searcher.FileInfo{
+				{URI: "root://eospublic.cern.ch//eos/c.root", Size: 200},
+			},
+		},
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{
+			format: FormatText,
+			contains: []string{
+				"root://eospublic.cern.ch//eos/a.root\nroot://eospublic.cern.ch//eos/b.root\nroot://eospublic.cern.ch//eos/c.root",
+			},
+		},
+		{
+			format: FormatCMSSW,
+			contains: []string{
+				"import FWCore.ParameterSet.Config as cms",
+				"readFiles = cms.untracked.vstring()",
+				"# /DoubleMuParked/Run2012B-22Jan2013-v1/AOD (recid 6004)",
+				"    'root://eospublic.cern.ch//eos/a.root',\n",
+				`source = cms.Source("PoolSource", fileNames=readFiles)`,
+			},
+		},
+		{
+			format: FormatTChain,
+			contains: []string{
+				`TChain *chain = new TChain("Events");`,
+				`chain->Add("root://eospublic.cern.ch//eos/c.root");`,
+				"return chain;",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := Format(testDatasets(), tt.format, "Events")
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("output missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := Format(testDatasets(), "xml", "Events"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestFormatCoffea(t *testing.T) {
+	got, err := Format(testDatasets(), FormatCoffea, "Events")
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	var fileset map[string]struct {
+		Files    map[string]string `json:"files"`
+		Metadata map[string]any    `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(got), &fileset); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, got)
+	}
+
+	dataset := fileset["/DoubleMuParked/Run2012B-22Jan2013-v1/AOD"]
+	if len(dataset.Files) != 2 || dataset.Files["root://eospublic.cern.ch//eos/b.root"] != "Events" {
+		t.Errorf("unexpected files: %v", dataset.Files)
+	}
+	if dataset.Metadata["recid"] != float64(6004) {
+		t.Errorf("unexpected metadata: %v", dataset.Metadata)
+	}
+}
+
+func TestFormatCMSSWBlocks(t *testing.T) {
+	dataset := Dataset{Name: "big", Recid: 1}
+	for i := 0; i < cmsswBlockSize+1; i++ {
+		dataset.Files = append(dataset.Files, searcher.FileInfo{URI: fmt.Sprintf("root://host//f%d.root", i)})
+	}
+	got, _ := Format([]Dataset{dataset}, FormatCMSSW, "Events")
+	if n := strings.Count(got, "readFiles.extend(["); n != 2 {
+		t.Errorf("got %d extend blocks, want 2", n)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	chunks := Split(testDatasets(), 2)
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+
+	// b.root (300) goes alone; a.root (100) and c.root (200) share a chunk.
+	if size := TotalSize(chunks[0]); size != 300 {
+		t.Errorf("chunk 0 size = %d, want 300", size)
+	}
+	if size := TotalSize(chunks[1]); size != 300 {
+		t.Errorf("chunk 1 size = %d, want 300", size)
+	}
+	if len(chunks[1]) != 2 || chunks[1][0].Recid != 6004 || chunks[1][1].Recid != 6021 {
+		t.Errorf("chunk 1 should keep both datasets in order: %+v", chunks[1])
+	}
+
+	if got := Split(testDatasets(), 10); len(got) != 3 {
+		t.Errorf("got %d chunks for 3 files, want 3", len(got))
+	}
+	if got := Split(testDatasets(), 1); len(got) != 1 || TotalSize(got[0]) != 600 {
+		t.Errorf("Split(1) = %+v", got)
+	}
+}
+
+func TestFilterFiles(t *testing.T) {
+	files := testDatasets()[0].Files
+
+	got, err := FilterFiles(files, []string{"b.*"}, "")
+	if err != nil || len(got) != 1 || got[0].Size != 300 {
+		t.Errorf("FilterFiles(name) = %v, %v", got, err)
+	}
+
+	got, err = FilterFiles(files, nil, `a\.root$`)
+	if err != nil || len(got) != 1 || got[0].Size != 100 {
+		t.Errorf("FilterFiles(regexp) = %v, %v", got, err)
+	}
+
+	if _, err := FilterFiles(files, nil, "("); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+}
+
+func TestChunkPath(t *testing.T) {
+	tests := []struct {
+		path string
+		i, n int
+		want string
+	}{
+		{path: "files.txt", i: 0, n: 1, want: "files.txt"},
+		{path: "files.txt", i: 3, n: 5, want: "files_3.txt"},
+		{path: "jobs/files.txt", i: 3, n: 12, want: "jobs/files_03.txt"},
+		{path: "fileset", i: 1, n: 2, want: "fileset_1"},
+	}
+	for _, tt := range tests {
+		if got := ChunkPath(tt.path, tt.i, tt.n); got != tt.want {
+			t.Errorf("ChunkPath(%q, %d, %d) = %q, want %q", tt.path, tt.i, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDatasetName(t *testing.T) {
+	if got := DatasetName(map[string]any{"title": "/A/B/AOD"}, 1); got != "/A/B/AOD" {
+		t.Errorf("DatasetName(title) = %q", got)
+	}
+	if got := DatasetName(map[string]any{}, 42); got != "recid_42" {
+		t.Errorf("DatasetName(no title) = %q", got)
+	}
+}
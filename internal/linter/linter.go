@@ -0,0 +1,241 @@
+package linter
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+)
+
+// Severities of findings.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Finding is a problem found in record metadata. Pointer is the JSON
+// pointer (RFC 6901) of the offending value; the empty pointer is the
+// whole record.
+type Finding struct {
+	Pointer  string `json:"pointer"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Rule is a consistency check on record metadata.
+type Rule struct {
+	Name        string
+	Description string
+	Check       func(metadata map[string]any) []Finding
+}
+
+//go:embed schemas/record.json
+var recordSchema []byte
+
+// DefaultSchema returns the bundled schema of the core record fields.
+func DefaultSchema() *Schema {
+	schema, err := ParseSchema(recordSchema)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+var (
+	doiPattern = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+)
+
+// Rules are the consistency checks applied besides the schema.
+var Rules = []Rule{
+	{Name: "file-size", Description: "every file has a non-negative integer size", Check: checkFileSizes},
+	{Name: "checksum-format", Description: "file checksums are <algorithm>:<lower-case hex digest> values of a known algorithm", Check: checkChecksums},
+	{Name: "doi-format", Description: "the DOI has the form 10.<registrant>/<suffix>", Check: checkDOI},
+	{Name: "distribution-totals", Description: "the file index totals match distribution.number_files and distribution.size", Check: checkDistributionTotals},
+}
+
+// fileEntry is a file object with its JSON pointer.
+type fileEntry struct {
+	pointer string
+	file    map[string]any
+}
+
+// recordFiles returns the files of a record, both listed directly and in
+// file indices, and the files of the indices alone.
+func recordFiles(metadata map[string]any) (all, indexed []fileEntry) {
+	if files, ok := metadata["files"].([]any); ok {
+		for i, f := range files {
+			if file, ok := f.(map[string]any); ok {
+				all = append(all, fileEntry{Pointer(Pointer("", "files"), i), file})
+			}
+		}
+	}
+	for _, field := range []string{"_file_indices", "file_indices"} {
+		indices, ok := metadata[field].([]any)
+		if !ok {
+			continue
+		}
+		for i, idx := range indices {
+			index, ok := idx.(map[string]any)
+			if !ok {
+				continue
+			}
+			files, _ := index["files"].([]any)
+			for j, f := range files {
+				if file, ok := f.(map[string]any); ok {
+					entry := fileEntry{Pointer(Pointer(Pointer(Pointer("", field), i), "files"), j), file}
+					all = append(all, entry)
+					indexed = append(indexed, entry)
+				}
+			}
+		}
+	}
+	return all, indexed
+}
+
+// integer returns a non-negative integer value given as number or string.
+func integer(value any) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), v >= 0 && v == float64(int64(v))
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n, err == nil && n >= 0
+	}
+	return 0, false
+}
+
+func checkFileSizes(metadata map[string]any) []Finding {
+	var findings []Finding
+	all, _ := recordFiles(metadata)
+	for _, entry := range all {
+		size, ok := entry.file["size"]
+		if !ok {
+			findings = append(findings, Finding{Pointer: entry.pointer, Severity: SeverityError, Message: "file has no size"})
+			continue
+		}
+		if _, ok := integer(size); !ok {
+			findings = append(findings, Finding{Pointer: Pointer(entry.pointer, "size"), Severity: SeverityError, Message: fmt.Sprintf("size %v is not a non-negative integer", size)})
+		}
+	}
+	return findings
+}
+
+func checkChecksums(metadata map[string]any) []Finding {
+	var findings []Finding
+	all, _ := recordFiles(metadata)
+	for _, entry := range all {
+		value, ok := entry.file["checksum"]
+		if !ok {
+			findings = append(findings, Finding{Pointer: entry.pointer, Severity: SeverityWarning, Message: "file has no checksum"})
+			continue
+		}
+		pointer := Pointer(entry.pointer, "checksum")
+		sum, ok := value.(string)
+		if !ok {
+			findings = append(findings, Finding{Pointer: pointer, Severity: SeverityError, Message: fmt.Sprintf("checksum %v is not a string", value)})
+			continue
+		}
+		algorithm, digest, err := checksum.Parse(sum)
+		if err != nil {
+			findings = append(findings, Finding{Pointer: pointer, Severity: SeverityError, Message: err.Error()})
+		} else if sum != algorithm+":"+digest {
+			findings = append(findings, Finding{Pointer: pointer, Severity: SeverityError, Message: fmt.Sprintf("checksum %s is not in lower case", sum)})
+		}
+	}
+	return findings
+}
+
+func checkDOI(metadata map[string]any) []Finding {
+	value, ok := metadata["doi"]
+	if !ok {
+		return nil
+	}
+	if doi, ok := value.(string); !ok || !doiPattern.MatchString(doi) {
+		return []Finding{{Pointer: "/doi", Severity: SeverityError, Message: fmt.Sprintf("DOI %v does not have the form 10.<registrant>/<suffix>", value)}}
+	}
+	return nil
+}
+
+func checkDistributionTotals(metadata map[string]any) []Finding {
+	distribution, ok := metadata["distribution"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	_, indexed := recordFiles(metadata)
+	if len(indexed) == 0 {
+		return nil
+	}
+
+	var size int64
+	for _, entry := range indexed {
+		n, _ := integer(entry.file["size"])
+		size += n
+	}
+
+	var findings []Finding
+	if value, ok := distribution["number_files"]; ok {
+		if n, ok := integer(value); ok && n != int64(len(indexed)) {
+			findings = append(findings, Finding{Pointer: "/distribution/number_files", Severity: SeverityError, Message: fmt.Sprintf("number_files is %d but the file indices list %d files", n, len(indexed))})
+		}
+	}
+	if value, ok := distribution["size"]; ok {
+		if n, ok := integer(value); ok && n != size {
+			findings = append(findings, Finding{Pointer: "/distribution/size", Severity: SeverityError, Message: fmt.Sprintf("size is %d but the files in the file indices add up to %d", n, size)})
+		}
+	}
+	return findings
+}
+
+// Lint checks metadata against the schema (if not nil) and the rules (if
+// withRules is set). Findings are ordered by pointer.
+func Lint(metadata map[string]any, schema *Schema, withRules bool) []Finding {
+	var findings []Finding
+	if schema != nil {
+		findings = append(findings, schema.Validate(metadata)...)
+	}
+	if withRules {
+		for _, rule := range Rules {
+			for _, finding := range rule.Check(metadata) {
+				finding.Rule = rule.Name
+				findings = append(findings, finding)
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Pointer < findings[j].Pointer })
+	return findings
+}
+
+// Count returns the number of errors and warnings.
+func Count(findings []Finding) (errors, warnings int) {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// Text renders the findings for a record, one per line.
+func Text(source string, findings []Finding) string {
+	errors, warnings := Count(findings)
+	if len(findings) == 0 {
+		return source + ": OK"
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s: %d error(s), %d warning(s)", source, errors, warnings))
+	for _, finding := range findings {
+		pointer := finding.Pointer
+		if pointer == "" {
+			pointer = "(record)"
+		}
+		b.WriteString(fmt.Sprintf("\n  %-7s %s: %s [%s]", finding.Severity, pointer, finding.Message, finding.Rule))
+	}
+	return b.String()
+}
@@ -0,0 +1,257 @@
+package linter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validRecord() map[string]any {
+	return map[string]any{
+		"recid": "6004",
+		"title": "/DoubleMuParked/Run2012B-22Jan2013-v1/AOD",
+		"doi":   "10.7483/OPENDATA.CMS.YLIC.86ZZ",
+		"type":  map[string]any{"primary": "Dataset", "secondary": []any{"Collision"}},
+		"distribution": map[string]any{
+			"number_files": float64(2),
+			"size":         float64(300),
+		},
+		"files": []any{
+			map[string]any{"uri": "root://eospublic.cern.ch//eos/index.txt", "size": float64(10), "checksum": "adler32:0a1b2c3d"},
+		},
+		"_file_indices": []any{
+			map[string]any{
+				"key": "index.txt",
+				"files": []any{
+					map[string]any{"uri": "root://eospublic.cern.ch//eos/a.root", "size": float64(100), "checksum": "adler32:00000001"},
+					map[string]any{"uri": "root://eospublic.cern.ch//eos/b.root", "size": float64(200), "checksum": "adler32:00000002"},
+				},
+			},
+		},
+	}
+}
+
+func TestLintValid(t *testing.T) {
+	if findings := Lint(validRecord(), DefaultSchema(), true); len(findings) != 0 {
+		t.Errorf("expected no findings, got %+v", findings)
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(map[string]any)
+		want   []Finding
+	}{
+		{
+			name: "missing size",
+			modify: func(m map[string]any) {
+				delete(m["files"].([]any)[0].(map[string]any), "size")
+			},
+			want: []Finding{{Pointer: "/files/0", Rule: "file-size", Severity: SeverityError}},
+		},
+		{
+			name: "bad checksum",
+			modify: func(m map[string]any) {
+				m["files"].([]any)[0].(map[string]any)["checksum"] = "md5:abc"
+			},
+			want: []Finding{{Pointer: "/files/0/checksum", Rule: "checksum-format", Severity: SeverityError}},
+		},
+		{
+			name: "missing checksum",
+			modify: func(m map[string]any) {
+				delete(m["files"].([]any)[0].(map[string]any), "checksum")
+			},
+			want: []Finding{{Pointer: "/files/0", Rule: "checksum-format", Severity: SeverityWarning}},
+		},
+		{
+			name: "unknown algorithm",
+			modify: func(m map[string]any) {
+				m["files"].([]any)[0].(map[string]any)["checksum"] = "blake3:0a1b2c3d"
+			},
+			want: []Finding{{Pointer: "/files/0/checksum", Rule: "checksum-format", Severity: SeverityError}},
+		},
+		{
+			name: "upper case checksum",
+			modify: func(m map[string]any) {
+				m["files"].([]any)[0].(map[string]any)["checksum"] = "adler32:0A1B2C3D"
+			},
+			want: []Finding{{Pointer: "/files/0/checksum", Rule: "checksum-format", Severity: SeverityError}},
+		},
+		{
+			name: "sha256 checksum",
+			modify: func(m map[string]any) {
+				m["files"].([]any)[0].(map[string]any)["checksum"] = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+			},
+		},
+		{
+			name:   "bad doi",
+			modify: func(m map[string]any) { m["doi"] = "doi:10.7483/X" },
+			want:   []Finding{{Pointer: "/doi", Rule: "doi-format", Severity: SeverityError}},
+		},
+		{
+			name: "distribution totals",
+			modify: func(m map[string]any) {
+				m["distribution"] = map[string]any{"number_files": float64(3), "size": float64(310)}
+			},
+			want: []Finding{
+				{Pointer: "/distribution/number_files", Rule: "distribution-totals", Severity: SeverityError},
+				{Pointer: "/distribution/size", Rule: "distribution-totals", Severity: SeverityError},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := validRecord()
+			tt.modify(record)
+			got := Lint(record, nil, true)
+			for i := range got {
+				got[i].Message = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(map[string]any)
+		want   []string
+	}{
+		{
+			name:   "missing title",
+			modify: func(m map[string]any) { delete(m, "title") },
+			want:   []string{""},
+		},
+		{
+			name:   "wrong type",
+			modify: func(m map[string]any) { m["distribution"].(map[string]any)["size"] = "big" },
+			want:   []string{"/distribution/size"},
+		},
+		{
+			name:   "negative number",
+			modify: func(m map[string]any) { m["distribution"].(map[string]any)["number_files"] = float64(-1) },
+			want:   []string{"/distribution/number_files"},
+		},
+		{
+			name:   "recid pattern",
+			modify: func(m map[string]any) { m["recid"] = "abc" },
+			want:   []string{"/recid"},
+		},
+		{
+			name: "ref to file definition",
+			modify: func(m map[string]any) {
+				indices := m["_file_indices"].([]any)
+				delete(indices[0].(map[string]any)["files"].([]any)[1].(map[string]any), "uri")
+			},
+			want: []string{"/_file_indices/0/files/1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := validRecord()
+			tt.modify(record)
+			var got []string
+			for _, finding := range DefaultSchema().Validate(record) {
+				got = append(got, finding.Pointer)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pointers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{
+		"type": "object",
+		"additionalProperties": false,
+		"properties": {
+			"a/b": {"enum": ["x", "y"]},
+			"list": {"type": "array", "minItems": 1, "uniqueItems": true},
+			"choice": {"oneOf": [{"type": "string"}, {"type": "integer"}]},
+			"ratio": {"type": "number", "exclusiveMinimum": 0}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	doc := map[string]any{
+		"a/b":    "z",
+		"list":   []any{"a", "a"},
+		"choice": true,
+		"ratio":  float64(0),
+		"extra":  float64(1),
+	}
+	var got []string
+	for _, finding := range schema.Validate(doc) {
+		got = append(got, finding.Pointer)
+	}
+	want := []string{"/a~1b", "/choice", "/extra", "/list", "/ratio"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pointers = %q, want %q", got, want)
+	}
+
+	if _, err := ParseSchema([]byte("[")); err == nil {
+		t.Error("expected error for invalid schema")
+	}
+}
+
+func TestSchemaUnsupported(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"files": {"type": "array", "items": {"$ref": "file-v1.0.0.json#/definitions/file"}},
+			"title": {"type": "string", "pattern": "^(?!test)"},
+			"doi": {"anyOf": [{"$ref": "#/definitions/missing"}, {"type": "string"}]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	doc := map[string]any{
+		"files": []any{map[string]any{"uri": "a"}, map[string]any{"uri": "b"}},
+		"title": "x",
+		"doi":   "10.7483/OPENDATA.CMS.1",
+	}
+	var got []string
+	for _, finding := range schema.Validate(doc) {
+		if finding.Rule != "schema-unsupported" || finding.Severity != SeverityWarning {
+			t.Errorf("unexpected finding: %+v", finding)
+		}
+		got = append(got, finding.Pointer)
+	}
+	// Each unsupported reference or pattern is reported once.
+	want := []string{"/doi", "/files/0", "/title"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pointers = %q, want %q", got, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	if got := Text("record.json", nil); got != "record.json: OK" {
+		t.Errorf("Text(no findings) = %q", got)
+	}
+
+	findings := []Finding{
+		{Pointer: "", Rule: "schema", Severity: SeverityError, Message: `missing required property "title"`},
+		{Pointer: "/files/0", Rule: "checksum-format", Severity: SeverityWarning, Message: "file has no checksum"},
+	}
+	got := Text("record 6004", findings)
+	for _, want := range []string{
+		"record 6004: 1 error(s), 1 warning(s)",
+		`  error   (record): missing required property "title" [schema]`,
+		"  warning /files/0: file has no checksum [checksum-format]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("text missing %q:\n%s", want, got)
+		}
+	}
+}
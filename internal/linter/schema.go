@@ -0,0 +1,383 @@
+package linter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is a JSON Schema. The validator supports the keywords used by
+// record schemas: type, enum, const, required, properties,
+// patternProperties, additionalProperties, items, minItems, maxItems,
+// uniqueItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf, not and local
+// $ref references. Other keywords are ignored. References to other schema
+// files and patterns that Go's regexp package cannot compile are reported
+// as warnings with rule "schema-unsupported", since the parts of the
+// document they apply to are not checked.
+type Schema struct {
+	root     map[string]any
+	patterns map[string]compiledPattern
+	// reported holds the unsupported references and patterns already
+	// reported by the current validation.
+	reported map[string]bool
+}
+
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// ParseSchema decodes a JSON Schema document.
+func ParseSchema(data []byte) (*Schema, error) {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to decode schema: %w", err)
+	}
+	return &Schema{root: root, patterns: make(map[string]compiledPattern)}, nil
+}
+
+// Validate checks a document against the schema and returns a finding with
+// rule "schema" for each violation, and a warning with rule
+// "schema-unsupported" for each reference or pattern that cannot be
+// applied, at the first place it is needed.
+func (s *Schema) Validate(doc any) []Finding {
+	s.reported = make(map[string]bool)
+	var findings []Finding
+	s.validate(s.root, doc, "", &findings, 0)
+	return findings
+}
+
+func escapeToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func unescapeToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// Pointer appends a reference token to a JSON pointer.
+func Pointer(parent string, token any) string {
+	switch t := token.(type) {
+	case int:
+		return parent + "/" + strconv.Itoa(t)
+	default:
+		return parent + "/" + escapeToken(fmt.Sprint(t))
+	}
+}
+
+// resolve follows a local reference such as #/definitions/file.
+func (s *Schema) resolve(ref string) (map[string]any, bool) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, false
+	}
+	var current any = s.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if token == "" {
+			continue
+		}
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current = m[unescapeToken(token)]
+	}
+	m, ok := current.(map[string]any)
+	return m, ok
+}
+
+func (s *Schema) pattern(expr string) (*regexp.Regexp, error) {
+	if p, ok := s.patterns[expr]; ok {
+		return p.re, p.err
+	}
+	re, err := regexp.Compile(expr)
+	s.patterns[expr] = compiledPattern{re: re, err: err}
+	return re, err
+}
+
+// hasErrors reports whether any of the findings is an error.
+func hasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// warningsOf returns the findings that are not errors.
+func warningsOf(findings []Finding) []Finding {
+	var result []Finding
+	for _, finding := range findings {
+		if finding.Severity != SeverityError {
+			result = append(result, finding)
+		}
+	}
+	return result
+}
+
+// typeOf returns the JSON type of a decoded value.
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func typeMatches(value any, want string) bool {
+	got := typeOf(value)
+	return got == want || (want == "number" && got == "integer")
+}
+
+func stringList(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func number(value any) (float64, bool) {
+	f, ok := value.(float64)
+	return f, ok
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// maxDepth guards against reference cycles.
+const maxDepth = 64
+
+func (s *Schema) validate(schema map[string]any, value any, ptr string, findings *[]Finding, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*findings = append(*findings, Finding{Pointer: ptr, Rule: "schema", Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+	}
+	unsupported := func(key, message string) {
+		if s.reported[key] {
+			return
+		}
+		s.reported[key] = true
+		*findings = append(*findings, Finding{Pointer: ptr, Rule: "schema-unsupported", Severity: SeverityWarning, Message: message})
+	}
+	pattern := func(expr string) *regexp.Regexp {
+		re, err := s.pattern(expr)
+		if err != nil {
+			unsupported("pattern "+expr, fmt.Sprintf("pattern %s is not supported and was not checked: %v", expr, err))
+		}
+		return re
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		if target, ok := s.resolve(ref); ok {
+			s.validate(target, value, ptr, findings, depth+1)
+		} else {
+			unsupported("$ref "+ref, fmt.Sprintf("reference %s cannot be resolved; the value was not checked against it", ref))
+		}
+		return
+	}
+
+	if types := stringList(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if typeMatches(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("expected %s, got %s", strings.Join(types, " or "), typeOf(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(value, allowed) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("value %v is not one of the allowed values", value)
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(value, constant) {
+		fail("value %v is not %v", value, constant)
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		subschemas, ok := schema[key].([]any)
+		if !ok {
+			continue
+		}
+		valid := 0
+		for _, sub := range subschemas {
+			subMap, ok := sub.(map[string]any)
+			if !ok {
+				continue
+			}
+			var subFindings []Finding
+			s.validate(subMap, value, ptr, &subFindings, depth+1)
+			if key == "allOf" {
+				*findings = append(*findings, subFindings...)
+			} else {
+				*findings = append(*findings, warningsOf(subFindings)...)
+			}
+			if !hasErrors(subFindings) {
+				valid++
+			}
+		}
+		if key == "anyOf" && valid == 0 {
+			fail("value does not match any of the allowed schemas")
+		}
+		if key == "oneOf" && valid != 1 {
+			fail("value matches %d of the schemas, expected exactly one", valid)
+		}
+	}
+	if not, ok := schema["not"].(map[string]any); ok {
+		var subFindings []Finding
+		s.validate(not, value, ptr, &subFindings, depth+1)
+		*findings = append(*findings, warningsOf(subFindings)...)
+		if !hasErrors(subFindings) {
+			fail("value matches a disallowed schema")
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if n, ok := number(schema["minLength"]); ok && float64(length) < n {
+			fail("string is shorter than %v characters", n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && float64(length) > n {
+			fail("string is longer than %v characters", n)
+		}
+		if expr, ok := schema["pattern"].(string); ok {
+			if re := pattern(expr); re != nil && !re.MatchString(v) {
+				fail("%q does not match pattern %s", v, expr)
+			}
+		}
+	case float64:
+		if n, ok := number(schema["minimum"]); ok {
+			if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive && v <= n {
+				fail("%v is not greater than %v", v, n)
+			} else if v < n {
+				fail("%v is less than the minimum %v", v, n)
+			}
+		}
+		if n, ok := number(schema["maximum"]); ok {
+			if exclusive, _ := schema["exclusiveMaximum"].(bool); exclusive && v >= n {
+				fail("%v is not less than %v", v, n)
+			} else if v > n {
+				fail("%v is greater than the maximum %v", v, n)
+			}
+		}
+		if n, ok := number(schema["exclusiveMinimum"]); ok && v <= n {
+			fail("%v is not greater than %v", v, n)
+		}
+		if n, ok := number(schema["exclusiveMaximum"]); ok && v >= n {
+			fail("%v is not less than %v", v, n)
+		}
+	case []any:
+		if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+			fail("array has fewer than %v items", n)
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+			fail("array has more than %v items", n)
+		}
+		if unique, _ := schema["uniqueItems"].(bool); unique {
+			for i := range v {
+				for j := i + 1; j < len(v); j++ {
+					if reflect.DeepEqual(v[i], v[j]) {
+						fail("items %d and %d are equal", i, j)
+					}
+				}
+			}
+		}
+		switch items := schema["items"].(type) {
+		case map[string]any:
+			for i, item := range v {
+				s.validate(items, item, Pointer(ptr, i), findings, depth+1)
+			}
+		case []any:
+			for i, item := range v {
+				if i < len(items) {
+					if itemSchema, ok := items[i].(map[string]any); ok {
+						s.validate(itemSchema, item, Pointer(ptr, i), findings, depth+1)
+					}
+				}
+			}
+		}
+	case map[string]any:
+		for _, key := range stringList(schema["required"]) {
+			if _, ok := v[key]; !ok {
+				fail("missing required property %q", key)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		patternProperties, _ := schema["patternProperties"].(map[string]any)
+		for _, key := range sortedKeys(v) {
+			matched := false
+			if propSchema, ok := properties[key].(map[string]any); ok {
+				matched = true
+				s.validate(propSchema, v[key], Pointer(ptr, key), findings, depth+1)
+			}
+			for _, expr := range sortedKeys(patternProperties) {
+				if re := pattern(expr); re != nil && re.MatchString(key) {
+					matched = true
+					if propSchema, ok := patternProperties[expr].(map[string]any); ok {
+						s.validate(propSchema, v[key], Pointer(ptr, key), findings, depth+1)
+					}
+				}
+			}
+			if matched {
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					*findings = append(*findings, Finding{Pointer: Pointer(ptr, key), Rule: "schema", Severity: SeverityError, Message: "property is not allowed"})
+				}
+			case map[string]any:
+				s.validate(additional, v[key], Pointer(ptr, key), findings, depth+1)
+			}
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package manifester
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+)
+
+// Version is the version of the JSON manifest format.
+const Version = 1
+
+// Entry is one file of a manifest.
+type Entry struct {
+	// Path is the slash-separated path of the file relative to the
+	// manifest's base directory.
+	Path string `json:"path"`
+	// Size is the file size in bytes, or nil if the manifest does not
+	// record it.
+	Size *int64 `json:"size,omitempty"`
+	// Checksums are <algorithm>:<hex digest> values. The first one is
+	// used for verification.
+	Checksums []string `json:"checksums"`
+}
+
+// Manifest lists files with their sizes and checksums. Manifests created
+// from record metadata and from local files have the same format.
+type Manifest struct {
+	Version int `json:"version"`
+	// Recid is the record the files belong to, if the manifest was
+	// created from record metadata.
+	Recid int     `json:"recid,omitempty"`
+	Files []Entry `json:"files"`
+}
+
+// FromFiles creates a manifest from file entries of record metadata with
+// uri, size and checksum keys. The files are listed by their base name,
+// as download-files stores them.
+func FromFiles(recid int, files []any) *Manifest {
+	m := &Manifest{Version: Version, Recid: recid, Files: []Entry{}}
+	for _, file := range files {
+		fileMap, ok := file.(map[string]any)
+		if !ok {
+			continue
+		}
+		uri, _ := fileMap["uri"].(string)
+		entry := Entry{Path: filepath.Base(uri), Checksums: []string{}}
+		if size, ok := fileMap["size"].(float64); ok {
+			n := int64(size)
+			entry.Size = &n
+		}
+		if value, _ := fileMap["checksum"].(string); value != "" {
+			if algorithm, digest, err := checksum.Parse(value); err == nil {
+				value = algorithm + ":" + digest
+			}
+			entry.Checksums = append(entry.Checksums, value)
+		}
+		m.Files = append(m.Files, entry)
+	}
+	return m
+}
+
+// FromDirectory creates a manifest of all files below directory, computing
+// their checksums with the algorithms in a single read pass per file.
+// Checksums found in cache, if it is not nil, are not computed again.
+// Checksum cache sidecar files and the files for which skip returns true
+// are left out.
+func FromDirectory(directory string, algorithms []string, cache *checksum.Cache, skip func(path string) bool) (*Manifest, error) {
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("no checksum algorithm given")
+	}
+	lower := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		if _, err := checksum.New(algorithm); err != nil {
+			return nil, err
+		}
+		lower[i] = strings.ToLower(algorithm)
+	}
+	algorithms = lower
+
+	m := &Manifest{Version: Version, Files: []Entry{}}
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), checksum.CacheFileName) {
+			return nil
+		}
+		if skip != nil && skip(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sums, err := fileChecksums(path, algorithms, cache)
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum of %s: %w", path, err)
+		}
+
+		size := info.Size()
+		entry := Entry{Path: filepath.ToSlash(rel), Size: &size}
+		for _, algorithm := range algorithms {
+			if !slices.Contains(entry.Checksums, sums[algorithm]) {
+				entry.Checksums = append(entry.Checksums, sums[algorithm])
+			}
+		}
+		m.Files = append(m.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// fileChecksums returns the checksums of a file keyed by algorithm, taking
+// what it can from cache and computing the rest.
+func fileChecksums(path string, algorithms []string, cache *checksum.Cache) (map[string]string, error) {
+	sums := make(map[string]string, len(algorithms))
+	var missing []string
+	for _, algorithm := range algorithms {
+		if cache != nil {
+			if sum, ok := cache.Lookup(path, algorithm); ok {
+				sums[algorithm] = sum
+				continue
+			}
+		}
+		missing = append(missing, algorithm)
+	}
+	if len(missing) == 0 {
+		return sums, nil
+	}
+
+	// Take the attributes before hashing, so that the cache does not keep
+	// checksums of a file that changed meanwhile.
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	computed, err := checksum.CalculateFile(path, missing...)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for algorithm, sum := range computed {
+		sums[algorithm] = sum
+		values = append(values, sum)
+	}
+	if cache != nil {
+		_ = cache.Store(path, info, values...)
+	}
+	return sums, nil
+}
+
+// FileList returns the manifest entries as file entries with path, uri, size
+// and checksum keys, as verifier.VerifyFiles expects them.
+func (m *Manifest) FileList() []any {
+	files := make([]any, 0, len(m.Files))
+	for _, entry := range m.Files {
+		file := map[string]any{
+			"path":     entry.Path,
+			"uri":      entry.Path,
+			"checksum": "",
+		}
+		if entry.Size != nil {
+			file["size"] = float64(*entry.Size)
+		}
+		if len(entry.Checksums) > 0 {
+			file["checksum"] = entry.Checksums[0]
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// Write writes the manifest in the format "json" or "sha256sum". The
+// sha256sum format can be checked with sha256sum -c and requires a sha256
+// checksum for every file.
+func (m *Manifest) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		jsonBytes, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", jsonBytes)
+		return err
+	case "sha256sum":
+		var b bytes.Buffer
+		for _, entry := range m.Files {
+			if strings.ContainsAny(entry.Path, "\n\\") {
+				return fmt.Errorf("cannot write path %q in sha256sum format", entry.Path)
+			}
+			digest := ""
+			for _, value := range entry.Checksums {
+				if algorithm, hex, err := checksum.Parse(value); err == nil && algorithm == "sha256" {
+					digest = hex
+					break
+				}
+			}
+			if digest == "" {
+				return fmt.Errorf("%s has no sha256 checksum", entry.Path)
+			}
+			fmt.Fprintf(&b, "%s  %s\n", digest, entry.Path)
+		}
+		_, err := w.Write(b.Bytes())
+		return err
+	default:
+		return fmt.Errorf("unknown manifest format %q (choose from 'json', 'sha256sum')", format)
+	}
+}
+
+// taggedLine matches a BSD-style checksum line such as
+// "SHA256 (path) = <hex digest>", as written by sha256sum --tag.
+var taggedLine = regexp.MustCompile(`^([A-Za-z0-9-]+) \((.+)\) = ([0-9A-Fa-f]+)$`)
+
+// Read reads a manifest. Besides the JSON format it accepts checksum lines
+// in the formats of sha256sum, md5sum and their --tag variants, and the
+// path, size and checksum lines printed by verifier.VerifyLocalFiles.
+func Read(r io.Reader) (*Manifest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var m Manifest
+		if err := json.Unmarshal(trimmed, &m); err != nil {
+			return nil, fmt.Errorf("invalid JSON manifest: %w", err)
+		}
+		if m.Version != Version {
+			return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+		}
+		return &m, nil
+	}
+
+	m := &Manifest{Version: Version, Files: []Entry{}}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		m.Files = append(m.Files, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadFile reads a manifest from a file.
+func ReadFile(path string) (*Manifest, error) {
+	file, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return Read(file)
+}
+
+// parseLine parses one line of a text manifest.
+func parseLine(line string) (Entry, error) {
+	// path<TAB>size<TAB>checksum, as printed by VerifyLocalFiles.
+	if parts := strings.Split(line, "\t"); len(parts) == 3 {
+		size, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			return Entry{}, fmt.Errorf("invalid size %q", parts[1])
+		}
+		value, err := normalize(strings.TrimSpace(parts[2]))
+		if err != nil {
+			return Entry{}, err
+		}
+		return Entry{Path: parts[0], Size: &size, Checksums: []string{value}}, nil
+	}
+
+	// ALGORITHM (path) = digest, as written with --tag.
+	if match := taggedLine.FindStringSubmatch(line); match != nil {
+		value, err := normalize(match[1] + ":" + match[3])
+		if err != nil {
+			return Entry{}, err
+		}
+		return Entry{Path: match[2], Checksums: []string{value}}, nil
+	}
+
+	// digest, two spaces (or space and asterisk) and path, with the
+	// algorithm given by the digest length.
+	digest, path, ok := strings.Cut(line, " ")
+	if !ok || (!strings.HasPrefix(path, " ") && !strings.HasPrefix(path, "*")) {
+		return Entry{}, fmt.Errorf("unrecognized manifest line %q", line)
+	}
+	path = path[1:]
+	var algorithm string
+	switch len(digest) {
+	case 64:
+		algorithm = "sha256"
+	case 32:
+		algorithm = "md5"
+	case 8:
+		algorithm = checksum.DefaultAlgorithm
+	default:
+		return Entry{}, fmt.Errorf("cannot tell the algorithm of checksum %q", digest)
+	}
+	value, err := normalize(algorithm + ":" + digest)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Path: path, Checksums: []string{value}}, nil
+}
+
+// normalize validates a checksum value and returns it in lower case.
+func normalize(value string) (string, error) {
+	algorithm, digest, err := checksum.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	return algorithm + ":" + digest, nil
+}
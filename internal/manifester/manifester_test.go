@@ -0,0 +1,177 @@
+package manifester
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+)
+
+const (
+	testSHA256  = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testAdler32 = "adler32:045d01c1"
+)
+
+func writeTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"a.root", filepath.Join("sub", "b.root")} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("test"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksum.CacheFileName), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFromDirectory(t *testing.T) {
+	dir := writeTree(t)
+
+	m, err := FromDirectory(dir, []string{"SHA256", "adler32", "sha256"}, nil, nil)
+	if err != nil {
+		t.Fatalf("FromDirectory() error = %v", err)
+	}
+
+	size := int64(4)
+	want := []Entry{
+		{Path: "a.root", Size: &size, Checksums: []string{testSHA256, testAdler32}},
+		{Path: "sub/b.root", Size: &size, Checksums: []string{testSHA256, testAdler32}},
+	}
+	if !reflect.DeepEqual(m.Files, want) {
+		t.Errorf("FromDirectory() files = %+v, want %+v", m.Files, want)
+	}
+
+	skipped, err := FromDirectory(dir, []string{"sha256"}, nil, func(path string) bool {
+		return filepath.Base(path) == "a.root"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped.Files) != 1 || skipped.Files[0].Path != "sub/b.root" {
+		t.Errorf("FromDirectory() with skip = %+v", skipped.Files)
+	}
+
+	if _, err := FromDirectory(dir, []string{"blake3"}, nil, nil); err == nil {
+		t.Error("FromDirectory() accepted an unknown algorithm")
+	}
+}
+
+func TestFromFiles(t *testing.T) {
+	m := FromFiles(5500, []any{
+		map[string]any{"uri": "root://eospublic.cern.ch//eos/opendata/a.root", "size": float64(4), "checksum": "ADLER32:045D01C1"},
+		map[string]any{"uri": "https://opendata.cern.ch/eos/opendata/b.root"},
+	})
+
+	if m.Recid != 5500 || len(m.Files) != 2 {
+		t.Fatalf("FromFiles() = %+v", m)
+	}
+	if got := m.Files[0]; got.Path != "a.root" || *got.Size != 4 || !reflect.DeepEqual(got.Checksums, []string{testAdler32}) {
+		t.Errorf("FromFiles() first entry = %+v", got)
+	}
+	if got := m.Files[1]; got.Path != "b.root" || got.Size != nil || len(got.Checksums) != 0 {
+		t.Errorf("FromFiles() second entry = %+v", got)
+	}
+
+	files := m.FileList()
+	first := files[0].(map[string]any)
+	if first["path"] != "a.root" || first["size"] != float64(4) || first["checksum"] != testAdler32 {
+		t.Errorf("FileList() first entry = %v", first)
+	}
+	if _, ok := files[1].(map[string]any)["size"]; ok {
+		t.Error("FileList() gave a size to an entry without one")
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	m, err := FromDirectory(writeTree(t), []string{"sha256", "adler32"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsonOut bytes.Buffer
+	if err := m.Write(&jsonOut, "json"); err != nil {
+		t.Fatalf("Write(json) error = %v", err)
+	}
+	read, err := Read(&jsonOut)
+	if err != nil {
+		t.Fatalf("Read(json) error = %v", err)
+	}
+	if !reflect.DeepEqual(read, m) {
+		t.Errorf("Read(json) = %+v, want %+v", read, m)
+	}
+
+	var textOut bytes.Buffer
+	if err := m.Write(&textOut, "sha256sum"); err != nil {
+		t.Fatalf("Write(sha256sum) error = %v", err)
+	}
+	wantText := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  a.root\n" +
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  sub/b.root\n"
+	if textOut.String() != wantText {
+		t.Errorf("Write(sha256sum) = %q, want %q", textOut.String(), wantText)
+	}
+
+	portal := FromFiles(1, []any{map[string]any{"uri": "a.root", "checksum": testAdler32}})
+	if err := portal.Write(&textOut, "sha256sum"); err == nil || !strings.Contains(err.Error(), "no sha256 checksum") {
+		t.Errorf("Write(sha256sum) without sha256 error = %v", err)
+	}
+	if err := m.Write(&textOut, "xml"); err == nil {
+		t.Error("Write() accepted an unknown format")
+	}
+}
+
+func TestReadText(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08  a file.root",
+		"098f6bcd4621d373cade4e832627b4f6 *b.root",
+		"045d01c1  c.root",
+		"SHA256 (sub/d.root) = 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		"data/e.root\t4\tadler32:045d01c1",
+		"",
+	}, "\n")
+
+	m, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	var got []string
+	for _, entry := range m.Files {
+		size := "-"
+		if entry.Size != nil {
+			size = "4"
+		}
+		got = append(got, entry.Path+" "+size+" "+strings.Join(entry.Checksums, ","))
+	}
+	want := []string{
+		"a file.root - " + testSHA256,
+		"b.root - md5:098f6bcd4621d373cade4e832627b4f6",
+		"c.root - " + testAdler32,
+		"sub/d.root - " + testSHA256,
+		"data/e.root 4 " + testAdler32,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{
+		"not a manifest line",
+		"0123  odd.root",
+		"a.root\tbig\tadler32:045d01c1",
+		`{"version": 2, "files": []}`,
+	} {
+		if _, err := Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("Read(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package masker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Range is an inclusive range of lumisections.
+type Range [2]int
+
+// LumiMask maps run numbers to the lumisection ranges selected in them, as
+// in the CMS validated runs ("golden") JSON files. The ranges of a run are
+// sorted and do not overlap or touch.
+type LumiMask map[int][]Range
+
+// Summary counts the runs and lumisections of a mask.
+type Summary struct {
+	Runs         int `json:"runs"`
+	Lumisections int `json:"lumisections"`
+	FirstRun     int `json:"first_run,omitempty"`
+	LastRun      int `json:"last_run,omitempty"`
+}
+
+// Parse decodes a lumi mask in the CMS JSON format, an object mapping run
+// numbers to lists of [first, last] lumisection ranges.
+func Parse(data []byte) (LumiMask, error) {
+	var raw map[string][][]int
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode lumi mask: %w", err)
+	}
+
+	mask := make(LumiMask, len(raw))
+	for key, ranges := range raw {
+		run, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil || run <= 0 {
+			return nil, fmt.Errorf("invalid run number %q", key)
+		}
+		for _, r := range ranges {
+			if len(r) != 2 || r[0] <= 0 || r[1] < r[0] {
+				return nil, fmt.Errorf("invalid lumisection range %v in run %d", r, run)
+			}
+			mask[run] = append(mask[run], Range{r[0], r[1]})
+		}
+	}
+	return mask.normalize(), nil
+}
+
+// Load reads a lumi mask from a file.
+func Load(path string) (LumiMask, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lumi mask: %w", err)
+	}
+	mask, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return mask, nil
+}
+
+// merge sorts ranges and joins the ones that overlap or touch.
+func merge(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sorted := append([]Range{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+
+	merged := []Range{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1]+1 {
+			last[1] = max(last[1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func (m LumiMask) normalize() LumiMask {
+	result := make(LumiMask, len(m))
+	for run, ranges := range m {
+		if merged := merge(ranges); len(merged) > 0 {
+			result[run] = merged
+		}
+	}
+	return result
+}
+
+// Runs returns the run numbers in ascending order.
+func (m LumiMask) Runs() []int {
+	runs := make([]int, 0, len(m))
+	for run := range m {
+		runs = append(runs, run)
+	}
+	sort.Ints(runs)
+	return runs
+}
+
+// Lumisections returns the number of lumisections selected in a run.
+func (m LumiMask) Lumisections(run int) int {
+	n := 0
+	for _, r := range m[run] {
+		n += r[1] - r[0] + 1
+	}
+	return n
+}
+
+// Contains reports whether a lumisection of a run is selected.
+func (m LumiMask) Contains(run, lumi int) bool {
+	for _, r := range m[run] {
+		if lumi >= r[0] && lumi <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary counts the runs and lumisections of the mask.
+func (m LumiMask) Summary() Summary {
+	runs := m.Runs()
+	summary := Summary{Runs: len(runs)}
+	for _, run := range runs {
+		summary.Lumisections += m.Lumisections(run)
+	}
+	if len(runs) > 0 {
+		summary.FirstRun = runs[0]
+		summary.LastRun = runs[len(runs)-1]
+	}
+	return summary
+}
+
+// Union returns the lumisections selected in m or other.
+func (m LumiMask) Union(other LumiMask) LumiMask {
+	result := make(LumiMask, len(m)+len(other))
+	for run, ranges := range m {
+		result[run] = append(result[run], ranges...)
+	}
+	for run, ranges := range other {
+		result[run] = append(result[run], ranges...)
+	}
+	return result.normalize()
+}
+
+// Intersect returns the lumisections selected in both m and other.
+func (m LumiMask) Intersect(other LumiMask) LumiMask {
+	result := make(LumiMask)
+	for run, ranges := range m {
+		for _, a := range ranges {
+			for _, b := range other[run] {
+				if first, last := max(a[0], b[0]), min(a[1], b[1]); first <= last {
+					result[run] = append(result[run], Range{first, last})
+				}
+			}
+		}
+	}
+	return result.normalize()
+}
+
+// Subtract returns the lumisections selected in m but not in other.
+func (m LumiMask) Subtract(other LumiMask) LumiMask {
+	result := make(LumiMask)
+	for run, ranges := range m {
+		for _, r := range ranges {
+			remaining := []Range{r}
+			for _, cut := range other[run] {
+				var next []Range
+				for _, piece := range remaining {
+					if cut[1] < piece[0] || cut[0] > piece[1] {
+						next = append(next, piece)
+						continue
+					}
+					if cut[0] > piece[0] {
+						next = append(next, Range{piece[0], cut[0] - 1})
+					}
+					if cut[1] < piece[1] {
+						next = append(next, Range{cut[1] + 1, piece[1]})
+					}
+				}
+				remaining = next
+			}
+			result[run] = append(result[run], remaining...)
+		}
+	}
+	return result.normalize()
+}
+
+// FilterRuns keeps the runs from first to last, inclusive. A zero bound is
+// open.
+func (m LumiMask) FilterRuns(first, last int) LumiMask {
+	result := make(LumiMask)
+	for run, ranges := range m {
+		if (first == 0 || run >= first) && (last == 0 || run <= last) {
+			result[run] = append([]Range{}, ranges...)
+		}
+	}
+	return result
+}
+
+// JSON renders the mask in the CMS JSON format with one run per line and
+// the runs in ascending order, as in the official validated runs files.
+func (m LumiMask) JSON() string {
+	runs := m.Runs()
+	if len(runs) == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	for i, run := range runs {
+		if i == 0 {
+			b.WriteString("{")
+		} else {
+			b.WriteString(",\n ")
+		}
+		parts := make([]string, len(m[run]))
+		for j, r := range m[run] {
+			parts[j] = fmt.Sprintf("[%d, %d]", r[0], r[1])
+		}
+		b.WriteString(fmt.Sprintf("\"%d\": [%s]", run, strings.Join(parts, ", ")))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Text returns a summary of the mask. With perRun, each run is listed with
+// its number of lumisections and its ranges.
+func (m LumiMask) Text(perRun bool) string {
+	summary := m.Summary()
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Runs:          %d\n", summary.Runs))
+	b.WriteString(fmt.Sprintf("Lumisections:  %d", summary.Lumisections))
+	if summary.Runs > 0 {
+		b.WriteString(fmt.Sprintf("\nRun range:     %d-%d", summary.FirstRun, summary.LastRun))
+	}
+	if perRun && summary.Runs > 0 {
+		b.WriteString("\n")
+		for _, run := range m.Runs() {
+			parts := make([]string, len(m[run]))
+			for j, r := range m[run] {
+				parts[j] = fmt.Sprintf("%d-%d", r[0], r[1])
+			}
+			b.WriteString(fmt.Sprintf("\n%-8d %6d  %s", run, m.Lumisections(run), strings.Join(parts, ",")))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package masker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, data string) LumiMask {
+	t.Helper()
+	mask, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse(%s) failed: %v", data, err)
+	}
+	return mask
+}
+
+func TestParse(t *testing.T) {
+	mask := mustParse(t, `{"190645": [[10, 110]], "190646": [[50, 60], [1, 20], [21, 30]]}`)
+	want := LumiMask{
+		190645: {{10, 110}},
+		190646: {{1, 30}, {50, 60}},
+	}
+	if !reflect.DeepEqual(mask, want) {
+		t.Errorf("Parse() = %v, want %v", mask, want)
+	}
+
+	for _, data := range []string{
+		`[1, 2]`,
+		`{"abc": [[1, 2]]}`,
+		`{"1": [[5, 2]]}`,
+		`{"1": [[1, 2, 3]]}`,
+		`{"1": [[0, 2]]}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s) expected error", data)
+		}
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := mustParse(t, `{"1": [[1, 10], [20, 30]], "2": [[1, 5]]}`)
+	b := mustParse(t, `{"1": [[5, 25]], "3": [[1, 1]]}`)
+
+	tests := []struct {
+		name string
+		got  LumiMask
+		want string
+	}{
+		{name: "union", got: a.Union(b), want: `{"1": [[1, 30]],
+ "2": [[1, 5]],
+ "3": [[1, 1]]}`},
+		{name: "intersect", got: a.Intersect(b), want: `{"1": [[5, 10], [20, 25]]}`},
+		{name: "subtract", got: a.Subtract(b), want: `{"1": [[1, 4], [26, 30]],
+ "2": [[1, 5]]}`},
+		{name: "subtract all", got: b.Subtract(b), want: `{}`},
+		{name: "filter runs", got: a.Union(b).FilterRuns(2, 0), want: `{"2": [[1, 5]],
+ "3": [[1, 1]]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.JSON(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	mask := mustParse(t, `{"190645": [[10, 110]], "190704": [[1, 3]]}`)
+	again := mustParse(t, mask.JSON())
+	if !reflect.DeepEqual(mask, again) {
+		t.Errorf("round trip changed mask: %v != %v", mask, again)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	mask := mustParse(t, `{"190645": [[10, 110]], "190704": [[1, 3], [5, 5]]}`)
+	want := Summary{Runs: 2, Lumisections: 105, FirstRun: 190645, LastRun: 190704}
+	if got := mask.Summary(); got != want {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+	if !mask.Contains(190704, 5) || mask.Contains(190704, 4) {
+		t.Error("Contains() gave wrong result")
+	}
+
+	text := mask.Text(true)
+	for _, want := range []string{"Runs:          2", "Lumisections:  105", "Run range:     190645-190704", "190704        4  1-3,5-5"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(mask.Text(false), "1-3,5-5") {
+		t.Error("text without perRun should not list runs")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.json")
+	if err := os.WriteFile(path, []byte(`{"1": [[1, 2]]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if mask, err := Load(path); err != nil || mask.Lumisections(1) != 2 {
+		t.Errorf("Load() = %v, %v", mask, err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
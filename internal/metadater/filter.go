@@ -0,0 +1,279 @@
+package metadater
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter operators supported by ParseFilter.
+const (
+	OpEqual        = "="
+	OpNotEqual     = "!="
+	OpMatch        = "~="
+	OpGreater      = ">"
+	OpLess         = "<"
+	OpGreaterEqual = ">="
+	OpLessEqual    = "<="
+	OpIn           = "in"
+	OpExists       = "exists"
+)
+
+// Condition is a single field comparison such as size>1e9 or type in A,B.
+type Condition struct {
+	Field  string
+	Op     string
+	Value  string
+	Values []string
+	re     *regexp.Regexp
+}
+
+// Filter is a boolean combination of conditions. Terms are ORed together and
+// the conditions within each term are ANDed.
+type Filter struct {
+	Terms [][]Condition
+}
+
+// operators is ordered so that two-character operators are tried first.
+var operators = []string{OpNotEqual, OpMatch, OpGreaterEqual, OpLessEqual, "==", OpEqual, OpGreater, OpLess}
+
+// ParseFilter parses a filter expression. Conditions have the form
+// field<op>value with op one of =, !=, ~= (regex), >, <, >=, <=, or
+// "field in a,b,c" and "field exists". Conditions are combined with && (or
+// AND) and || (or OR); && binds tighter. Field may be a dot path.
+func ParseFilter(expr string) (*Filter, error) {
+	filter := &Filter{}
+	for _, orPart := range splitKeyword(expr, "||", " OR ") {
+		var term []Condition
+		for _, andPart := range splitKeyword(orPart, "&&", " AND ") {
+			cond, err := parseCondition(andPart)
+			if err != nil {
+				return nil, err
+			}
+			term = append(term, cond)
+		}
+		filter.Terms = append(filter.Terms, term)
+	}
+	return filter, nil
+}
+
+// splitKeyword splits s on any of the given separators, except within
+// single- or double-quoted values.
+func splitKeyword(s string, seps ...string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' {
+			quote = c
+			continue
+		}
+		for _, sep := range seps {
+			if strings.HasPrefix(s[i:], sep) {
+				parts = append(parts, s[start:i])
+				start = i + len(sep)
+				i = start - 1
+				break
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parseCondition(expr string) (Condition, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return Condition{}, fmt.Errorf("empty filter condition")
+	}
+
+	// The field ends at the first operator character, unless a keyword
+	// operator comes first.
+	end := strings.IndexAny(expr, "=!~<>")
+	if field, ok := strings.CutSuffix(expr, " "+OpExists); ok && end < 0 && strings.TrimSpace(field) != "" {
+		return Condition{Field: strings.TrimSpace(field), Op: OpExists}, nil
+	}
+	if field, values, ok := strings.Cut(expr, " "+OpIn+" "); ok && (end < 0 || len(field) < end) && strings.TrimSpace(field) != "" {
+		values = strings.Trim(strings.TrimSpace(values), "[]()")
+		cond := Condition{Field: strings.TrimSpace(field), Op: OpIn}
+		for _, v := range strings.Split(values, ",") {
+			cond.Values = append(cond.Values, unquote(v))
+		}
+		return cond, nil
+	}
+
+	if end <= 0 {
+		return Condition{}, fmt.Errorf("invalid filter format: %s", expr)
+	}
+	rest := expr[end:]
+	for _, op := range operators {
+		if !strings.HasPrefix(rest, op) {
+			continue
+		}
+		// A value starting with an operator character, as in size=>5, is
+		// most likely a mistyped operator; such values must be quoted.
+		value := strings.TrimSpace(rest[len(op):])
+		if value != "" && strings.ContainsRune("=!~<>", rune(value[0])) {
+			return Condition{}, fmt.Errorf("invalid filter format: %s (quote values starting with an operator character)", expr)
+		}
+		cond := Condition{
+			Field: strings.TrimSpace(expr[:end]),
+			Op:    op,
+			Value: unquote(value),
+		}
+		if op == "==" {
+			cond.Op = OpEqual
+		}
+		if cond.Op == OpMatch {
+			re, err := regexp.Compile(cond.Value)
+			if err != nil {
+				return Condition{}, fmt.Errorf("invalid regular expression in filter %s: %w", expr, err)
+			}
+			cond.re = re
+		}
+		return cond, nil
+	}
+	return Condition{}, fmt.Errorf("invalid filter format: %s", expr)
+}
+
+// parseFilterValue converts a filter value to a number where possible,
+// accepting size suffixes such as 10MB or 1.5GiB.
+func parseFilterValue(s string) any {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n
+	}
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	}
+	for _, u := range units {
+		if number, ok := strings.CutSuffix(s, u.suffix); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(number), 64); err == nil {
+				return n * u.multiplier
+			}
+		}
+	}
+	return s
+}
+
+// Match reports whether item satisfies the condition. A missing field
+// matches unless strict is set, except for the exists operator.
+func (c Condition) Match(item any, strict bool) bool {
+	value, err := ExtractNestedField(item, c.Field)
+	missing := err != nil || value == nil
+
+	if c.Op == OpExists {
+		return !missing
+	}
+	if missing {
+		return !strict
+	}
+
+	// Array values match if any element matches, or for != if none is equal.
+	if values, ok := value.([]any); ok {
+		if c.Op == OpNotEqual {
+			for _, v := range values {
+				if CompareValues(v, "==", parseFilterValue(c.Value)) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, v := range values {
+			if c.matchValue(v) {
+				return true
+			}
+		}
+		return false
+	}
+	return c.matchValue(value)
+}
+
+func (c Condition) matchValue(value any) bool {
+	switch c.Op {
+	case OpMatch:
+		return c.re.MatchString(fmt.Sprintf("%v", value))
+	case OpIn:
+		for _, v := range c.Values {
+			if CompareValues(value, "==", parseFilterValue(v)) {
+				return true
+			}
+		}
+		return false
+	case OpEqual:
+		return CompareValues(value, "==", parseFilterValue(c.Value))
+	}
+	return CompareValues(value, c.Op, parseFilterValue(c.Value))
+}
+
+// Match reports whether item satisfies the filter.
+func (f *Filter) Match(item any, strict bool) bool {
+	for _, term := range f.Terms {
+		match := true
+		for _, cond := range term {
+			if !cond.Match(item, strict) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterItems returns the items matching all filters. In strict mode items
+// lacking a filtered field are excluded.
+func FilterItems(items []any, filters []string, strict bool) ([]any, error) {
+	if len(items) == 0 || len(filters) == 0 {
+		return items, nil
+	}
+
+	parsed := make([]*Filter, 0, len(filters))
+	for _, f := range filters {
+		filter, err := ParseFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, filter)
+	}
+
+	var result []any
+	for _, item := range items {
+		if _, ok := item.(map[string]any); !ok {
+			return nil, fmt.Errorf("item is not a map")
+		}
+
+		match := true
+		for _, filter := range parsed {
+			if !filter.Match(item, strict) {
+				match = false
+				break
+			}
+		}
+		if match {
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
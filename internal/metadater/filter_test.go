@@ -0,0 +1,142 @@
+package metadater
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    [][]Condition
+		wantErr bool
+	}{
+		{
+			name: "equality",
+			expr: "name=Bob",
+			want: [][]Condition{{{Field: "name", Op: OpEqual, Value: "Bob"}}},
+		},
+		{
+			name: "double equals and quotes",
+			expr: `name=="Rousseau, David"`,
+			want: [][]Condition{{{Field: "name", Op: OpEqual, Value: "Rousseau, David"}}},
+		},
+		{
+			name: "comparison with spaces",
+			expr: "size >= 1GB",
+			want: [][]Condition{{{Field: "size", Op: OpGreaterEqual, Value: "1GB"}}},
+		},
+		{
+			name: "in list",
+			expr: "type in Dataset,Software",
+			want: [][]Condition{{{Field: "type", Op: OpIn, Values: []string{"Dataset", "Software"}}}},
+		},
+		{
+			name: "exists",
+			expr: "orcid exists",
+			want: [][]Condition{{{Field: "orcid", Op: OpExists}}},
+		},
+		{
+			name: "value containing keyword",
+			expr: "title=Made in CERN",
+			want: [][]Condition{{{Field: "title", Op: OpEqual, Value: "Made in CERN"}}},
+		},
+		{
+			name: "and or",
+			expr: "age>20 && name!=Bob || name=Eve",
+			want: [][]Condition{
+				{{Field: "age", Op: OpGreater, Value: "20"}, {Field: "name", Op: OpNotEqual, Value: "Bob"}},
+				{{Field: "name", Op: OpEqual, Value: "Eve"}},
+			},
+		},
+		{
+			name: "keywords in quotes",
+			expr: `title="Higgs AND ZZ" || title='a || b' && note="x&&y"`,
+			want: [][]Condition{
+				{{Field: "title", Op: OpEqual, Value: "Higgs AND ZZ"}},
+				{{Field: "title", Op: OpEqual, Value: "a || b"}, {Field: "note", Op: OpEqual, Value: "x&&y"}},
+			},
+		},
+		{
+			name: "in list with quoted keyword",
+			expr: `type in "A OR B",C`,
+			want: [][]Condition{{{Field: "type", Op: OpIn, Values: []string{"A OR B", "C"}}}},
+		},
+		{name: "no operator", expr: "invalid", wantErr: true},
+		{name: "missing field", expr: "=value", wantErr: true},
+		{name: "empty condition", expr: "a=1 &&", wantErr: true},
+		{name: "bad regex", expr: "name~=(", wantErr: true},
+		{name: "mistyped operator", expr: "size=>5", wantErr: true},
+		{name: "value starting with an operator", expr: "note!==x", wantErr: true},
+		{name: "quoted value starting with an operator", expr: `note=">5"`, want: [][]Condition{{{Field: "note", Op: OpEqual, Value: ">5"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.expr)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(filter.Terms, tt.want) {
+				t.Errorf("ParseFilter(%q) = %+v, want %+v", tt.expr, filter.Terms, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterItems(t *testing.T) {
+	items := []any{
+		map[string]any{"name": "Alice", "age": float64(30), "size": float64(2e9), "date": "2012-05-01", "tags": []any{"a", "b"}},
+		map[string]any{"name": "Bob", "age": float64(25), "size": float64(5e5), "date": "2016-01-10"},
+		map[string]any{"name": "Charlie", "age": "40", "date": "2011-12-31", "tags": []any{"c"}},
+	}
+
+	tests := []struct {
+		name     string
+		filters  []string
+		strict   bool
+		expected []any
+	}{
+		{name: "numeric greater", filters: []string{"age>28"}, expected: []any{items[0], items[2]}},
+		{name: "size suffix", filters: []string{"size>1GB"}, strict: true, expected: []any{items[0]}},
+		{name: "missing field matches", filters: []string{"size<1MB"}, expected: []any{items[1], items[2]}},
+		{name: "strict missing field", filters: []string{"size<1MB"}, strict: true, expected: []any{items[1]}},
+		{name: "not equal", filters: []string{"name!=Bob"}, expected: []any{items[0], items[2]}},
+		{name: "regex", filters: []string{"name~=^(A|C)"}, expected: []any{items[0], items[2]}},
+		{name: "in", filters: []string{"age in 25,40"}, expected: []any{items[1], items[2]}},
+		{name: "exists", filters: []string{"tags exists"}, expected: []any{items[0], items[2]}},
+		{name: "date comparison", filters: []string{"date>=2012-01-01"}, expected: []any{items[0], items[1]}},
+		{name: "array any element", filters: []string{"tags=c"}, strict: true, expected: []any{items[2]}},
+		{name: "array not equal", filters: []string{"tags!=a"}, strict: true, expected: []any{items[2]}},
+		{name: "or", filters: []string{"name=Alice || name=Bob"}, expected: []any{items[0], items[1]}},
+		{name: "repeated filters are anded", filters: []string{"age>=25", "name~=o"}, expected: []any{items[1]}},
+		{name: "no match", filters: []string{"name=Eve"}, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FilterItems(items, tt.filters, tt.strict)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("FilterItems(%v) = %v, want %v", tt.filters, result, tt.expected)
+			}
+		})
+	}
+
+	if _, err := FilterItems([]any{"not a map"}, []string{"a=b"}, false); err == nil {
+		t.Error("expected error for non-map item")
+	}
+}
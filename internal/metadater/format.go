@@ -0,0 +1,470 @@
+package metadater
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+// normalize converts data to the generic JSON types (map[string]any, []any,
+// string, float64, bool and nil) so that renderers only handle those.
+func normalize(data any) (any, error) {
+	switch data.(type) {
+	case nil, string, float64, bool:
+		return data, nil
+	case map[string]any, []any:
+		if isGeneric(data) {
+			return data, nil
+		}
+	}
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var result any
+	if err := json.Unmarshal(jsonBytes, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func isGeneric(data any) bool {
+	switch t := data.(type) {
+	case nil, string, float64, bool:
+		return true
+	case map[string]any:
+		for _, v := range t {
+			if !isGeneric(v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		for _, v := range t {
+			if !isGeneric(v) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatFloat(f, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// quoteString returns s as a double-quoted string with JSON escapes, which
+// is also valid in YAML and TOML.
+func quoteString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// scalarString renders a scalar for the tree and template output.
+func scalarString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return formatNumber(t)
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// isBlock reports whether v is rendered over several lines.
+func isBlock(v any) bool {
+	switch t := v.(type) {
+	case map[string]any:
+		return len(t) > 0
+	case []any:
+		return len(t) > 0
+	}
+	return false
+}
+
+// Tree output
+
+// FormatTree renders data as an indented tree with box-drawing connectors.
+func FormatTree(data any) (string, error) {
+	data, err := normalize(data)
+	if err != nil {
+		return "", err
+	}
+	if !isBlock(data) {
+		return scalarString(data), nil
+	}
+
+	var lines []string
+	switch t := data.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(t) {
+			lines = append(lines, treeEntry(k, t[k], "")...)
+		}
+	case []any:
+		// A list of scalars is printed one value per line.
+		scalars := true
+		for _, item := range t {
+			scalars = scalars && !isBlock(item)
+		}
+		for i, item := range t {
+			if scalars {
+				lines = append(lines, scalarString(item))
+			} else {
+				lines = append(lines, treeEntry(fmt.Sprintf("[%d]", i), item, "")...)
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// treeEntry renders a labelled value; children are prefixed with prefix.
+func treeEntry(label string, v any, prefix string) []string {
+	if !isBlock(v) {
+		return []string{label + ": " + scalarString(v)}
+	}
+
+	type child struct {
+		label string
+		value any
+	}
+	var children []child
+	switch t := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(t) {
+			children = append(children, child{k, t[k]})
+		}
+	case []any:
+		for i, item := range t {
+			children = append(children, child{fmt.Sprintf("[%d]", i), item})
+		}
+	}
+
+	lines := []string{label}
+	for i, c := range children {
+		connector, indent := "├── ", "│   "
+		if i == len(children)-1 {
+			connector, indent = "└── ", "    "
+		}
+		sub := treeEntry(c.label, c.value, prefix+indent)
+		lines = append(lines, prefix+connector+sub[0])
+		lines = append(lines, sub[1:]...)
+	}
+	return lines
+}
+
+// YAML output
+
+var (
+	yamlSpecial   = regexp.MustCompile(`^[-?:,\[\]{}#&*!|>'"%@` + "`" + `]|: | #|^\s|\s$|[\n\t]`)
+	yamlAmbiguous = regexp.MustCompile(`^(?i:true|false|yes|no|on|off|y|n|null|~|[-+]?(\.inf|\.nan)|[-+]?[0-9][0-9_.eE+-]*|0x[0-9a-fA-F]+|0o[0-7]+)$`)
+)
+
+func yamlString(s string) string {
+	if s == "" || yamlSpecial.MatchString(s) || yamlAmbiguous.MatchString(s) || strings.HasSuffix(s, ":") {
+		return quoteString(s)
+	}
+	return s
+}
+
+func yamlScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return yamlString(t)
+	case float64:
+		return formatNumber(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	}
+	return yamlString(fmt.Sprintf("%v", v))
+}
+
+// FormatYAML renders data as a YAML document.
+func FormatYAML(data any) (string, error) {
+	data, err := normalize(data)
+	if err != nil {
+		return "", err
+	}
+	if !isBlock(data) {
+		return yamlScalar(data), nil
+	}
+	var b strings.Builder
+	writeYAML(&b, data, 0)
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// writeYAML writes a non-empty map or list with the given indentation.
+func writeYAML(b *strings.Builder, v any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch t := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(t) {
+			child := t[k]
+			b.WriteString(pad + yamlString(k) + ":")
+			if isBlock(child) {
+				b.WriteString("\n")
+				writeYAML(b, child, indent+2)
+			} else {
+				b.WriteString(" " + yamlScalar(child) + "\n")
+			}
+		}
+	case []any:
+		for _, item := range t {
+			if !isBlock(item) {
+				b.WriteString(pad + "- " + yamlScalar(item) + "\n")
+				continue
+			}
+			// Render the item one level deeper and put the dash on its
+			// first line.
+			var sub strings.Builder
+			writeYAML(&sub, item, indent+2)
+			b.WriteString(pad + "- " + strings.TrimPrefix(sub.String(), pad+"  "))
+		}
+	}
+}
+
+// TOML output
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if tomlBareKey.MatchString(k) {
+		return k
+	}
+	return quoteString(k)
+}
+
+// tomlInline renders a value in inline form. TOML has no null, so null
+// values are rendered as empty strings inside arrays and inline tables.
+func tomlInline(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return `""`
+	case string:
+		return quoteString(t)
+	case float64:
+		return formatNumber(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case []any:
+		items := make([]string, len(t))
+		for i, item := range t {
+			items[i] = tomlInline(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		var items []string
+		for _, k := range sortedKeys(t) {
+			items = append(items, tomlKey(k)+" = "+tomlInline(t[k]))
+		}
+		if len(items) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	}
+	return quoteString(fmt.Sprintf("%v", v))
+}
+
+// isTableArray reports whether v is a non-empty list of objects.
+func isTableArray(v any) bool {
+	list, ok := v.([]any)
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, item := range list {
+		if _, ok := item.(map[string]any); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatTOML renders data as a TOML document. Data that is not an object is
+// wrapped in a table under the key "value"; null values are omitted.
+func FormatTOML(data any) (string, error) {
+	data, err := normalize(data)
+	if err != nil {
+		return "", err
+	}
+	table, ok := data.(map[string]any)
+	if !ok {
+		table = map[string]any{"value": data}
+	}
+	var b strings.Builder
+	writeTOMLTable(&b, table, nil)
+	return strings.TrimSpace(b.String()), nil
+}
+
+func writeTOMLTable(b *strings.Builder, table map[string]any, path []string) {
+	keys := sortedKeys(table)
+
+	// Plain key/value pairs must precede sub-tables.
+	for _, k := range keys {
+		v := table[k]
+		if v == nil {
+			continue
+		}
+		if _, isMap := v.(map[string]any); isMap || isTableArray(v) {
+			continue
+		}
+		b.WriteString(tomlKey(k) + " = " + tomlInline(v) + "\n")
+	}
+
+	for _, k := range keys {
+		childPath := append(append([]string{}, path...), tomlKey(k))
+		switch v := table[k].(type) {
+		case map[string]any:
+			b.WriteString("\n[" + strings.Join(childPath, ".") + "]\n")
+			writeTOMLTable(b, v, childPath)
+		case []any:
+			if !isTableArray(v) {
+				continue
+			}
+			for _, item := range v {
+				b.WriteString("\n[[" + strings.Join(childPath, ".") + "]]\n")
+				writeTOMLTable(b, item.(map[string]any), childPath)
+			}
+		}
+	}
+}
+
+// Template output
+
+// isEmpty reports whether v is nil or the zero value of its type.
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+var templateFuncs = template.FuncMap{
+	// formatBytes renders a byte count, e.g. {{formatBytes .size}}.
+	"formatBytes": func(v any) (string, error) {
+		n, ok := toNumber(v)
+		if !ok {
+			return "", fmt.Errorf("formatBytes: not a number: %v", v)
+		}
+		return utils.FormatBytes(n), nil
+	},
+	// join joins list items, e.g. {{join ", " .keywords}}.
+	"join": func(sep string, v any) string {
+		list, ok := v.([]any)
+		if !ok {
+			if v == nil {
+				return ""
+			}
+			return scalarString(v)
+		}
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = scalarString(item)
+		}
+		return strings.Join(items, sep)
+	},
+	// default returns def when v is empty, e.g. {{.doi | default "n/a"}}.
+	"default": func(def any, v any) any {
+		if isEmpty(v) {
+			return def
+		}
+		return v
+	},
+	// json renders v as compact JSON.
+	"json": func(v any) (string, error) {
+		jsonBytes, err := json.Marshal(v)
+		return string(jsonBytes), err
+	},
+	// query evaluates a query expression, e.g. {{query . "files | length"}}.
+	"query": func(v any, expr string) (any, error) {
+		return Query(v, expr)
+	},
+}
+
+// ParseTemplate compiles a text/template with the metadata helper functions.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(templateFuncs).Parse(text)
+}
+
+// integerize converts whole numbers to int64 so that templates print sizes
+// and record IDs without an exponent.
+func integerize(v any) any {
+	switch t := v.(type) {
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1e15 {
+			return int64(t)
+		}
+	case map[string]any:
+		result := make(map[string]any, len(t))
+		for k, item := range t {
+			result[k] = integerize(item)
+		}
+		return result
+	case []any:
+		result := make([]any, len(t))
+		for i, item := range t {
+			result[i] = integerize(item)
+		}
+		return result
+	}
+	return v
+}
+
+// FormatTemplate executes a compiled template against data.
+func FormatTemplate(tmpl *template.Template, data any) (string, error) {
+	data, err := normalize(data)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, integerize(data)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Render formats data with tmpl if given, or else in the named format.
+func Render(data any, format string, tmpl *template.Template) (string, error) {
+	if tmpl != nil {
+		return FormatTemplate(tmpl, data)
+	}
+	return FormatOutput(data, format)
+}
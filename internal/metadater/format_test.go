@@ -0,0 +1,155 @@
+package metadater
+
+import (
+	"strings"
+	"testing"
+)
+
+func formatRecord() map[string]any {
+	return map[string]any{
+		"title": "Test: Record",
+		"recid": float64(5500),
+		"files": []any{
+			map[string]any{"key": "a.root", "size": float64(2048)},
+			map[string]any{"key": "b.root", "size": float64(1.5e9)},
+		},
+		"keywords":       []any{"muon", "2012"},
+		"system_details": map[string]any{"global_tag": "FT_R_53"},
+		"empty":          []any{},
+		"note":           nil,
+	}
+}
+
+func TestFormatTree(t *testing.T) {
+	got, err := FormatTree(formatRecord())
+	if err != nil {
+		t.Fatalf("FormatTree failed: %v", err)
+	}
+	want := strings.Join([]string{
+		"empty: []",
+		"files",
+		"├── [0]",
+		"│   ├── key: a.root",
+		"│   └── size: 2048",
+		"└── [1]",
+		"    ├── key: b.root",
+		"    └── size: 1500000000",
+		"keywords",
+		"├── [0]: muon",
+		"└── [1]: 2012",
+		"note: null",
+		"recid: 5500",
+		"system_details",
+		"└── global_tag: FT_R_53",
+		"title: Test: Record",
+	}, "\n")
+	if got != want {
+		t.Errorf("FormatTree() =\n%s\nwant\n%s", got, want)
+	}
+
+	scalars, _ := FormatTree([]any{"a", float64(1)})
+	if scalars != "a\n1" {
+		t.Errorf("FormatTree(scalars) = %q", scalars)
+	}
+}
+
+func TestFormatYAML(t *testing.T) {
+	got, err := FormatYAML(formatRecord())
+	if err != nil {
+		t.Fatalf("FormatYAML failed: %v", err)
+	}
+	want := strings.Join([]string{
+		"empty: []",
+		"files:",
+		"  - key: a.root",
+		"    size: 2048",
+		"  - key: b.root",
+		"    size: 1500000000",
+		"keywords:",
+		"  - muon",
+		`  - "2012"`,
+		"note: null",
+		"recid: 5500",
+		"system_details:",
+		"  global_tag: FT_R_53",
+		`title: "Test: Record"`,
+	}, "\n")
+	if got != want {
+		t.Errorf("FormatYAML() =\n%s\nwant\n%s", got, want)
+	}
+
+	nested, _ := FormatYAML([]any{[]any{"a", "b"}, "c"})
+	if nested != "- - a\n  - b\n- c" {
+		t.Errorf("FormatYAML(nested) = %q", nested)
+	}
+}
+
+func TestFormatTOML(t *testing.T) {
+	got, err := FormatTOML(formatRecord())
+	if err != nil {
+		t.Fatalf("FormatTOML failed: %v", err)
+	}
+	want := strings.Join([]string{
+		"empty = []",
+		`keywords = ["muon", "2012"]`,
+		"recid = 5500",
+		`title = "Test: Record"`,
+		"",
+		"[[files]]",
+		`key = "a.root"`,
+		"size = 2048",
+		"",
+		"[[files]]",
+		`key = "b.root"`,
+		"size = 1500000000",
+		"",
+		"[system_details]",
+		`global_tag = "FT_R_53"`,
+	}, "\n")
+	if got != want {
+		t.Errorf("FormatTOML() =\n%s\nwant\n%s", got, want)
+	}
+
+	scalar, _ := FormatTOML("title")
+	if scalar != `value = "title"` {
+		t.Errorf("FormatTOML(scalar) = %q", scalar)
+	}
+}
+
+func TestFormatTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "field", template: "{{.title}} {{.recid}}", want: "Test: Record 5500"},
+		{name: "range", template: "{{range .files}}{{.key}} {{end}}", want: "a.root b.root "},
+		{name: "formatBytes", template: "{{range .files}}{{formatBytes .size}};{{end}}", want: "2.0 KB;1.4 GB;"},
+		{name: "join", template: `{{join ", " .keywords}}`, want: "muon, 2012"},
+		{name: "default", template: `{{.doi | default "n/a"}} {{.title | default "n/a"}}`, want: "n/a Test: Record"},
+		{name: "query", template: `{{query . "files | length"}}`, want: "2"},
+		{name: "json", template: "{{json .system_details}}", want: `{"global_tag":"FT_R_53"}`},
+		{name: "formatBytes error", template: "{{formatBytes .title}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("ParseTemplate failed: %v", err)
+			}
+			got, err := FormatTemplate(tmpl, formatRecord())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FormatTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("FormatTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseTemplate("{{.title"); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
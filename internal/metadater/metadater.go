@@ -66,9 +66,9 @@ func GetNestedField(record any, path string) (any, error) {
 		return record, nil
 	}
 
-	// Record is now a map, delegate to ExtractNestedField
+	// Record is now a map, delegate to Query
 	if recordMap, ok := record.(map[string]any); ok {
-		return ExtractNestedField(recordMap, path)
+		return Query(recordMap, path)
 	}
 
 	// Fallback: try to convert to map (backward compatibility)
@@ -82,52 +82,13 @@ func GetNestedField(record any, path string) (any, error) {
 		return nil, fmt.Errorf("failed to unmarshal record: %w", err)
 	}
 
-	return ExtractNestedField(recordMap, path)
+	return Query(recordMap, path)
 }
 
+// FilterArray returns the items matching all filters. Items lacking a
+// filtered field are kept; use FilterItems for strict matching.
 func FilterArray(items []any, filters []string) ([]any, error) {
-	if len(items) == 0 {
-		return items, nil
-	}
-
-	if len(filters) == 0 {
-		return items, nil
-	}
-
-	var result []any
-
-	filterMap := make(map[string]string)
-	for _, f := range filters {
-		parts := strings.SplitN(f, "=", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid filter format: %s", f)
-		}
-		filterMap[parts[0]] = parts[1]
-	}
-
-	for _, item := range items {
-		itemMap, ok := item.(map[string]any)
-		if !ok {
-			return nil, fmt.Errorf("item is not a map")
-		}
-
-		match := true
-		for field, value := range filterMap {
-			if itemValue, exists := itemMap[field]; exists {
-				itemStr := fmt.Sprintf("%v", itemValue)
-				if itemStr != value {
-					match = false
-					break
-				}
-			}
-		}
-
-		if match {
-			result = append(result, item)
-		}
-	}
-
-	return result, nil
+	return FilterItems(items, filters, false)
 }
 
 func FormatOutput(data any, format string) (string, error) {
@@ -139,7 +100,11 @@ func FormatOutput(data any, format string) (string, error) {
 		}
 		return string(jsonBytes), nil
 	case "pretty":
-		return fmt.Sprintf("%+v\n", data), nil
+		return FormatTree(data)
+	case "yaml":
+		return FormatYAML(data)
+	case "toml":
+		return FormatTOML(data)
 	default:
 		return "", fmt.Errorf("unknown format: %s", format)
 	}
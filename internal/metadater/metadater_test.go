@@ -144,6 +144,16 @@ func TestFormatOutput(t *testing.T) {
 			format:  "pretty",
 			wantErr: false,
 		},
+		{
+			name:    "yaml format",
+			format:  "yaml",
+			wantErr: false,
+		},
+		{
+			name:    "toml format",
+			format:  "toml",
+			wantErr: false,
+		},
 		{
 			name:    "invalid format",
 			format:  "invalid",
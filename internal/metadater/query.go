@@ -0,0 +1,1155 @@
+package metadater
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query expressions are a small jq/JSONPath-like language for selecting
+// values from record metadata:
+//
+//	title                     field access (leading dot optional)
+//	files.uri                 field access maps over arrays
+//	files[0]  files[-1]       array indexing
+//	files[1:3]                array slicing
+//	files[*]  system.*        all elements or values
+//	..checksum                recursive descent
+//	files[?size>1e9].uri      filtering (==, !=, <, <=, >, >=, =~, and, or, !)
+//	files | length            pipes and functions
+//	{title, doi, "n": (files | length)}
+//	                          object construction
+//
+// Supported functions are length, keys, values, first, last, sum, min, max,
+// sort, unique and flatten. A bare name is read as a field at the start of
+// an expression and as a function after a pipe; use .name for a field there.
+
+// plainPath matches the dot-path syntax handled by ExtractNestedField.
+var plainPath = regexp.MustCompile(`^[^.\[\]{}()|?*@"'\s,:!=<>~]+(\.[^.\[\]{}()|?*@"'\s,:!=<>~]+)*$`)
+
+// Query evaluates a query expression against data. Plain dot paths are
+// evaluated by ExtractNestedField so that existing paths behave as before.
+func Query(data any, expr string) (any, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return data, nil
+	}
+	if plainPath.MatchString(expr) {
+		return ExtractNestedField(data, expr)
+	}
+
+	node, err := ParseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return node.eval(data)
+}
+
+// QueryNode is a compiled query expression.
+type QueryNode interface {
+	eval(input any) (any, error)
+}
+
+// EvaluateQuery evaluates a compiled query against data.
+func EvaluateQuery(node QueryNode, data any) (any, error) {
+	return node.eval(data)
+}
+
+// ParseQuery compiles a query expression.
+func ParseQuery(expr string) (QueryNode, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parsePipe(true)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return node, nil
+}
+
+// Tokenizer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokDot
+	tokDotDot
+	tokLBrack
+	tokRBrack
+	tokLBrace
+	tokRBrace
+	tokLParen
+	tokRParen
+	tokPipe
+	tokComma
+	tokColon
+	tokQuestion
+	tokStar
+	tokAt
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '.':
+			if i+1 < len(runes) && runes[i+1] == '.' {
+				tokens = append(tokens, token{kind: tokDotDot, text: "..", pos: start})
+				i += 2
+			} else {
+				tokens = append(tokens, token{kind: tokDot, text: ".", pos: start})
+				i++
+			}
+			continue
+		case r == '"' || r == '\'':
+			quote := r
+			i++
+			var b strings.Builder
+			for i < len(runes) && runes[i] != quote {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start})
+			continue
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				// Stop before "..", which cannot be part of a number.
+				if runes[i] == '.' && i+1 < len(runes) && runes[i+1] == '.' {
+					break
+				}
+				i++
+			}
+			text := string(runes[start:i])
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, num: num, pos: start})
+			continue
+		case isIdentStart(r):
+			for i < len(runes) && isIdentPart(runes[i]) {
+				i++
+			}
+			text := string(runes[start:i])
+			switch text {
+			case "and":
+				tokens = append(tokens, token{kind: tokAnd, text: text, pos: start})
+			case "or":
+				tokens = append(tokens, token{kind: tokOr, text: text, pos: start})
+			case "not":
+				tokens = append(tokens, token{kind: tokNot, text: text, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokIdent, text: text, pos: start})
+			}
+			continue
+		}
+
+		two := ""
+		if i+1 < len(runes) {
+			two = string(runes[i : i+2])
+		}
+		switch two {
+		case "==", "!=", "<=", ">=", "=~":
+			tokens = append(tokens, token{kind: tokOp, text: two, pos: start})
+			i += 2
+			continue
+		case "&&":
+			tokens = append(tokens, token{kind: tokAnd, text: two, pos: start})
+			i += 2
+			continue
+		case "||":
+			tokens = append(tokens, token{kind: tokOr, text: two, pos: start})
+			i += 2
+			continue
+		}
+
+		kinds := map[rune]tokenKind{
+			'[': tokLBrack, ']': tokRBrack, '{': tokLBrace, '}': tokRBrace,
+			'(': tokLParen, ')': tokRParen, '|': tokPipe, ',': tokComma,
+			':': tokColon, '?': tokQuestion, '*': tokStar, '@': tokAt, '!': tokNot,
+		}
+		if kind, ok := kinds[r]; ok {
+			tokens = append(tokens, token{kind: kind, text: string(r), pos: start})
+			i++
+			continue
+		}
+		if r == '<' || r == '>' {
+			tokens = append(tokens, token{kind: tokOp, text: string(r), pos: start})
+			i++
+			continue
+		}
+		if r == '=' {
+			tokens = append(tokens, token{kind: tokOp, text: "==", pos: start})
+			i++
+			continue
+		}
+		return nil, fmt.Errorf("unexpected character %q at position %d", r, start)
+	}
+	tokens = append(tokens, token{kind: tokEOF, text: "end of expression", pos: len(runes)})
+	return tokens, nil
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("expected %s at position %d, got %q", what, tok.pos, tok.text)
+	}
+	return tok, nil
+}
+
+// parsePipe parses term ('|' term)*. The first term of the whole expression
+// treats bare names as fields; later terms treat known names as functions.
+func (p *parser) parsePipe(first bool) (QueryNode, error) {
+	left, err := p.parseTerm(first)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokPipe {
+		p.next()
+		right, err := p.parseTerm(false)
+		if err != nil {
+			return nil, err
+		}
+		left = &pipeNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+var queryFunctions = map[string]func(any) (any, error){
+	"length":  fnLength,
+	"keys":    fnKeys,
+	"values":  fnValues,
+	"first":   fnFirst,
+	"last":    fnLast,
+	"sum":     fnSum,
+	"min":     fnMin,
+	"max":     fnMax,
+	"sort":    fnSort,
+	"unique":  fnUnique,
+	"flatten": fnFlatten,
+}
+
+func (p *parser) parseTerm(first bool) (QueryNode, error) {
+	var node QueryNode = identityNode{}
+	tok := p.peek()
+
+	switch tok.kind {
+	case tokIdent:
+		if fn, ok := queryFunctions[tok.text]; ok && !first {
+			p.next()
+			node = &funcNode{name: tok.text, fn: fn}
+			return p.parsePostfix(node)
+		}
+		p.next()
+		node = &fieldNode{base: node, name: tok.text}
+	case tokDot:
+		p.next()
+		next := p.peek()
+		switch next.kind {
+		case tokIdent, tokString:
+			p.next()
+			node = &fieldNode{base: node, name: next.text}
+		case tokStar:
+			p.next()
+			node = &iterNode{base: node}
+		}
+	case tokDotDot:
+		// handled as postfix
+	case tokLBrace:
+		obj, err := p.parseObject()
+		if err != nil {
+			return nil, err
+		}
+		node = obj
+	case tokLParen:
+		p.next()
+		inner, err := p.parsePipe(false)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		node = inner
+	case tokString:
+		p.next()
+		return &literalNode{value: tok.text}, nil
+	case tokNumber:
+		p.next()
+		return &literalNode{value: tok.num}, nil
+	case tokLBrack:
+		// postfix on identity, e.g. "[0]"
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return p.parsePostfix(node)
+}
+
+func (p *parser) parsePostfix(node QueryNode) (QueryNode, error) {
+	for {
+		tok := p.peek()
+		switch tok.kind {
+		case tokDot:
+			p.next()
+			next := p.next()
+			switch next.kind {
+			case tokIdent, tokString:
+				node = &fieldNode{base: node, name: next.text}
+			case tokStar:
+				node = &iterNode{base: node}
+			default:
+				return nil, fmt.Errorf("expected field name at position %d, got %q", next.pos, next.text)
+			}
+		case tokDotDot:
+			p.next()
+			name := ""
+			if next := p.peek(); next.kind == tokIdent || next.kind == tokString {
+				p.next()
+				name = next.text
+			}
+			node = &recurseNode{base: node, name: name}
+		case tokLBrack:
+			p.next()
+			bracket, err := p.parseBracket(node)
+			if err != nil {
+				return nil, err
+			}
+			node = bracket
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (p *parser) parseBracket(base QueryNode) (QueryNode, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokRBrack:
+		p.next()
+		return &iterNode{base: base}, nil
+	case tokStar:
+		p.next()
+		if _, err := p.expect(tokRBrack, "']'"); err != nil {
+			return nil, err
+		}
+		return &iterNode{base: base}, nil
+	case tokString:
+		p.next()
+		if _, err := p.expect(tokRBrack, "']'"); err != nil {
+			return nil, err
+		}
+		return &fieldNode{base: base, name: tok.text}, nil
+	case tokQuestion:
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRBrack, "']'"); err != nil {
+			return nil, err
+		}
+		return &filterNode{base: base, cond: cond}, nil
+	}
+
+	var start, end *int
+	if tok.kind == tokNumber {
+		p.next()
+		n, err := intFromToken(tok)
+		if err != nil {
+			return nil, err
+		}
+		start = &n
+	}
+	if p.peek().kind == tokColon {
+		p.next()
+		if next := p.peek(); next.kind == tokNumber {
+			p.next()
+			n, err := intFromToken(next)
+			if err != nil {
+				return nil, err
+			}
+			end = &n
+		}
+		if _, err := p.expect(tokRBrack, "']'"); err != nil {
+			return nil, err
+		}
+		return &sliceNode{base: base, start: start, end: end}, nil
+	}
+	if start == nil {
+		return nil, fmt.Errorf("expected index at position %d, got %q", tok.pos, tok.text)
+	}
+	if _, err := p.expect(tokRBrack, "']'"); err != nil {
+		return nil, err
+	}
+	return &indexNode{base: base, index: *start}, nil
+}
+
+func intFromToken(tok token) (int, error) {
+	if tok.num != math.Trunc(tok.num) {
+		return 0, fmt.Errorf("index must be an integer at position %d, got %s", tok.pos, tok.text)
+	}
+	return int(tok.num), nil
+}
+
+func (p *parser) parseObject() (QueryNode, error) {
+	if _, err := p.expect(tokLBrace, "'{'"); err != nil {
+		return nil, err
+	}
+	obj := &objectNode{}
+	if p.peek().kind == tokRBrace {
+		p.next()
+		return obj, nil
+	}
+	for {
+		keyTok := p.next()
+		if keyTok.kind != tokIdent && keyTok.kind != tokString {
+			return nil, fmt.Errorf("expected object key at position %d, got %q", keyTok.pos, keyTok.text)
+		}
+		var value QueryNode = &fieldNode{base: identityNode{}, name: keyTok.text}
+		if p.peek().kind == tokColon {
+			p.next()
+			v, err := p.parsePipe(true)
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		}
+		obj.keys = append(obj.keys, keyTok.text)
+		obj.values = append(obj.values, value)
+
+		sep := p.next()
+		if sep.kind == tokRBrace {
+			return obj, nil
+		}
+		if sep.kind != tokComma {
+			return nil, fmt.Errorf("expected ',' or '}' at position %d, got %q", sep.pos, sep.text)
+		}
+	}
+}
+
+// Conditions
+
+func (p *parser) parseOr() (condNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condNode, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notCond{inner: inner}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokOp {
+		return &truthyCond{operand: left}, nil
+	}
+	op := p.next().text
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	cmp := &compareCond{left: left, op: op, right: right}
+	if op == "=~" {
+		lit, ok := right.(*literalNode)
+		if !ok {
+			return nil, fmt.Errorf("=~ requires a string pattern")
+		}
+		pattern, ok := lit.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("=~ requires a string pattern")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+		cmp.re = re
+	}
+	return cmp, nil
+}
+
+func (p *parser) parseOperand() (QueryNode, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokString:
+		p.next()
+		return &literalNode{value: tok.text}, nil
+	case tokNumber:
+		p.next()
+		return &literalNode{value: tok.num}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			p.next()
+			return &literalNode{value: true}, nil
+		case "false":
+			p.next()
+			return &literalNode{value: false}, nil
+		case "null":
+			p.next()
+			return &literalNode{value: nil}, nil
+		}
+		p.next()
+		return p.parsePostfix(&fieldNode{base: identityNode{}, name: tok.text})
+	case tokAt:
+		p.next()
+		return p.parsePostfix(identityNode{})
+	case tokDot:
+		return p.parseTerm(true)
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// Evaluation
+
+type identityNode struct{}
+
+func (identityNode) eval(input any) (any, error) { return input, nil }
+
+type literalNode struct{ value any }
+
+func (n *literalNode) eval(any) (any, error) { return n.value, nil }
+
+type pipeNode struct{ left, right QueryNode }
+
+func (n *pipeNode) eval(input any) (any, error) {
+	v, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return n.right.eval(v)
+}
+
+type fieldNode struct {
+	base QueryNode
+	name string
+}
+
+func getField(v any, name string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		return t[name]
+	case []any:
+		results := []any{}
+		for _, item := range t {
+			if r := getField(item, name); r != nil {
+				results = append(results, r)
+			}
+		}
+		return results
+	}
+	return nil
+}
+
+func (n *fieldNode) eval(input any) (any, error) {
+	v, err := n.base.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return getField(v, n.name), nil
+}
+
+type indexNode struct {
+	base  QueryNode
+	index int
+}
+
+func (n *indexNode) eval(input any) (any, error) {
+	v, err := n.base.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		if v == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot index %T", v)
+	}
+	i := n.index
+	if i < 0 {
+		i += len(arr)
+	}
+	if i < 0 || i >= len(arr) {
+		return nil, nil
+	}
+	return arr[i], nil
+}
+
+type sliceNode struct {
+	base       QueryNode
+	start, end *int
+}
+
+func (n *sliceNode) eval(input any) (any, error) {
+	v, err := n.base.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		if v == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot slice %T", v)
+	}
+	clamp := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += len(arr)
+		}
+		return max(0, min(i, len(arr)))
+	}
+	start, end := clamp(n.start, 0), clamp(n.end, len(arr))
+	if start >= end {
+		return []any{}, nil
+	}
+	return append([]any{}, arr[start:end]...), nil
+}
+
+type iterNode struct{ base QueryNode }
+
+func (n *iterNode) eval(input any) (any, error) {
+	v, err := n.base.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case []any:
+		return t, nil
+	case map[string]any:
+		return fnValues(t)
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %T", v)
+}
+
+type recurseNode struct {
+	base QueryNode
+	name string
+}
+
+func collectRecursive(v any, name string, out *[]any) {
+	switch t := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if name == "" || k == name {
+				*out = append(*out, t[k])
+			}
+			collectRecursive(t[k], name, out)
+		}
+	case []any:
+		for _, item := range t {
+			if name == "" {
+				*out = append(*out, item)
+			}
+			collectRecursive(item, name, out)
+		}
+	}
+}
+
+func (n *recurseNode) eval(input any) (any, error) {
+	v, err := n.base.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	results := []any{}
+	collectRecursive(v, n.name, &results)
+	return results, nil
+}
+
+type filterNode struct {
+	base QueryNode
+	cond condNode
+}
+
+func (n *filterNode) filter(v any) (any, error) {
+	switch t := v.(type) {
+	case []any:
+		results := []any{}
+		for _, item := range t {
+			if nested, ok := item.([]any); ok {
+				filtered, err := n.filter(nested)
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, filtered)
+				continue
+			}
+			ok, err := n.cond.test(item)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				results = append(results, item)
+			}
+		}
+		return results, nil
+	case nil:
+		return nil, nil
+	default:
+		ok, err := n.cond.test(t)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return []any{t}, nil
+		}
+		return []any{}, nil
+	}
+}
+
+func (n *filterNode) eval(input any) (any, error) {
+	v, err := n.base.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return n.filter(v)
+}
+
+type objectNode struct {
+	keys   []string
+	values []QueryNode
+}
+
+func (n *objectNode) eval(input any) (any, error) {
+	result := make(map[string]any, len(n.keys))
+	for i, key := range n.keys {
+		v, err := n.values[i].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = v
+	}
+	return result, nil
+}
+
+type funcNode struct {
+	name string
+	fn   func(any) (any, error)
+}
+
+func (n *funcNode) eval(input any) (any, error) {
+	v, err := n.fn(input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return v, nil
+}
+
+// Conditions
+
+type condNode interface {
+	test(item any) (bool, error)
+}
+
+type andCond struct{ left, right condNode }
+
+func (c *andCond) test(item any) (bool, error) {
+	ok, err := c.left.test(item)
+	if err != nil || !ok {
+		return false, err
+	}
+	return c.right.test(item)
+}
+
+type orCond struct{ left, right condNode }
+
+func (c *orCond) test(item any) (bool, error) {
+	ok, err := c.left.test(item)
+	if err != nil || ok {
+		return ok, err
+	}
+	return c.right.test(item)
+}
+
+type notCond struct{ inner condNode }
+
+func (c *notCond) test(item any) (bool, error) {
+	ok, err := c.inner.test(item)
+	return !ok, err
+}
+
+type truthyCond struct{ operand QueryNode }
+
+func (c *truthyCond) test(item any) (bool, error) {
+	v, err := c.operand.eval(item)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case []any:
+		return len(t) > 0
+	}
+	return true
+}
+
+type compareCond struct {
+	left  QueryNode
+	op    string
+	right QueryNode
+	re    *regexp.Regexp
+}
+
+func (c *compareCond) test(item any) (bool, error) {
+	left, err := c.left.eval(item)
+	if err != nil {
+		return false, err
+	}
+	if c.re != nil {
+		if left == nil {
+			return false, nil
+		}
+		return c.re.MatchString(fmt.Sprintf("%v", left)), nil
+	}
+	right, err := c.right.eval(item)
+	if err != nil {
+		return false, err
+	}
+	return CompareValues(left, c.op, right), nil
+}
+
+// toNumber converts JSON numbers and numeric strings to float64.
+func toNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// CompareValues compares two values with one of ==, !=, <, <=, > or >=.
+// Values are compared numerically when both are numbers or numeric strings,
+// and as strings otherwise. Comparisons involving a missing value are false,
+// except for != and == against null.
+func CompareValues(left any, op string, right any) bool {
+	if left == nil || right == nil {
+		switch op {
+		case "==":
+			return left == nil && right == nil
+		case "!=":
+			return (left == nil) != (right == nil)
+		}
+		return false
+	}
+
+	var cmp int
+	lnum, lok := toNumber(left)
+	rnum, rok := toNumber(right)
+	if lok && rok {
+		switch {
+		case lnum < rnum:
+			cmp = -1
+		case lnum > rnum:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(fmt.Sprintf("%v", left), fmt.Sprintf("%v", right))
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// Functions
+
+func fnLength(v any) (any, error) {
+	switch t := v.(type) {
+	case nil:
+		return float64(0), nil
+	case []any:
+		return float64(len(t)), nil
+	case map[string]any:
+		return float64(len(t)), nil
+	case string:
+		return float64(len([]rune(t))), nil
+	case float64:
+		return math.Abs(t), nil
+	}
+	return nil, fmt.Errorf("%T has no length", v)
+}
+
+func fnKeys(v any) (any, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%T has no keys", v)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]any, len(keys))
+	for i, k := range keys {
+		result[i] = k
+	}
+	return result, nil
+}
+
+func fnValues(v any) (any, error) {
+	switch t := v.(type) {
+	case []any:
+		return t, nil
+	case map[string]any:
+		keys, _ := fnKeys(t)
+		result := []any{}
+		for _, k := range keys.([]any) {
+			result = append(result, t[k.(string)])
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%T has no values", v)
+}
+
+func asArray(v any) ([]any, error) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %T", v)
+	}
+	return arr, nil
+}
+
+func fnFirst(v any) (any, error) {
+	arr, err := asArray(v)
+	if err != nil || len(arr) == 0 {
+		return nil, err
+	}
+	return arr[0], nil
+}
+
+func fnLast(v any) (any, error) {
+	arr, err := asArray(v)
+	if err != nil || len(arr) == 0 {
+		return nil, err
+	}
+	return arr[len(arr)-1], nil
+}
+
+func fnSum(v any) (any, error) {
+	arr, err := asArray(v)
+	if err != nil {
+		return nil, err
+	}
+	var sum float64
+	for _, item := range arr {
+		n, ok := toNumber(item)
+		if !ok {
+			return nil, fmt.Errorf("cannot sum %T", item)
+		}
+		sum += n
+	}
+	return sum, nil
+}
+
+func extreme(v any, less bool) (any, error) {
+	arr, err := asArray(v)
+	if err != nil || len(arr) == 0 {
+		return nil, err
+	}
+	op := ">"
+	if less {
+		op = "<"
+	}
+	best := arr[0]
+	for _, item := range arr[1:] {
+		if CompareValues(item, op, best) {
+			best = item
+		}
+	}
+	return best, nil
+}
+
+func fnMin(v any) (any, error) { return extreme(v, true) }
+
+func fnMax(v any) (any, error) { return extreme(v, false) }
+
+func fnSort(v any) (any, error) {
+	arr, err := asArray(v)
+	if err != nil {
+		return nil, err
+	}
+	result := append([]any{}, arr...)
+	sort.SliceStable(result, func(i, j int) bool { return CompareValues(result[i], "<", result[j]) })
+	return result, nil
+}
+
+func fnUnique(v any) (any, error) {
+	sorted, err := fnSort(v)
+	if err != nil {
+		return nil, err
+	}
+	result := []any{}
+	for i, item := range sorted.([]any) {
+		if i == 0 || !CompareValues(item, "==", result[len(result)-1]) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func fnFlatten(v any) (any, error) {
+	arr, err := asArray(v)
+	if err != nil {
+		return nil, err
+	}
+	result := []any{}
+	for _, item := range arr {
+		if nested, ok := item.([]any); ok {
+			flat, _ := fnFlatten(nested)
+			result = append(result, flat.([]any)...)
+		} else {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
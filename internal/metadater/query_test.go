@@ -0,0 +1,124 @@
+package metadater
+
+import (
+	"reflect"
+	"testing"
+)
+
+func queryRecord() map[string]any {
+	return map[string]any{
+		"title": "Test Record",
+		"doi":   "10.7483/OPENDATA.CMS.TEST",
+		"recid": "5500",
+		"authors": []any{
+			map[string]any{"name": "Alice", "orcid": "0000-0001"},
+			map[string]any{"name": "Bob"},
+		},
+		"files": []any{
+			map[string]any{"uri": "root://a.root", "size": float64(500), "checksum": "adler32:00000001"},
+			map[string]any{"uri": "root://b.root", "size": float64(2e9), "checksum": "adler32:00000002"},
+			map[string]any{"uri": "root://c.root", "size": float64(3e9), "checksum": "adler32:00000003"},
+		},
+		"system_details": map[string]any{"global_tag": "FT_R_53", "release": "CMSSW_5_3_32"},
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected any
+		wantErr  bool
+	}{
+		{name: "plain field", expr: "title", expected: "Test Record"},
+		{name: "plain nested path", expr: "system_details.global_tag", expected: "FT_R_53"},
+		{name: "plain path over array", expr: "authors.orcid", expected: []any{"0000-0001"}},
+		{name: "plain missing field", expr: "missing", wantErr: true},
+		{name: "leading dot", expr: ".title", expected: "Test Record"},
+		{name: "identity", expr: ".", expected: queryRecord()},
+		{name: "index", expr: "files[0].uri", expected: "root://a.root"},
+		{name: "negative index", expr: "files[-1].uri", expected: "root://c.root"},
+		{name: "index out of range", expr: "files[10]", expected: nil},
+		{name: "slice", expr: "files[1:].uri", expected: []any{"root://b.root", "root://c.root"}},
+		{name: "slice to", expr: "files[:1].uri", expected: []any{"root://a.root"}},
+		{name: "wildcard", expr: "files[*].size", expected: []any{float64(500), float64(2e9), float64(3e9)}},
+		{name: "map wildcard", expr: "system_details.*", expected: []any{"FT_R_53", "CMSSW_5_3_32"}},
+		{name: "bracket key", expr: `system_details["release"]`, expected: "CMSSW_5_3_32"},
+		{name: "recursive descent", expr: "..orcid", expected: []any{"0000-0001"}},
+		{name: "numeric filter", expr: "files[?size>1e9].uri", expected: []any{"root://b.root", "root://c.root"}},
+		{name: "filter with and", expr: `files[?size>1e9 and uri=="root://c.root"].uri`, expected: []any{"root://c.root"}},
+		{name: "filter with or", expr: "files[?size<1000 || size>=3e9].uri", expected: []any{"root://a.root", "root://c.root"}},
+		{name: "filter with @", expr: "files[?(@.size <= 500)].uri", expected: []any{"root://a.root"}},
+		{name: "filter exists", expr: "authors[?orcid].name", expected: []any{"Alice"}},
+		{name: "filter not exists", expr: "authors[?!orcid].name", expected: []any{"Bob"}},
+		{name: "filter regex", expr: `authors[?name =~ "^B"].name`, expected: []any{"Bob"}},
+		{name: "numeric string compare", expr: "[?recid == 5500].title", expected: []any{"Test Record"}},
+		{name: "pipe length", expr: "files | length", expected: float64(3)},
+		{name: "pipe sum", expr: "files.size | sum", expected: float64(500 + 2e9 + 3e9)},
+		{name: "pipe keys", expr: "system_details | keys", expected: []any{"global_tag", "release"}},
+		{name: "field named like function", expr: "files[0] | .uri", expected: "root://a.root"},
+		{
+			name:     "projection",
+			expr:     `{title, doi, "n": (files | length)}`,
+			expected: map[string]any{"title": "Test Record", "doi": "10.7483/OPENDATA.CMS.TEST", "n": float64(3)},
+		},
+		{
+			name:     "projection over array",
+			expr:     "files[?size>1e9] | first | {uri, size}",
+			expected: map[string]any{"uri": "root://b.root", "size": float64(2e9)},
+		},
+		{name: "bare field after pipe", expr: "files[:2] | uri", expected: []any{"root://a.root", "root://b.root"}},
+		{name: "unterminated string", expr: `files[?uri=="a]`, wantErr: true},
+		{name: "unterminated bracket", expr: "files[0", wantErr: true},
+		{name: "trailing input", expr: "files[0] )", wantErr: true},
+		{name: "bad regex", expr: `authors[?name =~ "("]`, wantErr: true},
+		{name: "regex against a path", expr: `authors[?name =~ name]`, wantErr: true},
+		{name: "regex against a number", expr: `authors[?name =~ 1]`, wantErr: true},
+		{name: "index non-array", expr: "title[0]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Query(queryRecord(), tt.expr)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error but got result %v", result)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Query(%q) = %#v, want %#v", tt.expr, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		left     any
+		op       string
+		right    any
+		expected bool
+	}{
+		{left: float64(10), op: ">", right: float64(9), expected: true},
+		{left: "10", op: ">", right: float64(9), expected: true},
+		{left: "abc", op: "<", right: "abd", expected: true},
+		{left: "abc", op: "==", right: "abc", expected: true},
+		{left: nil, op: "==", right: nil, expected: true},
+		{left: nil, op: "!=", right: "x", expected: true},
+		{left: nil, op: ">", right: float64(1), expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := CompareValues(tt.left, tt.op, tt.right); got != tt.expected {
+			t.Errorf("CompareValues(%v, %q, %v) = %v, want %v", tt.left, tt.op, tt.right, got, tt.expected)
+		}
+	}
+}
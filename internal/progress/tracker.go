@@ -0,0 +1,143 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clelange/cernopendata-client-go/internal/utils"
+)
+
+// Tracker displays the combined progress of work spread over many files,
+// such as hashing files in parallel. It is safe for concurrent use.
+type Tracker struct {
+	mu          sync.Mutex
+	label       string
+	totalBytes  int64
+	doneBytes   int64
+	totalFiles  int
+	doneFiles   int
+	startTime   time.Time
+	lastUpdate  time.Time
+	output      io.Writer
+	updateEvery time.Duration
+	now         func() time.Time
+}
+
+// NewTracker creates a Tracker for totalFiles files of totalBytes bytes.
+func NewTracker(label string, totalFiles int, totalBytes int64) *Tracker {
+	return &Tracker{
+		label:       label,
+		totalBytes:  totalBytes,
+		totalFiles:  totalFiles,
+		startTime:   time.Now(),
+		output:      os.Stdout,
+		updateEvery: 200 * time.Millisecond,
+		now:         time.Now,
+	}
+}
+
+// SetOutput sets where the progress line is written, standard output by
+// default.
+func (t *Tracker) SetOutput(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.output = w
+}
+
+// Add records n more bytes as done.
+func (t *Tracker) Add(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.doneBytes += n
+	t.update()
+}
+
+// FileDone records one more file as done.
+func (t *Tracker) FileDone() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.doneFiles++
+	t.update()
+}
+
+// Reader wraps r so that every byte read from it is added to the tracker.
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	return &trackedReader{reader: r, tracker: t}
+}
+
+// Clear erases the progress line so that other output can be printed.
+// The line is redrawn on the next update.
+func (t *Tracker) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = fmt.Fprintf(t.output, "\r%s\r", strings.Repeat(" ", 80))
+	t.lastUpdate = time.Time{}
+}
+
+// Finish prints the final progress line.
+func (t *Tracker) Finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = fmt.Fprintf(t.output, "\r%s\n", t.line(true))
+}
+
+// DoneBytes returns the number of bytes done so far.
+func (t *Tracker) DoneBytes() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.doneBytes
+}
+
+// update redraws the progress line at most every updateEvery.
+func (t *Tracker) update() {
+	now := t.now()
+	if now.Sub(t.lastUpdate) < t.updateEvery {
+		return
+	}
+	t.lastUpdate = now
+	_, _ = fmt.Fprintf(t.output, "\r%s", t.line(false))
+}
+
+// line formats the progress line.
+func (t *Tracker) line(final bool) string {
+	elapsed := t.now().Sub(t.startTime).Seconds()
+	if elapsed == 0 {
+		elapsed = 0.001 // Avoid division by zero
+	}
+	rate := float64(t.doneBytes) / elapsed
+
+	line := fmt.Sprintf("  -> %s: %d/%d files, %s / %s [%s]",
+		t.label, t.doneFiles, t.totalFiles,
+		utils.FormatBytes(float64(t.doneBytes)), utils.FormatBytes(float64(t.totalBytes)),
+		utils.FormatRate(rate))
+	if final {
+		line += fmt.Sprintf(" in %.1fs", elapsed)
+	} else if rate > 0 && t.totalBytes > t.doneBytes {
+		eta := time.Duration(float64(t.totalBytes-t.doneBytes) / rate * float64(time.Second))
+		line += fmt.Sprintf(" ETA %s", eta.Round(time.Second))
+	}
+
+	// Pad with spaces to overwrite any previous longer line
+	if padding := 80 - len(line); padding > 0 {
+		line += strings.Repeat(" ", padding)
+	}
+	return line
+}
+
+// trackedReader counts the bytes read through it.
+type trackedReader struct {
+	reader  io.Reader
+	tracker *Tracker
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.tracker.Add(int64(n))
+	}
+	return n, err
+}
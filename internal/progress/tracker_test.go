@@ -0,0 +1,60 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTracker_Concurrent(t *testing.T) {
+	tr := NewTracker("Hashing", 8, 8*1000)
+	tr.output = io.Discard
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, _ := io.ReadAll(tr.Reader(bytes.NewReader(make([]byte, 1000))))
+			if len(data) != 1000 {
+				t.Errorf("read %d bytes, want 1000", len(data))
+			}
+			tr.FileDone()
+		}()
+	}
+	wg.Wait()
+
+	if tr.DoneBytes() != 8000 {
+		t.Errorf("DoneBytes() = %d, want 8000", tr.DoneBytes())
+	}
+	if tr.doneFiles != 8 {
+		t.Errorf("doneFiles = %d, want 8", tr.doneFiles)
+	}
+}
+
+func TestTracker_Line(t *testing.T) {
+	var output bytes.Buffer
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	tr := NewTracker("Hashing", 4, 4096)
+	tr.output = &output
+	tr.startTime = start
+	tr.now = func() time.Time { return now }
+	tr.updateEvery = 0
+
+	now = start.Add(2 * time.Second)
+	tr.Add(1024)
+	tr.FileDone()
+	if got := output.String(); !strings.Contains(got, "Hashing: 1/4 files, 1.0 KB / 4.0 KB [512 B/s] ETA 6s") {
+		t.Errorf("unexpected progress line: %q", got)
+	}
+
+	output.Reset()
+	tr.Finish()
+	if got := output.String(); !strings.Contains(got, "in 2.0s") || !strings.HasSuffix(got, "\n") {
+		t.Errorf("unexpected final line: %q", got)
+	}
+}
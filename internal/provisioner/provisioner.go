@@ -0,0 +1,352 @@
+package provisioner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+// Supported output formats besides text and JSON.
+const (
+	FormatDockerfile = "dockerfile"
+	FormatApptainer  = "apptainer"
+	FormatShell      = "shell"
+)
+
+// ContainerImage is a container image listed in a record's system details.
+type ContainerImage struct {
+	Name     string `json:"name"`
+	Registry string `json:"registry,omitempty"`
+}
+
+// Link is a documentation link from a record's usage or methodology.
+type Link struct {
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+}
+
+// Environment is the software environment needed to work with a record.
+type Environment struct {
+	Recid           int              `json:"recid"`
+	Title           string           `json:"title,omitempty"`
+	Experiment      string           `json:"experiment,omitempty"`
+	Release         string           `json:"release,omitempty"`
+	GlobalTag       string           `json:"global_tag,omitempty"`
+	Architecture    string           `json:"architecture,omitempty"`
+	Image           string           `json:"image,omitempty"`
+	ContainerImages []ContainerImage `json:"container_images,omitempty"`
+	Description     string           `json:"description,omitempty"`
+	Usage           []Link           `json:"usage,omitempty"`
+	Methodology     []Link           `json:"methodology,omitempty"`
+}
+
+// scramArch matches SCRAM architectures such as slc6_amd64_gcc472.
+var scramArch = regexp.MustCompile(`(slc\d+|el\d+|cc\d+|cs\d+)_(amd64|aarch64|ppc64le)_gcc\d+`)
+
+func stringField(m map[string]any, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []any:
+		var parts []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+func parseLinks(section any) []Link {
+	m, ok := section.(map[string]any)
+	if !ok {
+		return nil
+	}
+	var links []Link
+	items, _ := m["links"].([]any)
+	for _, item := range items {
+		linkMap, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if url := stringField(linkMap, "url"); url != "" {
+			links = append(links, Link{Description: stringField(linkMap, "description"), URL: url})
+		}
+	}
+	return links
+}
+
+// FromMetadata extracts the environment from record metadata. It fails if
+// the record has no system details.
+func FromMetadata(metadata map[string]any) (*Environment, error) {
+	env := &Environment{
+		Title:       stringField(metadata, "title"),
+		Experiment:  stringField(metadata, "experiment"),
+		Usage:       parseLinks(metadata["usage"]),
+		Methodology: parseLinks(metadata["methodology"]),
+	}
+	switch v := metadata["recid"].(type) {
+	case float64:
+		env.Recid = int(v)
+	case string:
+		env.Recid, _ = strconv.Atoi(v)
+	}
+
+	details, ok := metadata["system_details"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("record %d has no system details", env.Recid)
+	}
+	env.Release = stringField(details, "release")
+	env.GlobalTag = stringField(details, "global_tag")
+	env.Description = stringField(details, "description")
+
+	images, _ := details["container_images"].([]any)
+	for _, item := range images {
+		imageMap, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if name := stringField(imageMap, "name"); name != "" {
+			env.ContainerImages = append(env.ContainerImages, ContainerImage{Name: name, Registry: stringField(imageMap, "registry")})
+		}
+	}
+
+	for _, image := range env.ContainerImages {
+		if arch := scramArch.FindString(image.Name); arch != "" {
+			env.Architecture = arch
+			break
+		}
+	}
+
+	if env.Release == "" && env.GlobalTag == "" && len(env.ContainerImages) == 0 {
+		return nil, fmt.Errorf("record %d has no release, global tag or container image", env.Recid)
+	}
+	return env, nil
+}
+
+// SelectImage sets Image to the first container image from the given
+// registry. Without a registry, Docker Hub images are preferred since they
+// can be pulled without logging in.
+func (e *Environment) SelectImage(registry string) error {
+	if len(e.ContainerImages) == 0 {
+		return nil
+	}
+	candidates := append([]ContainerImage{}, e.ContainerImages...)
+	if registry == "" {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Registry == "dockerhub" && candidates[j].Registry != "dockerhub"
+		})
+		e.Image = candidates[0].Name
+		return nil
+	}
+	for _, image := range candidates {
+		if strings.EqualFold(image.Registry, registry) {
+			e.Image = image.Name
+			return nil
+		}
+	}
+	return fmt.Errorf("record %d has no container image from registry %s", e.Recid, registry)
+}
+
+// IsCMSSW reports whether the release is a CMSSW release.
+func (e *Environment) IsCMSSW() bool {
+	return strings.HasPrefix(e.Release, "CMSSW_")
+}
+
+// Format renders the environment as text, a Dockerfile, an Apptainer
+// definition or a shell setup script.
+func (e *Environment) Format(format string) (string, error) {
+	switch format {
+	case "text":
+		return e.Text(), nil
+	case FormatDockerfile:
+		return e.Dockerfile()
+	case FormatApptainer:
+		return e.Apptainer()
+	case FormatShell:
+		return e.Shell(), nil
+	default:
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func (e *Environment) header() string {
+	title := strings.Join(strings.Fields(e.Title), " ")
+	return fmt.Sprintf("Environment for CERN Open Data record %d: %s", e.Recid, title)
+}
+
+// recordURL returns the portal page of the record.
+func (e *Environment) recordURL() string {
+	return fmt.Sprintf("%s/record/%d", config.ServerHTTPSURI, e.Recid)
+}
+
+// safeShellWord matches words that need no quoting in a shell.
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for a POSIX shell so that it is taken literally.
+func shellQuote(s string) string {
+	if safeShellWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dockerfileQuote double-quotes s for an ENV or LABEL instruction of a
+// Dockerfile, which only knows the escapes \" and \\.
+func dockerfileQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// variables returns the environment variables describing the release.
+func (e *Environment) variables() [][2]string {
+	var vars [][2]string
+	if e.Release != "" {
+		vars = append(vars, [2]string{"OPENDATA_RELEASE", e.Release})
+	}
+	if e.GlobalTag != "" {
+		vars = append(vars, [2]string{"OPENDATA_GLOBAL_TAG", e.GlobalTag})
+	}
+	if e.Architecture != "" {
+		vars = append(vars, [2]string{"SCRAM_ARCH", e.Architecture})
+	}
+	return vars
+}
+
+// Text returns a human-readable summary.
+func (e *Environment) Text() string {
+	var b strings.Builder
+	b.WriteString(e.header() + "\n")
+	field := func(name, value string) {
+		if value != "" {
+			b.WriteString(fmt.Sprintf("\n%-14s %s", name+":", value))
+		}
+	}
+	field("Experiment", e.Experiment)
+	field("Release", e.Release)
+	field("Global tag", e.GlobalTag)
+	field("Architecture", e.Architecture)
+	field("Image", e.Image)
+
+	if len(e.ContainerImages) > 1 {
+		b.WriteString("\n\nContainer images:")
+		for _, image := range e.ContainerImages {
+			if image.Registry != "" {
+				b.WriteString(fmt.Sprintf("\n  %s (%s)", image.Name, image.Registry))
+			} else {
+				b.WriteString("\n  " + image.Name)
+			}
+		}
+	}
+
+	if e.Description != "" {
+		b.WriteString("\n\n" + e.Description)
+	}
+
+	if e.Image != "" {
+		b.WriteString("\n\nSetup:\n")
+		b.WriteString(fmt.Sprintf("  docker run -it --rm -v \"$PWD\":/code/workdir %s /bin/bash", e.Image))
+	} else if e.IsCMSSW() {
+		b.WriteString("\n\nSetup (with CVMFS):\n")
+		b.WriteString("  " + strings.Join(e.cmsswSetup(), "\n  "))
+	}
+
+	for _, section := range []struct {
+		name  string
+		links []Link
+	}{{"Usage", e.Usage}, {"Methodology", e.Methodology}} {
+		if len(section.links) == 0 {
+			continue
+		}
+		b.WriteString("\n\n" + section.name + ":")
+		for _, link := range section.links {
+			if link.Description != "" {
+				b.WriteString(fmt.Sprintf("\n  %s: %s", link.Description, link.URL))
+			} else {
+				b.WriteString("\n  " + link.URL)
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// cmsswSetup returns the commands creating a CMSSW work area from CVMFS.
+func (e *Environment) cmsswSetup() []string {
+	lines := []string{"source /cvmfs/cms.cern.ch/cmsset_default.sh"}
+	if e.Architecture != "" {
+		lines = append(lines, "export SCRAM_ARCH="+shellQuote(e.Architecture))
+	}
+	release := shellQuote(e.Release)
+	return append(lines,
+		fmt.Sprintf("[ -d %s ] || scram project CMSSW %s", release, release),
+		fmt.Sprintf("cd %s/src", release),
+		"eval \"$(scramv1 runtime -sh)\"",
+	)
+}
+
+// Dockerfile returns a Dockerfile based on the selected container image.
+func (e *Environment) Dockerfile() (string, error) {
+	if e.Image == "" {
+		return "", fmt.Errorf("record %d has no container image", e.Recid)
+	}
+	var b strings.Builder
+	b.WriteString("# " + e.header() + "\n")
+	b.WriteString("FROM " + e.Image + "\n")
+	for _, v := range e.variables() {
+		b.WriteString(fmt.Sprintf("ENV %s=%s\n", v[0], dockerfileQuote(v[1])))
+	}
+	b.WriteString("LABEL org.opencontainers.image.source=" + dockerfileQuote(e.recordURL()))
+	return b.String(), nil
+}
+
+// Apptainer returns an Apptainer (Singularity) definition file based on the
+// selected container image.
+func (e *Environment) Apptainer() (string, error) {
+	if e.Image == "" {
+		return "", fmt.Errorf("record %d has no container image", e.Recid)
+	}
+	var b strings.Builder
+	b.WriteString("Bootstrap: docker\n")
+	b.WriteString("From: " + e.Image + "\n")
+	if vars := e.variables(); len(vars) > 0 {
+		b.WriteString("\n%environment\n")
+		for _, v := range vars {
+			b.WriteString(fmt.Sprintf("    export %s=%s\n", v[0], shellQuote(v[1])))
+		}
+	}
+	b.WriteString("\n%labels\n")
+	b.WriteString(fmt.Sprintf("    recid %d\n", e.Recid))
+	b.WriteString("    source " + e.recordURL() + "\n")
+	b.WriteString("\n%help\n")
+	b.WriteString("    " + e.header())
+	return b.String(), nil
+}
+
+// Shell returns a script that sets up the environment: a CMSSW work area
+// from CVMFS for CMSSW releases, and otherwise the variables and the
+// command starting the container image. The script changes the variables
+// and the directory of the shell, so it has to be sourced.
+func (e *Environment) Shell() string {
+	var b strings.Builder
+	b.WriteString("# " + e.header() + "\n")
+	b.WriteString("# Source this script in bash instead of running it: . ./setup.sh\n\n")
+	for _, v := range e.variables() {
+		b.WriteString(fmt.Sprintf("export %s=%s\n", v[0], shellQuote(v[1])))
+	}
+	if e.Image != "" {
+		b.WriteString("\n# To work in the container instead, run:\n")
+		b.WriteString(fmt.Sprintf("#   docker run -it --rm -v \"$PWD\":/code/workdir %s /bin/bash\n", e.Image))
+	}
+	if e.IsCMSSW() {
+		b.WriteString("\n")
+		for _, line := range e.cmsswSetup() {
+			b.WriteString(line + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
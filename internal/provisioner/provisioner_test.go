@@ -0,0 +1,169 @@
+package provisioner
+
+import (
+	"strings"
+	"testing"
+)
+
+func cmsMetadata() map[string]any {
+	return map[string]any{
+		"recid":      float64(5500),
+		"title":      "Higgs-to-four-lepton analysis example",
+		"experiment": []any{"CMS"},
+		"system_details": map[string]any{
+			"release":    "CMSSW_5_3_32",
+			"global_tag": "FT53_V21A_AN6::All",
+			"container_images": []any{
+				map[string]any{"name": "gitlab-registry.cern.ch/cms-cloud/cmssw-docker/cmssw_5_3_32-slc6_amd64_gcc472", "registry": "gitlab"},
+				map[string]any{"name": "cmsopendata/cmssw_5_3_32", "registry": "dockerhub"},
+			},
+		},
+		"usage": map[string]any{
+			"links": []any{
+				map[string]any{"description": "Getting started", "url": "/docs/cms-getting-started-2011"},
+			},
+		},
+	}
+}
+
+func TestFromMetadata(t *testing.T) {
+	env, err := FromMetadata(cmsMetadata())
+	if err != nil {
+		t.Fatalf("FromMetadata failed: %v", err)
+	}
+
+	if env.Recid != 5500 || env.Experiment != "CMS" || env.Release != "CMSSW_5_3_32" || env.GlobalTag != "FT53_V21A_AN6::All" {
+		t.Errorf("unexpected environment: %+v", env)
+	}
+	if env.Architecture != "slc6_amd64_gcc472" {
+		t.Errorf("Architecture = %q, want slc6_amd64_gcc472", env.Architecture)
+	}
+	if len(env.ContainerImages) != 2 || len(env.Usage) != 1 {
+		t.Errorf("unexpected images or links: %+v", env)
+	}
+
+	if _, err := FromMetadata(map[string]any{"recid": float64(1)}); err == nil {
+		t.Error("expected error for record without system details")
+	}
+	if _, err := FromMetadata(map[string]any{"system_details": map[string]any{}}); err == nil {
+		t.Error("expected error for empty system details")
+	}
+}
+
+func TestSelectImage(t *testing.T) {
+	tests := []struct {
+		registry string
+		want     string
+		wantErr  bool
+	}{
+		{registry: "", want: "cmsopendata/cmssw_5_3_32"},
+		{registry: "gitlab", want: "gitlab-registry.cern.ch/cms-cloud/cmssw-docker/cmssw_5_3_32-slc6_amd64_gcc472"},
+		{registry: "quay", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		env, _ := FromMetadata(cmsMetadata())
+		err := env.SelectImage(tt.registry)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SelectImage(%q) error = %v, wantErr %v", tt.registry, err, tt.wantErr)
+			continue
+		}
+		if env.Image != tt.want {
+			t.Errorf("SelectImage(%q) image = %q, want %q", tt.registry, env.Image, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	env, _ := FromMetadata(cmsMetadata())
+	_ = env.SelectImage("")
+
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{
+			format:   "text",
+			contains: []string{"Release:       CMSSW_5_3_32", "Global tag:    FT53_V21A_AN6::All", "docker run", "Getting started: /docs/cms-getting-started-2011"},
+		},
+		{
+			format:   FormatDockerfile,
+			contains: []string{"FROM cmsopendata/cmssw_5_3_32\n", `ENV OPENDATA_GLOBAL_TAG="FT53_V21A_AN6::All"`, "record/5500"},
+		},
+		{
+			format:   FormatApptainer,
+			contains: []string{"Bootstrap: docker\nFrom: cmsopendata/cmssw_5_3_32\n", "%environment", "export SCRAM_ARCH=slc6_amd64_gcc472", "%labels", "source https://opendata.cern.ch/record/5500"},
+		},
+		{
+			format:   FormatShell,
+			contains: []string{"# Source this script", "source /cvmfs/cms.cern.ch/cmsset_default.sh", "scram project CMSSW CMSSW_5_3_32", "cd CMSSW_5_3_32/src"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := env.Format(tt.format)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("output missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := env.Format("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+
+	noImage := &Environment{Recid: 1, Release: "CMSSW_7_6_7"}
+	if _, err := noImage.Dockerfile(); err == nil {
+		t.Error("expected error for Dockerfile without image")
+	}
+	if got := noImage.Text(); !strings.Contains(got, "scram project CMSSW CMSSW_7_6_7") {
+		t.Errorf("text without image should show CVMFS setup:\n%s", got)
+	}
+}
+
+func TestShellQuoting(t *testing.T) {
+	env := &Environment{
+		Recid:     1,
+		Title:     "Title\nrm -rf ~",
+		Release:   "CMSSW_$(id)",
+		GlobalTag: "it's `x`",
+	}
+	got := env.Shell()
+	for _, want := range []string{
+		"# Environment for CERN Open Data record 1: Title rm -rf ~\n",
+		"export OPENDATA_RELEASE='CMSSW_$(id)'\n",
+		`export OPENDATA_GLOBAL_TAG='it'\''s ` + "`x`'",
+		"cd 'CMSSW_$(id)'/src",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("script missing %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"#!/bin/bash", "set -e"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("sourced script contains %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestDockerfileQuoting(t *testing.T) {
+	env := &Environment{
+		Recid:     1,
+		Image:     "cmsopendata/cmssw_7_6_7",
+		Release:   "CMSSW_7_6_7",
+		GlobalTag: "a \"b\" \\c é\tx",
+	}
+	got, err := env.Dockerfile()
+	if err != nil {
+		t.Fatalf("Dockerfile failed: %v", err)
+	}
+	if want := "ENV OPENDATA_GLOBAL_TAG=\"a \\\"b\\\" \\\\c é\tx\"\n"; !strings.Contains(got, want) {
+		t.Errorf("Dockerfile missing %q:\n%s", want, got)
+	}
+}
@@ -0,0 +1,200 @@
+package querier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go-hep.org/x/hep/xrootd"
+	"go-hep.org/x/hep/xrootd/xrdio"
+	"go-hep.org/x/hep/xrootd/xrdproto/query"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+)
+
+// ChecksumFunc returns the checksum that the storage reports for a file,
+// as an <algorithm>:<hex digest> value.
+type ChecksumFunc func(ctx context.Context, uri string) (string, error)
+
+// Querier asks XRootD servers for the checksums they store, without
+// downloading the files. Connections are reused per server. It is safe
+// for concurrent use.
+type Querier struct {
+	mu       sync.Mutex
+	clients  map[string]*xrootd.Client
+	username string
+}
+
+// NewQuerier creates a Querier.
+func NewQuerier() *Querier {
+	return &Querier{
+		clients:  make(map[string]*xrootd.Client),
+		username: "gopher",
+	}
+}
+
+// Checksum queries the server of a root:// URI for the file's checksum.
+func (q *Querier) Checksum(ctx context.Context, uri string) (string, error) {
+	url, err := xrdio.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse XRootD URL: %w", err)
+	}
+
+	client, err := q.client(ctx, url.Addr)
+	if err != nil {
+		return "", err
+	}
+
+	var resp query.Response
+	req := query.Request{Query: query.Checksum, Args: []byte(url.Path)}
+	if _, err := client.Send(ctx, &resp, &req); err != nil {
+		return "", fmt.Errorf("checksum query failed: %w", err)
+	}
+	return ParseResponse(resp.Data)
+}
+
+// Close closes the connections to the servers.
+func (q *Querier) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var firstErr error
+	for addr, client := range q.clients {
+		if err := client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(q.clients, addr)
+	}
+	return firstErr
+}
+
+// client returns the connection to a server, opening it on first use.
+func (q *Querier) client(ctx context.Context, addr string) (*xrootd.Client, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if client, ok := q.clients[addr]; ok {
+		return client, nil
+	}
+	client, err := xrootd.NewClient(ctx, addr, q.username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create XRootD client: %w", err)
+	}
+	q.clients[addr] = client
+	return client, nil
+}
+
+// ParseResponse converts the response to a checksum query, such as
+// "adler32 0a1b2c3d", to an <algorithm>:<hex digest> value.
+func ParseResponse(data []byte) (string, error) {
+	text := string(bytes.TrimRight(data, "\x00\n "))
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return "", fmt.Errorf("unexpected checksum response %q", text)
+	}
+	algorithm, digest, err := checksum.Parse(fields[0] + ":" + fields[1])
+	if err != nil {
+		return "", fmt.Errorf("unexpected checksum response %q: %w", text, err)
+	}
+	return algorithm + ":" + digest, nil
+}
+
+// Result is the comparison of a file's metadata checksum with the
+// checksum reported by the storage.
+type Result struct {
+	URI      string `json:"uri"`
+	Expected string `json:"expected"`
+	Remote   string `json:"remote,omitempty"`
+	Match    bool   `json:"match"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Compare queries the checksums of the files, given as file entries with
+// uri and checksum keys, with up to jobs queries in flight, and compares
+// them with the metadata checksums. The results are in the order of files.
+func Compare(ctx context.Context, files []any, checksumOf ChecksumFunc, jobs int) []Result {
+	results := make([]Result, len(files))
+	for i, file := range files {
+		fileMap, _ := file.(map[string]any)
+		results[i].URI, _ = fileMap["uri"].(string)
+		results[i].Expected, _ = fileMap["checksum"].(string)
+	}
+
+	if jobs < 1 {
+		jobs = 1
+	}
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				compare(ctx, &results[i], checksumOf)
+			}
+		}()
+	}
+	for i := range results {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+// compare fills in the remote checksum of one result.
+func compare(ctx context.Context, result *Result, checksumOf ChecksumFunc) {
+	algorithm, digest, err := checksum.Parse(result.Expected)
+	if err != nil {
+		result.Error = fmt.Sprintf("metadata checksum: %v", err)
+		return
+	}
+	remote, err := checksumOf(ctx, result.URI)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.Remote = remote
+	remoteAlgorithm, _, _ := strings.Cut(remote, ":")
+	if remoteAlgorithm != algorithm {
+		result.Error = fmt.Sprintf("storage reports a %s checksum, metadata has %s", remoteAlgorithm, algorithm)
+		return
+	}
+	result.Match = remote == algorithm+":"+digest
+}
+
+// Count returns the number of matching, mismatching and failed results.
+func Count(results []Result) (matched, mismatched, failed int) {
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			failed++
+		case result.Match:
+			matched++
+		default:
+			mismatched++
+		}
+	}
+	return matched, mismatched, failed
+}
+
+// Text formats the results with one line per file followed by a summary.
+func Text(results []Result) string {
+	var b strings.Builder
+	for _, result := range results {
+		name := filepath.Base(result.URI)
+		switch {
+		case result.Error != "":
+			fmt.Fprintf(&b, "ERROR     %s: %s\n", name, result.Error)
+		case result.Match:
+			fmt.Fprintf(&b, "OK        %s %s\n", name, result.Remote)
+		default:
+			fmt.Fprintf(&b, "MISMATCH  %s metadata %s, storage %s\n", name, result.Expected, result.Remote)
+		}
+	}
+	matched, mismatched, failed := Count(results)
+	fmt.Fprintf(&b, "%d matching, %d mismatching, %d failed", matched, mismatched, failed)
+	return b.String()
+}
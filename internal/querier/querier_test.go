@@ -0,0 +1,92 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{data: "adler32 0a1b2c3d\x00", want: "adler32:0a1b2c3d"},
+		{data: "ADLER32 0A1B2C3D\n", want: "adler32:0a1b2c3d"},
+		{data: "md5 098f6bcd4621d373cade4e832627b4f6", want: "md5:098f6bcd4621d373cade4e832627b4f6"},
+		{data: "adler32", wantErr: true},
+		{data: "adler32 xyz", wantErr: true},
+		{data: "blake3 0a1b2c3d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseResponse([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseResponse(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseResponse(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	remote := map[string]string{
+		"root://eospublic.cern.ch//eos/a.root": "adler32:0a1b2c3d",
+		"root://eospublic.cern.ch//eos/b.root": "adler32:00000000",
+		"root://eospublic.cern.ch//eos/d.root": "adler32:0a1b2c3d",
+	}
+	checksumOf := func(ctx context.Context, uri string) (string, error) {
+		if sum, ok := remote[uri]; ok {
+			return sum, nil
+		}
+		return "", fmt.Errorf("no such file")
+	}
+
+	files := []any{
+		map[string]any{"uri": "root://eospublic.cern.ch//eos/a.root", "checksum": "adler32:0A1B2C3D"},
+		map[string]any{"uri": "root://eospublic.cern.ch//eos/b.root", "checksum": "adler32:0a1b2c3d"},
+		map[string]any{"uri": "root://eospublic.cern.ch//eos/c.root", "checksum": "adler32:0a1b2c3d"},
+		map[string]any{"uri": "root://eospublic.cern.ch//eos/d.root", "checksum": "md5:098f6bcd4621d373cade4e832627b4f6"},
+		map[string]any{"uri": "root://eospublic.cern.ch//eos/e.root"},
+	}
+
+	for _, jobs := range []int{1, 4} {
+		results := Compare(context.Background(), files, checksumOf, jobs)
+
+		var got []string
+		for _, result := range results {
+			got = append(got, fmt.Sprintf("%v %q", result.Match, result.Error))
+		}
+		want := []string{
+			`true ""`,
+			`false ""`,
+			`false "no such file"`,
+			`false "storage reports a adler32 checksum, metadata has md5"`,
+			`false "metadata checksum: no checksum"`,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("jobs=%d: results = %q, want %q", jobs, got, want)
+		}
+
+		if matched, mismatched, failed := Count(results); matched != 1 || mismatched != 1 || failed != 3 {
+			t.Errorf("Count() = %d, %d, %d, want 1, 1, 3", matched, mismatched, failed)
+		}
+
+		text := Text(results)
+		for _, line := range []string{
+			"OK        a.root adler32:0a1b2c3d",
+			"MISMATCH  b.root metadata adler32:0a1b2c3d, storage adler32:00000000",
+			"ERROR     c.root: no such file",
+			"1 matching, 1 mismatching, 3 failed",
+		} {
+			if !strings.Contains(text, line) {
+				t.Errorf("text missing %q:\n%s", line, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package relater
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+// Reference is a link from a record to another record found in its metadata.
+type Reference struct {
+	Recid int    `json:"recid"`
+	Field string `json:"field"`
+}
+
+// Node is a record in the related-record graph.
+type Node struct {
+	Recid    int      `json:"recid"`
+	Title    string   `json:"title,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Subtypes []string `json:"subtypes,omitempty"`
+	Depth    int      `json:"depth"`
+	Error    string   `json:"error,omitempty"`
+	// Metadata is the metadata the record was fetched with, so that it
+	// need not be fetched again.
+	Metadata map[string]any `json:"-"`
+}
+
+// Edge is a reference from one record to another. Field is the top-level
+// metadata field the reference was found in.
+type Edge struct {
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Field string `json:"field"`
+}
+
+// Graph is the set of records reachable from Root.
+type Graph struct {
+	Root  int    `json:"root"`
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Fetcher returns the metadata of a record.
+type Fetcher func(recid int) (map[string]any, error)
+
+// Options controls the traversal.
+type Options struct {
+	// Depth is the number of reference hops followed from the root.
+	Depth int
+	// Fields restricts the traversal to references found in these
+	// top-level metadata fields. All fields are followed if empty.
+	Fields []string
+	// Server is the URL of the server the records come from. Absolute
+	// links to its host are followed besides those to the portal.
+	Server string
+}
+
+// recordURL matches links to records, both relative (/record/14) and
+// absolute (https://opendata.cern.ch/record/14). The first group holds
+// what precedes the path: the scheme and host of an absolute link, or the
+// rest of a word, which is empty for a relative link.
+var recordURL = regexp.MustCompile(`(https?://[^/\s]+|[^\s"'=(<>\[]*)/records?/(\d+)\b`)
+
+// portalHosts are the hosts whose absolute record links are followed;
+// other sites such as Zenodo and INSPIRE use the same paths for their own
+// records.
+var portalHosts = map[string]bool{
+	hostOf(config.ServerHTTPURI):  true,
+	hostOf(config.ServerHTTPSURI): true,
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// isPortalLink reports whether the text before a record path makes it a
+// relative link or an absolute link to the portal or to server.
+func isPortalLink(prefix, server string) bool {
+	if prefix == "" {
+		return true
+	}
+	host := hostOf(prefix)
+	return host != "" && (portalHosts[host] || host == hostOf(server))
+}
+
+// skippedFields hold file locations rather than references to other records.
+var skippedFields = map[string]bool{
+	"files":          true,
+	"_files":         true,
+	"file_indices":   true,
+	"_file_indices":  true,
+	"recid":          true,
+	"control_number": true,
+}
+
+func recidOf(value any) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), v > 0
+	case int:
+		return v, v > 0
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil && n > 0
+	}
+	return 0, false
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// collect appends the record IDs referenced anywhere in value: "recid"
+// keys of nested objects (as in relations) and record links in strings
+// (as in usage and methodology links or descriptions).
+func collect(value any, server string, found []int) []int {
+	switch v := value.(type) {
+	case map[string]any:
+		if recid, ok := recidOf(v["recid"]); ok {
+			found = append(found, recid)
+		}
+		for _, k := range sortedKeys(v) {
+			if k != "recid" {
+				found = collect(v[k], server, found)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			found = collect(item, server, found)
+		}
+	case string:
+		for _, match := range recordURL.FindAllStringSubmatch(v, -1) {
+			if !isPortalLink(match[1], server) {
+				continue
+			}
+			if recid, ok := recidOf(match[2]); ok {
+				found = append(found, recid)
+			}
+		}
+	}
+	return found
+}
+
+// FindReferences returns the records referenced in the metadata of a
+// record, in field order and without duplicates. References of the record
+// to itself are left out. Absolute links are followed if they point to the
+// portal or to server, which may be empty.
+func FindReferences(metadata map[string]any, server string) []Reference {
+	self, _ := recidOf(metadata["recid"])
+	seen := make(map[Reference]bool)
+	var refs []Reference
+	for _, field := range sortedKeys(metadata) {
+		if skippedFields[field] {
+			continue
+		}
+		for _, recid := range collect(metadata[field], server, nil) {
+			ref := Reference{Recid: recid, Field: field}
+			if recid == self || seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+func newNode(recid, depth int, metadata map[string]any) Node {
+	node := Node{Recid: recid, Depth: depth, Metadata: metadata}
+	node.Title, _ = metadata["title"].(string)
+	if recordType, ok := metadata["type"].(map[string]any); ok {
+		node.Type, _ = recordType["primary"].(string)
+		secondary, _ := recordType["secondary"].([]any)
+		for _, s := range secondary {
+			if str, ok := s.(string); ok {
+				node.Subtypes = append(node.Subtypes, str)
+			}
+		}
+	}
+	return node
+}
+
+// HasType reports whether the record has the given primary or secondary
+// type, ignoring case.
+func (n Node) HasType(recordType string) bool {
+	if strings.EqualFold(n.Type, recordType) {
+		return true
+	}
+	for _, s := range n.Subtypes {
+		if strings.EqualFold(s, recordType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n Node) typeString() string {
+	if len(n.Subtypes) == 0 {
+		return n.Type
+	}
+	return n.Type + "/" + strings.Join(n.Subtypes, ", ")
+}
+
+// Traverse follows references breadth-first from root up to opts.Depth
+// hops. Records that cannot be fetched are kept as nodes with an error and
+// are not followed; only a failure to fetch the root is returned as error.
+func Traverse(root int, fetch Fetcher, opts Options) (*Graph, error) {
+	metadata, err := fetch(root)
+	if err != nil {
+		return nil, err
+	}
+
+	follow := make(map[string]bool)
+	for _, field := range opts.Fields {
+		follow[field] = true
+	}
+
+	graph := &Graph{Root: root, Nodes: []Node{newNode(root, 0, metadata)}}
+	visited := map[int]bool{root: true}
+
+	for i := 0; i < len(graph.Nodes); i++ {
+		node := graph.Nodes[i]
+		if node.Error != "" || node.Depth >= opts.Depth {
+			continue
+		}
+		for _, ref := range FindReferences(node.Metadata, opts.Server) {
+			if len(follow) > 0 && !follow[ref.Field] {
+				continue
+			}
+			graph.Edges = append(graph.Edges, Edge{From: node.Recid, To: ref.Recid, Field: ref.Field})
+			if visited[ref.Recid] {
+				continue
+			}
+			visited[ref.Recid] = true
+			related, err := fetch(ref.Recid)
+			if err != nil {
+				graph.Nodes = append(graph.Nodes, Node{Recid: ref.Recid, Depth: node.Depth + 1, Error: err.Error()})
+				continue
+			}
+			graph.Nodes = append(graph.Nodes, newNode(ref.Recid, node.Depth+1, related))
+		}
+	}
+	return graph, nil
+}
+
+// Related returns the nodes other than the root.
+func (g *Graph) Related() []Node {
+	var nodes []Node
+	for _, node := range g.Nodes {
+		if node.Recid != g.Root {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Format renders the graph as a text list or as Graphviz DOT.
+func (g *Graph) Format(format string) (string, error) {
+	switch format {
+	case "text":
+		return g.Text(), nil
+	case "dot":
+		return g.DOT(), nil
+	default:
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// Text lists the records in traversal order, indented by depth, each with
+// the references leading to it.
+func (g *Graph) Text() string {
+	incoming := make(map[int][]string)
+	for _, edge := range g.Edges {
+		incoming[edge.To] = append(incoming[edge.To], fmt.Sprintf("%s of %d", edge.Field, edge.From))
+	}
+
+	var b strings.Builder
+	for _, node := range g.Nodes {
+		b.WriteString(strings.Repeat("  ", node.Depth))
+		b.WriteString(strconv.Itoa(node.Recid))
+		if node.Title != "" {
+			b.WriteString(" " + node.Title)
+		}
+		if t := node.typeString(); t != "" {
+			b.WriteString(" [" + t + "]")
+		}
+		if refs := incoming[node.Recid]; len(refs) > 0 {
+			b.WriteString(" (via " + strings.Join(refs, "; ") + ")")
+		}
+		if node.Error != "" {
+			b.WriteString(" error: " + node.Error)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// dotEscape escapes s for use inside a quoted DOT string.
+func dotEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// DOT renders the graph in the Graphviz DOT language.
+func (g *Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph related {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range g.Nodes {
+		label := strconv.Itoa(node.Recid)
+		if node.Title != "" {
+			label += `\n` + dotEscape(node.Title)
+		}
+		if t := node.typeString(); t != "" {
+			label += `\n` + dotEscape(t)
+		}
+		attrs := fmt.Sprintf(`label="%s"`, label)
+		if node.Recid == g.Root {
+			attrs += ", style=bold"
+		}
+		if node.Error != "" {
+			attrs += ", style=dashed"
+		}
+		b.WriteString(fmt.Sprintf("  %d [%s];\n", node.Recid, attrs))
+	}
+	for _, edge := range g.Edges {
+		b.WriteString(fmt.Sprintf("  %d -> %d [label=\"%s\"];\n", edge.From, edge.To, dotEscape(edge.Field)))
+	}
+	b.WriteString("}")
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package relater
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRecords() map[int]map[string]any {
+	return map[int]map[string]any{
+		6004: {
+			"recid": "6004",
+			"title": "/DoubleMuParked/Run2012B-22Jan2013-v1/AOD",
+			"type":  map[string]any{"primary": "Dataset", "secondary": []any{"Collision"}},
+			"usage": map[string]any{
+				"links": []any{
+					map[string]any{"description": "Validated runs", "url": "/record/1002"},
+					map[string]any{"description": "Self", "url": "https://opendata.cern.ch/record/6004"},
+				},
+			},
+			"relations": []any{
+				map[string]any{"recid": "14", "type": "isChildOf"},
+			},
+			"files": []any{
+				map[string]any{"uri": "https://opendata.cern.ch/record/999/files/x.root"},
+			},
+		},
+		1002: {
+			"recid":       float64(1002),
+			"title":       "CMS list of validated runs",
+			"type":        map[string]any{"primary": "Environment", "secondary": []any{"Validation"}},
+			"methodology": map[string]any{"description": `See <a href="/record/6004">the dataset</a> and <a href="/record/404">a removed record</a>.`},
+		},
+		14: {
+			"recid": float64(14),
+			"title": "Configuration file for RECO step",
+			"type":  map[string]any{"primary": "Environment", "secondary": []any{"Configuration"}},
+		},
+	}
+}
+
+func testFetcher(records map[int]map[string]any) Fetcher {
+	return func(recid int) (map[string]any, error) {
+		if metadata, ok := records[recid]; ok {
+			return metadata, nil
+		}
+		return nil, fmt.Errorf("record %d not found", recid)
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	got := FindReferences(testRecords()[6004], "")
+	want := []Reference{
+		{Recid: 14, Field: "relations"},
+		{Recid: 1002, Field: "usage"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindReferences() = %v, want %v", got, want)
+	}
+
+	if got := FindReferences(testRecords()[14], ""); len(got) != 0 {
+		t.Errorf("FindReferences(no links) = %v, want none", got)
+	}
+
+	links := map[string]any{
+		"recid": float64(1),
+		"publications": []any{
+			"https://zenodo.org/record/4567",
+			"https://inspirehep.net/record/1234",
+			"See zenodo.org/records/89 and http://opendata.cern.ch/record/12.",
+			`<a href="https://opendata.cern.ch/records/13">x</a> (/record/15)`,
+		},
+	}
+	want = []Reference{
+		{Recid: 12, Field: "publications"},
+		{Recid: 13, Field: "publications"},
+		{Recid: 15, Field: "publications"},
+	}
+	if got := FindReferences(links, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindReferences(other sites) = %v, want %v", got, want)
+	}
+
+	// Links to a custom server are followed when it is the active one.
+	mirror := map[string]any{
+		"recid":        float64(1),
+		"publications": []any{"http://localhost:5000/record/16", "https://zenodo.org/record/4567"},
+	}
+	if got := FindReferences(mirror, ""); len(got) != 0 {
+		t.Errorf("FindReferences(mirror) = %v, want none", got)
+	}
+	want = []Reference{{Recid: 16, Field: "publications"}}
+	if got := FindReferences(mirror, "http://localhost:5000"); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindReferences(mirror, server) = %v, want %v", got, want)
+	}
+}
+
+func TestTraverse(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		wantNodes []int
+		wantEdges int
+	}{
+		{name: "depth 0", opts: Options{Depth: 0}, wantNodes: []int{6004}, wantEdges: 0},
+		{name: "depth 1", opts: Options{Depth: 1}, wantNodes: []int{6004, 14, 1002}, wantEdges: 2},
+		{name: "depth 2", opts: Options{Depth: 2}, wantNodes: []int{6004, 14, 1002, 404}, wantEdges: 4},
+		{name: "fields", opts: Options{Depth: 2, Fields: []string{"usage"}}, wantNodes: []int{6004, 1002}, wantEdges: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := Traverse(6004, testFetcher(testRecords()), tt.opts)
+			if err != nil {
+				t.Fatalf("Traverse failed: %v", err)
+			}
+			var recids []int
+			for _, node := range graph.Nodes {
+				recids = append(recids, node.Recid)
+			}
+			if !reflect.DeepEqual(recids, tt.wantNodes) {
+				t.Errorf("nodes = %v, want %v", recids, tt.wantNodes)
+			}
+			if len(graph.Edges) != tt.wantEdges {
+				t.Errorf("got %d edges, want %d: %v", len(graph.Edges), tt.wantEdges, graph.Edges)
+			}
+		})
+	}
+
+	graph, _ := Traverse(6004, testFetcher(testRecords()), Options{Depth: 2})
+	missing := graph.Nodes[3]
+	if missing.Error == "" || missing.Depth != 2 {
+		t.Errorf("unfetchable record should be kept with an error: %+v", missing)
+	}
+	if related := graph.Related(); len(related) != 3 || !related[0].HasType("configuration") {
+		t.Errorf("Related() = %+v", related)
+	}
+	if title, _ := graph.Nodes[1].Metadata["title"].(string); title != "Configuration file for RECO step" {
+		t.Errorf("node metadata not kept: %+v", graph.Nodes[1])
+	}
+
+	if _, err := Traverse(1, testFetcher(testRecords()), Options{Depth: 1}); err == nil {
+		t.Error("expected error for unfetchable root")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	graph, _ := Traverse(6004, testFetcher(testRecords()), Options{Depth: 1})
+
+	tests := []struct {
+		format   string
+		contains []string
+	}{
+		{
+			format: "text",
+			contains: []string{
+				"6004 /DoubleMuParked/Run2012B-22Jan2013-v1/AOD [Dataset/Collision]\n",
+				"  14 Configuration file for RECO step [Environment/Configuration] (via relations of 6004)",
+				"  1002 CMS list of validated runs [Environment/Validation] (via usage of 6004)",
+			},
+		},
+		{
+			format: "dot",
+			contains: []string{
+				"digraph related {",
+				`6004 [label="6004\n/DoubleMuParked/Run2012B-22Jan2013-v1/AOD\nDataset/Collision", style=bold];`,
+				`6004 -> 1002 [label="usage"];`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := graph.Format(tt.format)
+			if err != nil {
+				t.Fatalf("Format failed: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("output missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+
+	if _, err := graph.Format("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if got := dotEscape(`say "hi"`); got != `say \"hi\"` {
+		t.Errorf("dotEscape() = %q", got)
+	}
+}
@@ -0,0 +1,247 @@
+package repairer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/verifier"
+)
+
+// Action is how a file that failed verification is repaired.
+type Action string
+
+const (
+	// Download downloads a missing file.
+	Download Action = "download"
+	// Resume keeps a file that is shorter than expected and resumes its
+	// download from the end.
+	Resume Action = "resume"
+	// Truncate removes trailing bytes from a file whose leading bytes
+	// have the expected checksum; nothing is downloaded.
+	Truncate Action = "truncate"
+	// Replace moves a corrupt file aside and downloads it again.
+	Replace Action = "replace"
+)
+
+// DownloadFunc downloads files, given as file entries with uri, size and
+// checksum keys, into a directory. Files already present are resumed.
+type DownloadFunc func(files []any, directory string) error
+
+// Repair is the outcome of repairing one file.
+type Repair struct {
+	Path     string `json:"path"`
+	Action   Action `json:"action"`
+	Aside    string `json:"aside,omitempty"`
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Repairer repairs files that failed verification by downloading them
+// again with Download and verifying them with Verifier.
+type Repairer struct {
+	Download DownloadFunc
+	Verifier *verifier.Verifier
+}
+
+// New creates a Repairer.
+func New(download DownloadFunc, v *verifier.Verifier) *Repairer {
+	return &Repairer{Download: download, Verifier: v}
+}
+
+// Repair repairs the failures reported by verifying files in directory.
+// Files that are shorter than expected are resumed first; if they still
+// fail verification they are moved aside and downloaded again.
+func (r *Repairer) Repair(directory string, files []any, failures []verifier.VerificationResult) []Repair {
+	entries := make(map[string]any)
+	for _, file := range files {
+		if fileMap, ok := file.(map[string]any); ok {
+			uri, _ := fileMap["uri"].(string)
+			entries[filepath.Join(directory, filepath.Base(uri))] = file
+		}
+	}
+
+	repairs := make([]Repair, len(failures))
+	var ready []int
+	for i, failure := range failures {
+		repairs[i] = Prepare(failure)
+		if repairs[i].Error == "" {
+			ready = append(ready, i)
+		}
+	}
+	r.fetch(directory, entries, repairs, ready)
+
+	// A resumed file that still fails had bad bytes before the point it
+	// was resumed from; download it again from scratch.
+	var retry []int
+	for i := range repairs {
+		if repairs[i].Action != Resume || repairs[i].Repaired {
+			continue
+		}
+		repairs[i].Action = Replace
+		repairs[i].Error = ""
+		aside, err := MoveAside(repairs[i].Path)
+		if err != nil {
+			repairs[i].Error = err.Error()
+			continue
+		}
+		repairs[i].Aside = aside
+		retry = append(retry, i)
+	}
+	r.fetch(directory, entries, repairs, retry)
+
+	return repairs
+}
+
+// fetch downloads the files of the given repairs, except truncated ones,
+// and verifies all of them, recording the outcome.
+func (r *Repairer) fetch(directory string, entries map[string]any, repairs []Repair, indices []int) {
+	var downloads, checks []any
+	var checked []int
+	for _, i := range indices {
+		entry, ok := entries[repairs[i].Path]
+		if !ok {
+			repairs[i].Error = "file is not in the file list"
+			continue
+		}
+		checked = append(checked, i)
+		checks = append(checks, entry)
+		if repairs[i].Action != Truncate {
+			downloads = append(downloads, entry)
+		}
+	}
+	if len(checks) == 0 {
+		return
+	}
+
+	if len(downloads) > 0 {
+		if err := r.Download(downloads, directory); err != nil {
+			printer.DisplayMessage(printer.Warning, err.Error())
+		}
+	}
+
+	printer.DisplayMessage(printer.Info, "Verifying repaired files...")
+	stats, err := r.Verifier.VerifyFiles(directory, checks)
+	if err != nil {
+		for _, i := range checked {
+			repairs[i].Error = err.Error()
+		}
+		return
+	}
+	failed := make(map[string]bool)
+	for _, failure := range stats.Failures {
+		failed[failure.Path] = true
+	}
+	for _, i := range checked {
+		repairs[i].Repaired = !failed[repairs[i].Path]
+		if !repairs[i].Repaired {
+			repairs[i].Error = "file still fails verification"
+		}
+	}
+}
+
+// Prepare decides how to repair a file and readies it for downloading:
+// missing files are left missing, shorter files are kept for resuming,
+// longer files whose leading bytes have the expected checksum are
+// truncated, and other files are moved aside.
+func Prepare(result verifier.VerificationResult) Repair {
+	repair := Repair{Path: result.Path}
+	switch {
+	case !result.FileExists:
+		repair.Action = Download
+		return repair
+	case result.ActualSize < result.ExpectedSize:
+		repair.Action = Resume
+		return repair
+	case result.ActualSize > result.ExpectedSize && result.ExpectedSum != "":
+		if ok, err := prefixMatches(result.Path, result.ExpectedSize, result.ExpectedSum); err == nil && ok {
+			repair.Action = Truncate
+			if err := os.Truncate(result.Path, result.ExpectedSize); err != nil {
+				repair.Error = err.Error()
+			}
+			return repair
+		}
+	}
+
+	repair.Action = Replace
+	aside, err := MoveAside(result.Path)
+	repair.Aside = aside
+	if err != nil {
+		repair.Error = err.Error()
+	}
+	return repair
+}
+
+// MoveAside renames a file to <name>.corrupt, or <name>.corrupt.N if
+// that exists, and returns the new path.
+func MoveAside(path string) (string, error) {
+	aside := path + ".corrupt"
+	for n := 1; ; n++ {
+		if _, err := os.Stat(aside); os.IsNotExist(err) {
+			break
+		}
+		aside = fmt.Sprintf("%s.corrupt.%d", path, n)
+	}
+	if err := os.Rename(path, aside); err != nil {
+		return "", fmt.Errorf("failed to move %s aside: %w", path, err)
+	}
+	return aside, nil
+}
+
+// prefixMatches reports whether the first size bytes of the file have the
+// expected checksum, in any registered algorithm.
+func prefixMatches(path string, size int64, expected string) (bool, error) {
+	algorithm, digest, err := checksum.Parse(expected)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = file.Close() }()
+
+	sums, err := checksum.Calculate(io.LimitReader(file, size), algorithm)
+	if err != nil {
+		return false, err
+	}
+	return sums[algorithm] == algorithm+":"+digest, nil
+}
+
+// Count returns the number of repaired and still failing files.
+func Count(repairs []Repair) (repaired, failed int) {
+	for _, repair := range repairs {
+		if repair.Repaired {
+			repaired++
+		} else {
+			failed++
+		}
+	}
+	return repaired, failed
+}
+
+// Text formats the repairs as a report with one line per file.
+func Text(repairs []Repair) string {
+	var b strings.Builder
+	for _, repair := range repairs {
+		status := "repaired"
+		if !repair.Repaired {
+			status = "FAILED"
+		}
+		fmt.Fprintf(&b, "%-8s %-8s %s", status, repair.Action, filepath.Base(repair.Path))
+		if repair.Aside != "" {
+			fmt.Fprintf(&b, " (corrupt file kept as %s)", filepath.Base(repair.Aside))
+		}
+		if repair.Error != "" {
+			fmt.Fprintf(&b, ": %s", repair.Error)
+		}
+		b.WriteString("\n")
+	}
+	repaired, failed := Count(repairs)
+	fmt.Fprintf(&b, "%d repaired, %d failed", repaired, failed)
+	return b.String()
+}
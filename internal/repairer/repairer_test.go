@@ -0,0 +1,203 @@
+package repairer
+
+import (
+	"fmt"
+	"hash/adler32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/verifier"
+)
+
+func TestRepair(t *testing.T) {
+	dir := t.TempDir()
+
+	content := map[string]string{
+		"missing.root":   "missing file content",
+		"short.root":     "short file content",
+		"badshort.root":  "bad short file content",
+		"long.root":      "long file content",
+		"corrupt.root":   "corrupt file content",
+		"unchanged.root": "unchanged file content",
+	}
+	local := map[string]string{
+		"short.root":     "short file",
+		"badshort.root":  "BAD short",
+		"long.root":      "long file content plus garbage",
+		"corrupt.root":   "CORRUPT file content",
+		"unchanged.root": "unchanged file content",
+	}
+	for name, data := range local {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var files []any
+	for _, name := range []string{"missing.root", "short.root", "badshort.root", "long.root", "corrupt.root", "unchanged.root"} {
+		files = append(files, map[string]any{
+			"uri":      "root://eospublic.cern.ch//eos/opendata/" + name,
+			"size":     float64(len(content[name])),
+			"checksum": fmt.Sprintf("adler32:%08x", adler32.Checksum([]byte(content[name]))),
+		})
+	}
+
+	// The fake download resumes existing files like the real downloaders.
+	var downloaded [][]string
+	download := func(files []any, directory string) error {
+		var names []string
+		for _, file := range files {
+			name := filepath.Base(file.(map[string]any)["uri"].(string))
+			names = append(names, name)
+			path := filepath.Join(directory, name)
+			existing, _ := os.ReadFile(path) // #nosec G304 -- test file path
+			data := string(existing) + content[name][len(existing):]
+			if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+				return err
+			}
+		}
+		downloaded = append(downloaded, names)
+		return nil
+	}
+
+	v := verifier.NewVerifier()
+	v.Cache = nil
+	stats, err := v.VerifyFiles(dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Failures) != 5 {
+		t.Fatalf("expected 5 failures, got %d", len(stats.Failures))
+	}
+
+	repairs := New(download, v).Repair(dir, files, stats.Failures)
+
+	want := []struct {
+		name   string
+		action Action
+		aside  bool
+	}{
+		{"missing.root", Download, false},
+		{"short.root", Resume, false},
+		{"badshort.root", Replace, true},
+		{"long.root", Truncate, false},
+		{"corrupt.root", Replace, true},
+	}
+	for i, w := range want {
+		repair := repairs[i]
+		if filepath.Base(repair.Path) != w.name || repair.Action != w.action || !repair.Repaired || (repair.Aside != "") != w.aside {
+			t.Errorf("repair %d = %+v, want %s repaired by %s", i, repair, w.name, w.action)
+		}
+	}
+
+	wantDownloads := [][]string{
+		{"missing.root", "short.root", "badshort.root", "corrupt.root"},
+		{"badshort.root"},
+	}
+	if fmt.Sprint(downloaded) != fmt.Sprint(wantDownloads) {
+		t.Errorf("downloads = %v, want %v", downloaded, wantDownloads)
+	}
+
+	for name, data := range content {
+		got, err := os.ReadFile(filepath.Join(dir, name)) // #nosec G304 -- test file path
+		if err != nil || string(got) != data {
+			t.Errorf("%s = %q, %v, want %q", name, got, err, data)
+		}
+	}
+	aside, err := os.ReadFile(filepath.Join(dir, "corrupt.root.corrupt")) // #nosec G304 -- test file path
+	if err != nil || string(aside) != local["corrupt.root"] {
+		t.Errorf("corrupt file not kept aside: %q, %v", aside, err)
+	}
+
+	report := Text(repairs)
+	for _, line := range []string{
+		"repaired truncate long.root",
+		"repaired replace  corrupt.root (corrupt file kept as corrupt.root.corrupt)",
+		"5 repaired, 0 failed",
+	} {
+		if !strings.Contains(report, line) {
+			t.Errorf("report missing %q:\n%s", line, report)
+		}
+	}
+}
+
+func TestRepairDownloadFails(t *testing.T) {
+	dir := t.TempDir()
+	files := []any{
+		map[string]any{"uri": "http://example.com/a.root", "size": float64(4), "checksum": "adler32:045d01c1"},
+	}
+	download := func(files []any, directory string) error {
+		return fmt.Errorf("server unreachable")
+	}
+
+	v := verifier.NewVerifier()
+	v.Cache = nil
+	stats, err := v.VerifyFiles(dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repairs := New(download, v).Repair(dir, files, stats.Failures)
+	if repaired, failed := Count(repairs); repaired != 0 || failed != 1 {
+		t.Errorf("Count() = %d, %d, want 0, 1", repaired, failed)
+	}
+	if repairs[0].Error == "" {
+		t.Error("expected an error for the unrepaired file")
+	}
+}
+
+func TestMoveAside(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.root")
+	for i, want := range []string{"a.root.corrupt", "a.root.corrupt.1"} {
+		if err := os.WriteFile(path, []byte{byte(i)}, 0600); err != nil {
+			t.Fatal(err)
+		}
+		aside, err := MoveAside(path)
+		if err != nil {
+			t.Fatalf("MoveAside() error = %v", err)
+		}
+		if filepath.Base(aside) != want {
+			t.Errorf("MoveAside() = %s, want %s", filepath.Base(aside), want)
+		}
+	}
+	if _, err := MoveAside(path); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestPrefixMatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "long.root")
+	if err := os.WriteFile(path, []byte("file content plus garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len("file content"))
+
+	tests := []struct {
+		expected string
+		want     bool
+	}{
+		{expected: "adler32:1de004bc", want: true},
+		{expected: "ADLER32:1DE004BC", want: true},
+		{expected: "md5:d10b4c3ff123b26dc068d43a8bef2d23", want: true},
+		{expected: "sha256:e0ac3601005dfa1864f5392aabaf7d898b1b5bab854f1acb4491bcd806b76b0c", want: true},
+		{expected: "crc32c:00000000", want: false},
+		{expected: "md5:00000000000000000000000000000000", want: false},
+	}
+	for _, tt := range tests {
+		got, err := prefixMatches(path, size, tt.expected)
+		if err != nil {
+			t.Errorf("prefixMatches(%q) error = %v", tt.expected, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("prefixMatches(%q) = %v, want %v", tt.expected, got, tt.want)
+		}
+	}
+
+	if _, err := prefixMatches(path, size, "nothing"); err == nil {
+		t.Error("prefixMatches() with a malformed checksum should fail")
+	}
+}
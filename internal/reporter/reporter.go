@@ -0,0 +1,270 @@
+package reporter
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/verifier"
+)
+
+// Exit codes of a verification, one bit per failure category. The codes of
+// all categories that occur are added up, so 10 means that files are
+// missing and checksums mismatch.
+const (
+	// ExitMissing is set if files are missing or cannot be read.
+	ExitMissing = 2
+	// ExitSizeMismatch is set if files have the wrong size.
+	ExitSizeMismatch = 4
+	// ExitChecksumMismatch is set if files have the wrong checksum.
+	ExitChecksumMismatch = 8
+	// ExitUnverifiable is set if expected checksums are missing or use an
+	// unknown algorithm.
+	ExitUnverifiable = 16
+)
+
+// Formats lists the report formats.
+var Formats = []string{"json", "junit", "tap"}
+
+// Report is the outcome of verifying the files of a record.
+type Report struct {
+	// Recid is the record the files belong to, or 0.
+	Recid int
+	// Directory is where the files were verified.
+	Directory string
+	// TapeSkipped is the number of files skipped because they are on
+	// tape.
+	TapeSkipped int
+	Stats       *verifier.VerificationStats
+}
+
+// ExitCode returns the exit code for the results: 0 if all files were
+// verified, otherwise the sum of the codes of the failure categories.
+func ExitCode(results []verifier.VerificationResult) int {
+	code := 0
+	for _, result := range results {
+		switch result.Status {
+		case verifier.StatusMissing, verifier.StatusUnreadable:
+			code |= ExitMissing
+		case verifier.StatusSizeMismatch:
+			code |= ExitSizeMismatch
+			if result.ChecksumError != "" {
+				code |= ExitUnverifiable
+			} else if !result.ChecksumMatch {
+				code |= ExitChecksumMismatch
+			}
+		case verifier.StatusChecksumMismatch:
+			code |= ExitChecksumMismatch
+		case verifier.StatusUnverifiable:
+			code |= ExitUnverifiable
+		}
+	}
+	return code
+}
+
+// Write writes the report in the format "json", "junit" or "tap".
+func Write(w io.Writer, format string, report Report) error {
+	var data []byte
+	var err error
+	switch format {
+	case "json":
+		data, err = JSON(report)
+	case "junit":
+		data, err = JUnit(report)
+	case "tap":
+		data = []byte(TAP(report))
+	default:
+		return fmt.Errorf("unknown report format %q (choose from '%s')", format, strings.Join(Formats, "', '"))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// jsonReport is the JSON form of a Report.
+type jsonReport struct {
+	Recid      int                           `json:"recid,omitempty"`
+	Directory  string                        `json:"directory"`
+	Summary    jsonSummary                   `json:"summary"`
+	Files      []verifier.VerificationResult `json:"files"`
+	ExtraFiles []verifier.ExtraFile          `json:"extra_files"`
+	ExitCode   int                           `json:"exit_code"`
+}
+
+type jsonSummary struct {
+	Total          int `json:"total"`
+	Verified       int `json:"verified"`
+	SizeErrors     int `json:"size_errors"`
+	ChecksumErrors int `json:"checksum_errors"`
+	Missing        int `json:"missing"`
+	Unverifiable   int `json:"unverifiable"`
+	Cached         int `json:"cached"`
+	SkippedTape    int `json:"skipped_tape"`
+	Orphans        int `json:"orphans"`
+	Duplicates     int `json:"duplicates"`
+	ZeroByte       int `json:"zero_byte"`
+	Partial        int `json:"partial"`
+	Pruned         int `json:"pruned"`
+}
+
+// JSON formats the report as a JSON document with a summary, the results
+// of all files and the extra files.
+func JSON(report Report) ([]byte, error) {
+	stats := report.Stats
+	out := jsonReport{
+		Recid:     report.Recid,
+		Directory: report.Directory,
+		Summary: jsonSummary{
+			Total:          stats.TotalFiles,
+			Verified:       stats.VerifiedFiles,
+			SizeErrors:     stats.SizeFailed,
+			ChecksumErrors: stats.ChecksumFailed,
+			Missing:        stats.MissingFiles,
+			Unverifiable:   stats.UnsupportedChecksums,
+			Cached:         stats.CachedFiles,
+			SkippedTape:    report.TapeSkipped,
+			Orphans:        stats.OrphanFiles,
+			Duplicates:     stats.DuplicateFiles,
+			ZeroByte:       stats.ZeroByteFiles,
+			Partial:        stats.PartFiles,
+			Pruned:         stats.PrunedFiles,
+		},
+		Files:      stats.Results,
+		ExtraFiles: stats.Extras,
+		ExitCode:   ExitCode(stats.Results),
+	}
+	if out.Files == nil {
+		out.Files = []verifier.VerificationResult{}
+	}
+	if out.ExtraFiles == nil {
+		out.ExtraFiles = []verifier.ExtraFile{}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+// JUnit formats the report as JUnit XML with one test case per file. Wrong
+// or missing files are failures; unreadable files and unverifiable
+// checksums are errors. Extra files are listed in the suite's output.
+func JUnit(report Report) ([]byte, error) {
+	name := "verify " + report.Directory
+	className := report.Directory
+	if report.Recid != 0 {
+		name = fmt.Sprintf("verify record %d", report.Recid)
+		className = fmt.Sprintf("record.%d", report.Recid)
+	}
+
+	suite := junitTestSuite{Name: name}
+	for _, result := range report.Stats.Results {
+		testCase := junitTestCase{ClassName: className, Name: relPath(report.Directory, result.Path)}
+		problem := &junitProblem{Type: result.Status, Message: Describe(result)}
+		switch result.Status {
+		case verifier.StatusVerified:
+		case verifier.StatusUnreadable, verifier.StatusUnverifiable:
+			testCase.Error = problem
+			suite.Errors++
+		default:
+			testCase.Failure = problem
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Tests = len(suite.Cases)
+
+	var out strings.Builder
+	for _, extra := range report.Stats.Extras {
+		fmt.Fprintf(&out, "%s: %s\n", extra.Kind, relPath(report.Directory, extra.Path))
+	}
+	suite.SystemOut = out.String()
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal XML: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// TAP formats the report in the Test Anything Protocol, version 13, with
+// one test per file and YAML diagnostics for the failures. Extra files are
+// listed as comments.
+func TAP(report Report) string {
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", len(report.Stats.Results))
+	for i, result := range report.Stats.Results {
+		name := relPath(report.Directory, result.Path)
+		if result.Status == verifier.StatusVerified {
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, name)
+			continue
+		}
+		fmt.Fprintf(&b, "not ok %d - %s\n", i+1, name)
+		b.WriteString("  ---\n")
+		fmt.Fprintf(&b, "  status: %s\n", result.Status)
+		fmt.Fprintf(&b, "  message: %q\n", Describe(result))
+		b.WriteString("  ...\n")
+	}
+	for _, extra := range report.Stats.Extras {
+		fmt.Fprintf(&b, "# %s: %s\n", extra.Kind, relPath(report.Directory, extra.Path))
+	}
+	return b.String()
+}
+
+// Describe explains the status of a result in one line.
+func Describe(result verifier.VerificationResult) string {
+	switch result.Status {
+	case verifier.StatusMissing:
+		return "file not found"
+	case verifier.StatusUnreadable:
+		return result.Error
+	case verifier.StatusSizeMismatch:
+		return fmt.Sprintf("expected size %d, actual size %d", result.ExpectedSize, result.ActualSize)
+	case verifier.StatusChecksumMismatch:
+		return fmt.Sprintf("expected checksum %s, actual checksum %s", result.ExpectedSum, result.ActualSum)
+	case verifier.StatusUnverifiable:
+		return result.ChecksumError
+	default:
+		return "verified"
+	}
+}
+
+// relPath returns path relative to directory, slash-separated.
+func relPath(directory, path string) string {
+	if rel, err := filepath.Rel(directory, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,163 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/verifier"
+)
+
+func testReport() Report {
+	dir := filepath.Join("data", "5500")
+	results := []verifier.VerificationResult{
+		{Path: filepath.Join(dir, "a.root"), SizeMatch: true, ChecksumMatch: true, FileExists: true, Status: verifier.StatusVerified},
+		{Path: filepath.Join(dir, "b.root"), ExpectedSum: "adler32:00000000", ActualSum: "adler32:045d01c1", SizeMatch: true, FileExists: true, Status: verifier.StatusChecksumMismatch},
+		{Path: filepath.Join(dir, "c.root"), Status: verifier.StatusMissing, Error: "File not found"},
+		{Path: filepath.Join(dir, "d.root"), SizeMatch: true, FileExists: true, ChecksumError: "no checksum", Status: verifier.StatusUnverifiable},
+	}
+	return Report{
+		Recid:     5500,
+		Directory: dir,
+		Stats: &verifier.VerificationStats{
+			TotalFiles:           4,
+			VerifiedFiles:        1,
+			ChecksumFailed:       1,
+			MissingFiles:         1,
+			UnsupportedChecksums: 1,
+			OrphanFiles:          1,
+			Results:              results,
+			Extras:               []verifier.ExtraFile{{Path: filepath.Join(dir, "sub", "x.txt"), Kind: verifier.Orphan, Size: 3}},
+		},
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     int
+	}{
+		{name: "all verified", statuses: []string{verifier.StatusVerified, verifier.StatusVerified}, want: 0},
+		{name: "missing", statuses: []string{verifier.StatusMissing}, want: ExitMissing},
+		{name: "unreadable", statuses: []string{verifier.StatusUnreadable}, want: ExitMissing},
+		{name: "size", statuses: []string{verifier.StatusSizeMismatch}, want: ExitSizeMismatch},
+		{name: "combined", statuses: []string{verifier.StatusChecksumMismatch, verifier.StatusMissing, verifier.StatusUnverifiable}, want: 26},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []verifier.VerificationResult
+			for _, status := range tt.statuses {
+				// Size mismatches here have a matching checksum.
+				results = append(results, verifier.VerificationResult{Status: status, ChecksumMatch: true})
+			}
+			if got := ExitCode(results); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	both := []verifier.VerificationResult{{Status: verifier.StatusSizeMismatch}}
+	if got := ExitCode(both); got != ExitSizeMismatch+ExitChecksumMismatch {
+		t.Errorf("ExitCode() for a wrong size and checksum = %d", got)
+	}
+
+	unverifiable := []verifier.VerificationResult{{Status: verifier.StatusSizeMismatch, ChecksumError: "unknown checksum algorithm"}}
+	if got := ExitCode(unverifiable); got != ExitSizeMismatch+ExitUnverifiable {
+		t.Errorf("ExitCode() for a wrong size and unusable checksum = %d", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := JSON(testReport())
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+
+	var got struct {
+		Recid   int `json:"recid"`
+		Summary struct {
+			Total   int `json:"total"`
+			Orphans int `json:"orphans"`
+		} `json:"summary"`
+		Files []struct {
+			Path   string `json:"path"`
+			Status string `json:"status"`
+		} `json:"files"`
+		ExtraFiles []struct {
+			Kind string `json:"kind"`
+		} `json:"extra_files"`
+		ExitCode int `json:"exit_code"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	if got.Recid != 5500 || got.Summary.Total != 4 || got.Summary.Orphans != 1 {
+		t.Errorf("unexpected report: %+v", got)
+	}
+	if len(got.Files) != 4 || got.Files[1].Status != verifier.StatusChecksumMismatch {
+		t.Errorf("unexpected files: %+v", got.Files)
+	}
+	if len(got.ExtraFiles) != 1 || got.ExtraFiles[0].Kind != verifier.Orphan {
+		t.Errorf("unexpected extra files: %+v", got.ExtraFiles)
+	}
+	if got.ExitCode != ExitChecksumMismatch+ExitMissing+ExitUnverifiable {
+		t.Errorf("exit_code = %d", got.ExitCode)
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	data, err := JUnit(testReport())
+	if err != nil {
+		t.Fatalf("JUnit() error = %v", err)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, data)
+	}
+	suite := got.Suites[0]
+	if suite.Name != "verify record 5500" || suite.Tests != 4 || suite.Failures != 2 || suite.Errors != 1 {
+		t.Errorf("unexpected suite: %+v", suite)
+	}
+	if c := suite.Cases[1]; c.Name != "b.root" || c.ClassName != "record.5500" || c.Failure == nil || c.Failure.Type != verifier.StatusChecksumMismatch {
+		t.Errorf("unexpected test case: %+v", c)
+	}
+	if c := suite.Cases[3]; c.Error == nil || c.Error.Message != "no checksum" {
+		t.Errorf("unverifiable file should be an error: %+v", c)
+	}
+	if !strings.Contains(suite.SystemOut, "orphan: sub/x.txt") {
+		t.Errorf("system-out = %q", suite.SystemOut)
+	}
+}
+
+func TestTAP(t *testing.T) {
+	got := TAP(testReport())
+	for _, line := range []string{
+		"TAP version 13\n1..4\n",
+		"ok 1 - a.root\n",
+		"not ok 2 - b.root\n  ---\n  status: checksum-mismatch\n  message: \"expected checksum adler32:00000000, actual checksum adler32:045d01c1\"\n  ...\n",
+		"not ok 3 - c.root\n",
+		"# orphan: sub/x.txt\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("TAP output missing %q:\n%s", line, got)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, testReport()); err != nil || buf.Len() == 0 {
+			t.Errorf("Write(%s) error = %v, %d bytes", format, err, buf.Len())
+		}
+	}
+	if err := Write(&bytes.Buffer{}, "xml", testReport()); err == nil {
+		t.Error("Write() accepted an unknown format")
+	}
+}
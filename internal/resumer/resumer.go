@@ -0,0 +1,97 @@
+package resumer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+// FetchFunc reads up to length bytes of the remote file starting at
+// offset. It returns fewer bytes if the remote file ends earlier.
+type FetchFunc func(offset, length int64) ([]byte, error)
+
+// VerifiedOffset returns the offset up to which the first size bytes of the
+// partial file at path agree with the remote file, so that a download can
+// be resumed from there.
+//
+// The last config.ResumeVerifySize bytes are fetched again and compared
+// with the local ones. If they match, size is returned; the bytes before
+// them are assumed to be correct. Otherwise the offset of the first
+// differing byte is returned, or, if the whole window differs, the
+// preceding bytes are checked in windows of doubling size until matching
+// bytes are found. The bytes are compared in chunks of
+// config.ResumeVerifySize. Once config.ResumeVerifyLimit bytes would be
+// exceeded, or at the start of the file, 0 is returned and the file has to
+// be downloaded again.
+func VerifiedOffset(path string, size int64, fetch FetchFunc) (int64, error) {
+	file, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	buf := make([]byte, config.ResumeVerifySize)
+	budget := int64(config.ResumeVerifyLimit)
+	end := size
+	window := int64(config.ResumeVerifySize)
+	for end > 0 {
+		start := max(end-window, 0)
+		if end-start > budget {
+			return 0, nil
+		}
+		budget -= end - start
+
+		n, err := matchingBytes(file, buf, start, end, fetch)
+		if err != nil {
+			return 0, fmt.Errorf("failed to verify %s: %w", path, err)
+		}
+		if n == end-start {
+			return end, nil
+		}
+		if n > 0 {
+			return start + n, nil
+		}
+		end = start
+		window *= 2
+	}
+	return 0, nil
+}
+
+// matchingBytes returns the number of bytes of file from start on, up to
+// end, that agree with the remote file, comparing len(buf) bytes at a time.
+func matchingBytes(file *os.File, buf []byte, start, end int64, fetch FetchFunc) (int64, error) {
+	for offset := start; offset < end; {
+		local := buf[:min(int64(len(buf)), end-offset)]
+		if _, err := file.ReadAt(local, offset); err != nil && err != io.EOF {
+			return 0, err
+		}
+		remote, err := fetch(offset, int64(len(local)))
+		if err != nil {
+			return 0, err
+		}
+
+		n := commonPrefix(local, remote)
+		offset += int64(n)
+		if n < len(local) {
+			return offset - start, nil
+		}
+	}
+	return end - start, nil
+}
+
+// commonPrefix returns the number of leading bytes that a and b share.
+func commonPrefix(a, b []byte) int {
+	n := min(len(a), len(b))
+	if bytes.Equal(a[:n], b[:n]) {
+		return n
+	}
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
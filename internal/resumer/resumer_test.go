@@ -0,0 +1,160 @@
+package resumer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+func TestVerifiedOffset(t *testing.T) {
+	const size = 3 * config.ResumeVerifySize
+	remote := make([]byte, 4*config.ResumeVerifySize)
+	for i := range remote {
+		remote[i] = byte(i % 251)
+	}
+
+	tests := []struct {
+		name    string
+		local   func() []byte
+		remote  []byte
+		want    int64
+		fetches int
+	}{
+		{
+			name:    "intact",
+			local:   func() []byte { return bytes.Clone(remote[:size]) },
+			remote:  remote,
+			want:    size,
+			fetches: 1,
+		},
+		{
+			name: "corrupt byte in the tail",
+			local: func() []byte {
+				local := bytes.Clone(remote[:size])
+				local[size-100] ^= 0xff
+				return local
+			},
+			remote:  remote,
+			want:    size - 100,
+			fetches: 1,
+		},
+		{
+			name: "corrupt from before the tail",
+			local: func() []byte {
+				local := bytes.Clone(remote[:size])
+				for i := config.ResumeVerifySize / 2; i < size; i++ {
+					local[i] = 0xff
+				}
+				return local
+			},
+			remote:  remote,
+			want:    config.ResumeVerifySize / 2,
+			fetches: 2,
+		},
+		{
+			name: "corrupt window boundary",
+			local: func() []byte {
+				local := bytes.Clone(remote[:size])
+				for i := size - config.ResumeVerifySize; i < size; i++ {
+					local[i] = 0xff
+				}
+				return local
+			},
+			remote:  remote,
+			want:    size - config.ResumeVerifySize,
+			fetches: 3,
+		},
+		{
+			name:    "nothing in common",
+			local:   func() []byte { return bytes.Repeat([]byte{0xff}, size) },
+			remote:  remote,
+			want:    0,
+			fetches: 2,
+		},
+		{
+			name:    "shorter remote file",
+			local:   func() []byte { return bytes.Clone(remote[:size]) },
+			remote:  remote[:size-10],
+			want:    size - 10,
+			fetches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.dat")
+			if err := os.WriteFile(path, tt.local(), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			fetches := 0
+			fetch := func(offset, length int64) ([]byte, error) {
+				fetches++
+				if offset >= int64(len(tt.remote)) {
+					return nil, nil
+				}
+				return tt.remote[offset:min(offset+length, int64(len(tt.remote)))], nil
+			}
+
+			got, err := VerifiedOffset(path, size, fetch)
+			if err != nil {
+				t.Fatalf("VerifiedOffset() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifiedOffset() = %d, want %d", got, tt.want)
+			}
+			if fetches != tt.fetches {
+				t.Errorf("fetched %d times, want %d", fetches, tt.fetches)
+			}
+		})
+	}
+}
+
+func TestVerifiedOffsetLimit(t *testing.T) {
+	// Corrupt from the start, with more bytes to check than the limit.
+	size := int64(config.ResumeVerifyLimit + config.ResumeVerifySize)
+	path := filepath.Join(t.TempDir(), "file.dat")
+	if err := os.WriteFile(path, bytes.Repeat([]byte{0xff}, int(size)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var fetched, longest int64
+	fetch := func(offset, length int64) ([]byte, error) {
+		fetched += length
+		longest = max(longest, length)
+		return make([]byte, length), nil
+	}
+
+	got, err := VerifiedOffset(path, size, fetch)
+	if err != nil {
+		t.Fatalf("VerifiedOffset() error = %v", err)
+	}
+	if got != 0 {
+		t.Errorf("VerifiedOffset() = %d, want 0", got)
+	}
+	if fetched > config.ResumeVerifyLimit {
+		t.Errorf("fetched %d bytes, more than the limit of %d", fetched, config.ResumeVerifyLimit)
+	}
+	if longest > config.ResumeVerifySize {
+		t.Errorf("fetched %d bytes at once, more than %d", longest, config.ResumeVerifySize)
+	}
+}
+
+func TestVerifiedOffsetFetchError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.dat")
+	if err := os.WriteFile(path, []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	errFetch := errors.New("connection reset")
+	_, err := VerifiedOffset(path, 7, func(offset, length int64) ([]byte, error) {
+		return nil, errFetch
+	})
+	if !errors.Is(err, errFetch) {
+		t.Errorf("VerifiedOffset() error = %v, want %v", err, errFetch)
+	}
+}
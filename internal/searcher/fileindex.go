@@ -0,0 +1,258 @@
+package searcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+// FileIndex describes one entry of a record's _file_indices metadata.
+type FileIndex struct {
+	Key         string     `json:"key"`
+	URI         string     `json:"uri"`
+	Size        int64      `json:"size"`
+	Checksum    string     `json:"checksum,omitempty"`
+	NumberFiles int        `json:"number_files"`
+	FilesSize   int64      `json:"files_size"`
+	Files       []FileInfo `json:"-"`
+}
+
+// MatchesIndexKey reports whether an index key is selected by pattern. The
+// pattern may be the full key, the key without its .json/.txt extension, or
+// a glob.
+func MatchesIndexKey(key, pattern string) bool {
+	stem := strings.TrimSuffix(key, filepath.Ext(key))
+	for _, candidate := range []string{key, stem} {
+		if candidate == pattern {
+			return true
+		}
+		if matched, err := filepath.Match(pattern, candidate); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFileIndices returns the file indexes of a record together with the
+// files they contain.
+func (c *Client) GetFileIndices(record *RecordResponse, protocol string) ([]FileIndex, error) {
+	if record.Metadata == nil {
+		return nil, fmt.Errorf("metadata is nil")
+	}
+
+	recidInt, err := getMetadataFieldAsInt(record.Metadata, "recid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recid from metadata: %w", err)
+	}
+
+	indices, ok := record.Metadata["_file_indices"].([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	var result []FileIndex
+	for _, index := range indices {
+		indexMap, ok := index.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("index entry is not a map")
+		}
+
+		key, err := getMetadataFieldAsString(indexMap, "key")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get key: %w", err)
+		}
+
+		size, err := getMetadataFieldAsInt64(indexMap, "size")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get size: %w", err)
+		}
+
+		checksum, _ := getMetadataFieldAsString(indexMap, "checksum")
+
+		fileIndex := FileIndex{
+			Key:      key,
+			URI:      fmt.Sprintf("%s/record/%d/file_index/%s", c.server, recidInt, key),
+			Size:     size,
+			Checksum: checksum,
+		}
+
+		if indexFiles, ok := indexMap["files"].([]any); ok {
+			for _, innerFile := range indexFiles {
+				fileMap, ok := innerFile.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("inner file entry is not a map")
+				}
+
+				uri, err := getMetadataFieldAsString(fileMap, "uri")
+				if err != nil {
+					return nil, fmt.Errorf("failed to get uri: %w", err)
+				}
+
+				fileSize, err := getMetadataFieldAsInt64(fileMap, "size")
+				if err != nil {
+					return nil, fmt.Errorf("failed to get size: %w", err)
+				}
+
+				fileChecksum, _ := getMetadataFieldAsString(fileMap, "checksum")
+
+				availability, _ := getMetadataFieldAsString(fileMap, "availability")
+				if availability == "" {
+					availability = "online"
+				}
+
+				fileIndex.Files = append(fileIndex.Files, FileInfo{
+					URI:          convertURI(uri, config.ServerRootURI, c.server, protocol),
+					Size:         fileSize,
+					Checksum:     fileChecksum,
+					Availability: availability,
+				})
+				fileIndex.FilesSize += fileSize
+			}
+		}
+		fileIndex.NumberFiles = len(fileIndex.Files)
+
+		result = append(result, fileIndex)
+	}
+
+	return result, nil
+}
+
+// SelectFileIndices returns the indexes matching any of the given key
+// patterns, each once, in the order they are first matched. It fails if a
+// pattern matches no index.
+func SelectFileIndices(indices []FileIndex, patterns []string) ([]FileIndex, error) {
+	var selected []FileIndex
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matched := false
+		for _, index := range indices {
+			if !MatchesIndexKey(index.Key, pattern) {
+				continue
+			}
+			matched = true
+			if !seen[index.Key] {
+				seen[index.Key] = true
+				selected = append(selected, index)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no file index matching %s", pattern)
+		}
+	}
+	return selected, nil
+}
+
+// GetIndexFilesList returns the files contained in the indexes matching any
+// of the given key patterns.
+func (c *Client) GetIndexFilesList(record *RecordResponse, protocol string, patterns []string) ([]FileInfo, error) {
+	indices, err := c.GetFileIndices(record, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := SelectFileIndices(indices, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileInfo
+	for _, index := range selected {
+		files = append(files, index.Files...)
+	}
+
+	return files, nil
+}
+
+// FetchFileIndex downloads the raw content of a file index. Indexes larger
+// than config.FetchFileMaxSize are refused.
+func (c *Client) FetchFileIndex(index FileIndex) ([]byte, error) {
+	resp, err := c.client.Get(index.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file index %s: %w", index.Key, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode, "file index "+index.Key)
+	}
+
+	tooLarge := fmt.Errorf("file index %s is larger than %d bytes", index.Key, config.FetchFileMaxSize)
+	if resp.ContentLength > config.FetchFileMaxSize {
+		return nil, tooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, config.FetchFileMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file index %s: %w", index.Key, err)
+	}
+	if len(data) > config.FetchFileMaxSize {
+		return nil, tooLarge
+	}
+
+	return data, nil
+}
+
+// ParseFileIndex parses the content of a .json or .txt file index. JSON
+// indexes are arrays of file objects; text indexes list one URI per line.
+func ParseFileIndex(data []byte) ([]FileInfo, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []map[string]any
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("failed to decode file index: %w", err)
+		}
+
+		var files []FileInfo
+		for _, entry := range entries {
+			uri, err := getMetadataFieldAsString(entry, "uri")
+			if err != nil {
+				return nil, fmt.Errorf("failed to get uri: %w", err)
+			}
+			size, _ := getMetadataFieldAsInt64(entry, "size")
+			checksum, _ := getMetadataFieldAsString(entry, "checksum")
+			files = append(files, FileInfo{URI: uri, Size: size, Checksum: checksum})
+		}
+		return files, nil
+	}
+
+	var files []FileInfo
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		files = append(files, FileInfo{URI: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file index: %w", err)
+	}
+
+	return files, nil
+}
+
+// GetFileIndexFiles downloads a file index and returns the files it lists,
+// with URIs converted for the given protocol.
+func (c *Client) GetFileIndexFiles(index FileIndex, protocol string) ([]FileInfo, error) {
+	data, err := c.FetchFileIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ParseFileIndex(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file index %s: %w", index.Key, err)
+	}
+
+	for i := range files {
+		files[i].URI = convertURI(files[i].URI, config.ServerRootURI, c.server, protocol)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,197 @@
+package searcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+func indexedRecord() *RecordResponse {
+	return &RecordResponse{
+		Metadata: map[string]any{
+			"recid": 6004,
+			"_file_indices": []any{
+				map[string]any{
+					"key":  "CMS_Run2012B_AOD_10000_file_index.json",
+					"size": 300,
+					"files": []any{
+						map[string]any{"uri": "root://eospublic.cern.ch//eos/opendata/cms/a.root", "size": 100, "checksum": "adler32:00000001"},
+						map[string]any{"uri": "root://eospublic.cern.ch//eos/opendata/cms/b.root", "size": 200, "checksum": "adler32:00000002", "availability": "on demand"},
+					},
+				},
+				map[string]any{
+					"key":  "CMS_Run2012B_AOD_20000_file_index.json",
+					"size": 100,
+					"files": []any{
+						map[string]any{"uri": "root://eospublic.cern.ch//eos/opendata/cms/c.root", "size": 50},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestMatchesIndexKey(t *testing.T) {
+	key := "CMS_Run2012B_AOD_10000_file_index.json"
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{pattern: key, want: true},
+		{pattern: "CMS_Run2012B_AOD_10000_file_index", want: true},
+		{pattern: "*_10000_*", want: true},
+		{pattern: "CMS_Run2012B_AOD_20000_file_index", want: false},
+		{pattern: "[", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchesIndexKey(key, tt.pattern); got != tt.want {
+			t.Errorf("MatchesIndexKey(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestGetFileIndices(t *testing.T) {
+	client := NewClient("http://opendata.cern.ch")
+	indices, err := client.GetFileIndices(indexedRecord(), "http")
+	if err != nil {
+		t.Fatalf("GetFileIndices failed: %v", err)
+	}
+
+	if len(indices) != 2 {
+		t.Fatalf("got %d indices, want 2", len(indices))
+	}
+
+	first := indices[0]
+	if first.NumberFiles != 2 || first.FilesSize != 300 {
+		t.Errorf("first index has %d files of %d bytes, want 2 files of 300 bytes", first.NumberFiles, first.FilesSize)
+	}
+	if first.URI != "http://opendata.cern.ch/record/6004/file_index/CMS_Run2012B_AOD_10000_file_index.json" {
+		t.Errorf("first index URI = %q", first.URI)
+	}
+	if first.Files[0].URI != "http://opendata.cern.ch/eos/opendata/cms/a.root" {
+		t.Errorf("file URI not converted: %q", first.Files[0].URI)
+	}
+	if first.Files[0].Availability != "online" || first.Files[1].Availability != "on demand" {
+		t.Errorf("unexpected availability: %q, %q", first.Files[0].Availability, first.Files[1].Availability)
+	}
+}
+
+func TestGetIndexFilesList(t *testing.T) {
+	client := NewClient("http://opendata.cern.ch")
+
+	files, err := client.GetIndexFilesList(indexedRecord(), "xrootd", []string{"*_20000_file_index"})
+	if err != nil {
+		t.Fatalf("GetIndexFilesList failed: %v", err)
+	}
+	if len(files) != 1 || files[0].URI != "root://eospublic.cern.ch//eos/opendata/cms/c.root" {
+		t.Errorf("unexpected files: %+v", files)
+	}
+
+	if _, err := client.GetIndexFilesList(indexedRecord(), "xrootd", []string{"missing"}); err == nil {
+		t.Error("expected error for unmatched index pattern")
+	}
+
+	// Indexes matched by several patterns are listed once.
+	files, err = client.GetIndexFilesList(indexedRecord(), "xrootd", []string{"*_20000_*", "*AOD*", "*Run2012B*"})
+	if err != nil {
+		t.Fatalf("GetIndexFilesList failed: %v", err)
+	}
+	var uris []string
+	for _, file := range files {
+		uris = append(uris, filepath.Base(file.URI))
+	}
+	if want := []string{"c.root", "a.root", "b.root"}; !reflect.DeepEqual(uris, want) {
+		t.Errorf("files of overlapping patterns = %v, want %v", uris, want)
+	}
+}
+
+func TestParseFileIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantURIs []string
+		wantSize int64
+		wantErr  bool
+	}{
+		{
+			name:     "json index",
+			data:     `[{"uri": "root://eospublic.cern.ch//a.root", "size": 10, "checksum": "adler32:00000001"}]`,
+			wantURIs: []string{"root://eospublic.cern.ch//a.root"},
+			wantSize: 10,
+		},
+		{
+			name:     "text index",
+			data:     "root://eospublic.cern.ch//a.root\n\n# comment\nroot://eospublic.cern.ch//b.root\n",
+			wantURIs: []string{"root://eospublic.cern.ch//a.root", "root://eospublic.cern.ch//b.root"},
+		},
+		{
+			name:    "invalid json",
+			data:    `[{"uri": }]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParseFileIndex([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFileIndex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(files) != len(tt.wantURIs) {
+				t.Fatalf("got %d files, want %d", len(files), len(tt.wantURIs))
+			}
+			for i, uri := range tt.wantURIs {
+				if files[i].URI != uri {
+					t.Errorf("file %d URI = %q, want %q", i, files[i].URI, uri)
+				}
+			}
+			if files[0].Size != tt.wantSize {
+				t.Errorf("file size = %d, want %d", files[0].Size, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestGetFileIndexFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/record/6004/file_index/huge.txt" {
+			_, _ = w.Write(make([]byte, config.FetchFileMaxSize+1))
+			return
+		}
+		if r.URL.Path != "/record/6004/file_index/index.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("root://eospublic.cern.ch//eos/opendata/cms/a.root\n"))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	index := FileIndex{Key: "index.txt", URI: server.URL + "/record/6004/file_index/index.txt"}
+
+	files, err := client.GetFileIndexFiles(index, "http")
+	if err != nil {
+		t.Fatalf("GetFileIndexFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].URI != server.URL+"/eos/opendata/cms/a.root" {
+		t.Errorf("unexpected files: %+v", files)
+	}
+
+	index.URI = server.URL + "/record/6004/file_index/missing.txt"
+	if _, err := client.GetFileIndexFiles(index, "http"); err == nil {
+		t.Error("expected error for missing index")
+	}
+
+	index.URI = server.URL + "/record/6004/file_index/huge.txt"
+	if _, err := client.FetchFileIndex(index); err == nil {
+		t.Error("expected error for an index larger than the limit")
+	}
+}
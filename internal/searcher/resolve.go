@@ -0,0 +1,222 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrAmbiguous is returned when a DOI or title lookup that must yield one
+// record matches several.
+var ErrAmbiguous = errors.New("more than one record found")
+
+// Candidate is a record matching a DOI or title lookup.
+type Candidate struct {
+	Recid int     `json:"recid"`
+	Title string  `json:"title"`
+	Type  string  `json:"type,omitempty"`
+	Date  string  `json:"date,omitempty"`
+	Score float64 `json:"score,omitempty"`
+}
+
+// Pick strategies accepted by PickCandidate.
+const (
+	PickFirst  = "first"
+	PickNewest = "newest"
+	PickOldest = "oldest"
+)
+
+// candidateLimit is the maximum number of matching records a lookup pages
+// through. Lookups matching more records fail rather than silently leave
+// candidates out.
+const candidateLimit = 500
+
+// dateLayouts are the layouts of the publication and creation dates of
+// records, from the most to the least precise.
+var dateLayouts = []string{time.RFC3339, "2006-01-02", "2006-01", "2006"}
+
+func candidateFromHit(hit SearchHit) (Candidate, error) {
+	recid, err := getMetadataFieldAsInt(hit.Metadata, "recid")
+	if err != nil {
+		return Candidate{}, fmt.Errorf("failed to get recid from search hit %s: %w", hit.ID, err)
+	}
+
+	candidate := Candidate{Recid: recid}
+	candidate.Title, _ = getMetadataFieldAsString(hit.Metadata, "title")
+	if recordType, ok := hit.Metadata["type"].(map[string]any); ok {
+		candidate.Type, _ = getMetadataFieldAsString(recordType, "primary")
+	}
+	if date, err := getMetadataFieldAsString(hit.Metadata, "date_published"); err == nil {
+		candidate.Date = date
+	} else if dates, ok := hit.Metadata["date_created"].([]any); ok && len(dates) > 0 {
+		candidate.Date, _ = dates[0].(string)
+	}
+
+	return candidate, nil
+}
+
+// titleTokens splits a title into lower-case alphanumeric tokens.
+func titleTokens(title string) []string {
+	return strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// TitleSimilarity returns the Jaccard similarity of the title tokens of a and b.
+func TitleSimilarity(a, b string) float64 {
+	tokensA := make(map[string]bool)
+	for _, t := range titleTokens(a) {
+		tokensA[t] = true
+	}
+	tokensB := make(map[string]bool)
+	for _, t := range titleTokens(b) {
+		tokensB[t] = true
+	}
+	if len(tokensA) == 0 || len(tokensB) == 0 {
+		return 0
+	}
+
+	common := 0
+	for t := range tokensA {
+		if tokensB[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(tokensA)+len(tokensB)-common)
+}
+
+// parseDate parses the date of a candidate. Dates that cannot be parsed
+// yield the zero time, so they sort before all others.
+func parseDate(date string) time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// FindCandidates returns all records matching a DOI or title, paging
+// through the search results. Exact lookups use a quoted field query;
+// fuzzy title lookups run a free text search and rank the hits by title
+// similarity. Lookups matching more than candidateLimit records fail.
+// Candidates are returned in a deterministic order: by descending score
+// for fuzzy lookups and by ascending recid otherwise.
+func (c *Client) FindCandidates(field, value string, fuzzy bool) ([]Candidate, error) {
+	q := fmt.Sprintf("%s:\"%s\"", field, value)
+	if fuzzy {
+		if field != "title" {
+			return nil, fmt.Errorf("fuzzy matching is only supported for titles")
+		}
+		q = strings.Join(titleTokens(value), " ")
+	}
+
+	var candidates []Candidate
+	hits := 0
+	errTooMany := errors.New("too many matching records")
+	total, err := c.EachRecord(q, nil, "", func(hit SearchHit) error {
+		hits++
+		if hits > candidateLimit {
+			return errTooMany
+		}
+		candidate, err := candidateFromHit(hit)
+		if err != nil {
+			return err
+		}
+		if fuzzy {
+			candidate.Score = TitleSimilarity(value, candidate.Title)
+			if candidate.Score == 0 {
+				return nil
+			}
+		}
+		candidates = append(candidates, candidate)
+		return nil
+	})
+	if errors.Is(err, errTooMany) {
+		return nil, fmt.Errorf("%d records match %s %q, more than the %d that can be compared", total, field, value, candidateLimit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Recid < candidates[j].Recid
+	})
+
+	return candidates, nil
+}
+
+// PickCandidate selects one candidate according to the given strategy.
+// An empty strategy only succeeds when there is exactly one candidate.
+func PickCandidate(candidates []Candidate, pick string) (Candidate, error) {
+	if len(candidates) == 0 {
+		return Candidate{}, fmt.Errorf("no matching record found")
+	}
+
+	switch pick {
+	case "":
+		if len(candidates) > 1 {
+			return Candidate{}, fmt.Errorf("%w: %d records", ErrAmbiguous, len(candidates))
+		}
+		return candidates[0], nil
+	case PickFirst:
+		return candidates[0], nil
+	case PickNewest, PickOldest:
+		best := candidates[0]
+		bestDate := parseDate(best.Date)
+		for _, candidate := range candidates[1:] {
+			date := parseDate(candidate.Date)
+			newer := date.After(bestDate) || (date.Equal(bestDate) && candidate.Recid > best.Recid)
+			if newer == (pick == PickNewest) {
+				best, bestDate = candidate, date
+			}
+		}
+		return best, nil
+	default:
+		return Candidate{}, fmt.Errorf("unknown pick strategy: %s (choose from '%s', '%s', '%s')", pick, PickFirst, PickNewest, PickOldest)
+	}
+}
+
+// ResolveRecid is like GetRecid but resolves ambiguous DOI or title lookups
+// with a pick strategy and optionally matches titles fuzzily.
+func ResolveRecid(server, doi, title string, recid int, pick string, fuzzy bool) (int, error) {
+	if recid > 0 {
+		return recid, nil
+	}
+
+	if pick == "" && !fuzzy {
+		return GetRecid(server, doi, title, recid)
+	}
+
+	candidates, err := FindRecidCandidates(server, doi, title, fuzzy)
+	if err != nil {
+		return 0, err
+	}
+
+	candidate, err := PickCandidate(candidates, pick)
+	if err != nil {
+		return 0, err
+	}
+	return candidate.Recid, nil
+}
+
+// FindRecidCandidates lists all records matching a DOI or title.
+func FindRecidCandidates(server, doi, title string, fuzzy bool) ([]Candidate, error) {
+	client := NewClient(server)
+
+	if doi != "" {
+		return client.FindCandidates("doi", doi, false)
+	}
+
+	if title != "" {
+		return client.FindCandidates("title", title, fuzzy)
+	}
+
+	return nil, fmt.Errorf("please provide doi or title")
+}
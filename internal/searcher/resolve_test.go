@@ -0,0 +1,179 @@
+package searcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want float64
+	}{
+		{name: "identical", a: "Higgs to four leptons", b: "Higgs to four leptons", want: 1},
+		{name: "case and punctuation", a: "Higgs-to-four-leptons", b: "higgs to four LEPTONS", want: 1},
+		{name: "partial overlap", a: "Higgs to four leptons", b: "Higgs example", want: 0.2},
+		{name: "no overlap", a: "Higgs", b: "muon", want: 0},
+		{name: "empty", a: "", b: "muon", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TitleSimilarity(tt.a, tt.b); got != tt.want {
+				t.Errorf("TitleSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickCandidate(t *testing.T) {
+	candidates := []Candidate{
+		{Recid: 10, Date: "2016"},
+		{Recid: 5, Date: "2019"},
+		{Recid: 7, Date: "2019"},
+		{Recid: 3, Date: "2012"},
+	}
+	// Dates of different precision compare as dates, not as strings.
+	mixed := []Candidate{
+		{Recid: 1, Date: "2019-03-01"},
+		{Recid: 2, Date: "2019"},
+		{Recid: 4, Date: "2018-12"},
+	}
+
+	tests := []struct {
+		name       string
+		candidates []Candidate
+		pick       string
+		want       int
+		wantErr    bool
+	}{
+		{name: "first", candidates: candidates, pick: PickFirst, want: 10},
+		{name: "newest breaks ties by recid", candidates: candidates, pick: PickNewest, want: 7},
+		{name: "oldest", candidates: candidates, pick: PickOldest, want: 3},
+		{name: "newest of mixed dates", candidates: mixed, pick: PickNewest, want: 1},
+		{name: "oldest of mixed dates", candidates: mixed, pick: PickOldest, want: 4},
+		{name: "ambiguous without strategy", candidates: candidates, pick: "", wantErr: true},
+		{name: "single without strategy", candidates: candidates[:1], pick: "", want: 10},
+		{name: "unknown strategy", candidates: candidates, pick: "random", wantErr: true},
+		{name: "no candidates", candidates: nil, pick: PickFirst, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PickCandidate(tt.candidates, tt.pick)
+			if tt.name == "ambiguous without strategy" && !errors.Is(err, ErrAmbiguous) {
+				t.Errorf("PickCandidate() error = %v, want ErrAmbiguous", err)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PickCandidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Recid != tt.want {
+				t.Errorf("PickCandidate() recid = %d, want %d", got.Recid, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCandidates(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("q")
+		w.Header().Set("Content-Type", "application/json")
+		searchResp := SearchResponse{
+			Hits: SearchHits{
+				Total: 3,
+				Hits: []SearchHit{
+					{ID: "20", Metadata: map[string]any{"recid": float64(20), "title": "Higgs example 2012", "type": map[string]any{"primary": "Software"}}},
+					{ID: "5", Metadata: map[string]any{"recid": float64(5), "title": "Higgs to four leptons example", "date_published": "2017"}},
+					{ID: "8", Metadata: map[string]any{"recid": float64(8), "title": "Unrelated muon record"}},
+				},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(searchResp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	t.Run("exact", func(t *testing.T) {
+		candidates, err := client.FindCandidates("title", "Higgs example", false)
+		if err != nil {
+			t.Fatalf("FindCandidates failed: %v", err)
+		}
+		if gotQuery != `title:"Higgs example"` {
+			t.Errorf("query = %q", gotQuery)
+		}
+		if len(candidates) != 3 {
+			t.Fatalf("got %d candidates, want 3", len(candidates))
+		}
+		if candidates[0].Recid != 5 || candidates[1].Recid != 8 || candidates[2].Recid != 20 {
+			t.Errorf("candidates not sorted by recid: %+v", candidates)
+		}
+		if candidates[2].Type != "Software" || candidates[0].Date != "2017" {
+			t.Errorf("unexpected candidate fields: %+v", candidates)
+		}
+	})
+
+	t.Run("fuzzy", func(t *testing.T) {
+		candidates, err := client.FindCandidates("title", "Higgs-to-four-leptons example", true)
+		if err != nil {
+			t.Fatalf("FindCandidates failed: %v", err)
+		}
+		if gotQuery != "higgs to four leptons example" {
+			t.Errorf("query = %q", gotQuery)
+		}
+		if len(candidates) != 2 {
+			t.Fatalf("got %d candidates, want 2", len(candidates))
+		}
+		if candidates[0].Recid != 5 || candidates[0].Score != 1 {
+			t.Errorf("best candidate = %+v, want recid 5 with score 1", candidates[0])
+		}
+	})
+
+	t.Run("fuzzy doi", func(t *testing.T) {
+		if _, err := client.FindCandidates("doi", "10.7483/x", true); err == nil {
+			t.Error("expected error for fuzzy DOI lookup")
+		}
+	})
+}
+
+func TestFindCandidatesPages(t *testing.T) {
+	var total int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		searchResp := SearchResponse{Hits: SearchHits{Total: total}}
+		for recid := (page-1)*size + 1; recid <= min(page*size, total); recid++ {
+			searchResp.Hits.Hits = append(searchResp.Hits.Hits, SearchHit{
+				ID:       strconv.Itoa(recid),
+				Metadata: map[string]any{"recid": float64(recid), "title": fmt.Sprintf("Dataset %d", recid)},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(searchResp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	total = 120
+	candidates, err := client.FindCandidates("title", "Dataset", false)
+	if err != nil {
+		t.Fatalf("FindCandidates failed: %v", err)
+	}
+	if len(candidates) != total || candidates[total-1].Recid != total {
+		t.Errorf("got %d candidates, want all %d", len(candidates), total)
+	}
+
+	total = candidateLimit + 1
+	if _, err := client.FindCandidates("title", "Dataset", false); err == nil {
+		t.Error("expected error for more matching records than the limit")
+	}
+}
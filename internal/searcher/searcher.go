@@ -3,11 +3,12 @@ package searcher
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/clelange/cernopendata-client-go/internal/config"
 )
@@ -157,10 +158,15 @@ func getMetadataFieldAsInt64(metadata map[string]any, field string) (int64, erro
 func NewClient(server string) *Client {
 	return &Client{
 		server: server,
-		client: &http.Client{Timeout: 30 * time.Second},
+		client: &http.Client{Transport: NewRetryTransport(http.DefaultTransport, getSharedLimiter())},
 	}
 }
 
+// Server returns the URL of the server the client queries.
+func (c *Client) Server() string {
+	return c.server
+}
+
 func (c *Client) GetRecord(recid int) (*RecordResponse, error) {
 	url := fmt.Sprintf("%s/api/records/%d", c.server, recid)
 	resp, err := c.client.Get(url)
@@ -170,7 +176,7 @@ func (c *Client) GetRecord(recid int) (*RecordResponse, error) {
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d for record %d", resp.StatusCode, recid)
+		return nil, statusError(resp.StatusCode, fmt.Sprintf("record %d", recid))
 	}
 
 	var recordResp RecordResponse
@@ -185,6 +191,32 @@ func (c *Client) GetRecord(recid int) (*RecordResponse, error) {
 	return &recordResp, nil
 }
 
+// LoadRecordFile reads a record saved to disk, either as the metadata object
+// printed by get-metadata --format json or as a full API record response.
+func LoadRecordFile(path string) (*RecordResponse, error) {
+	data, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("failed to read record file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode record file %s: %w", path, err)
+	}
+
+	record := &RecordResponse{Metadata: raw}
+	if metadata, ok := raw["metadata"].(map[string]any); ok {
+		record.Metadata = metadata
+		record.ID, _ = raw["id"].(string)
+	}
+
+	if recid, err := getMetadataFieldAsInt(record.Metadata, "recid"); err == nil && record.ID == "" {
+		record.ID = strconv.Itoa(recid)
+	}
+
+	return record, nil
+}
+
 func (c *Client) GetRecordByDOI(doi string) (*RecordResponse, error) {
 	return c.getRecordBySearch("doi", doi)
 }
@@ -207,7 +239,7 @@ func (c *Client) getRecordBySearch(field, value string) (*RecordResponse, error)
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d for search", resp.StatusCode)
+		return nil, statusError(resp.StatusCode, "search")
 	}
 
 	var searchResp SearchResponse
@@ -216,11 +248,11 @@ func (c *Client) getRecordBySearch(field, value string) (*RecordResponse, error)
 	}
 
 	if searchResp.Hits.Total == 0 {
-		return nil, fmt.Errorf("no record found with %s: %s", field, value)
+		return nil, fmt.Errorf("%w: no record found with %s: %s", ErrNotFound, field, value)
 	}
 
 	if searchResp.Hits.Total > 1 {
-		return nil, fmt.Errorf("more than one record found with %s: %s", field, value)
+		return nil, fmt.Errorf("%w with %s: %s", ErrAmbiguous, field, value)
 	}
 
 	return c.GetRecordByID(searchResp.Hits.Hits[0].ID)
@@ -234,6 +266,33 @@ func (c *Client) GetRecordByID(id string) (*RecordResponse, error) {
 	return c.GetRecord(recordID)
 }
 
+// FetchFile downloads the content of a small file, such as a validated run
+// list, into memory. Files larger than config.FetchFileMaxSize are refused.
+func (c *Client) FetchFile(uri string) ([]byte, error) {
+	resp, err := c.client.Get(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file %s: %w", uri, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp.StatusCode, "file "+uri)
+	}
+
+	tooLarge := fmt.Errorf("file %s is larger than %d bytes", uri, config.FetchFileMaxSize)
+	if resp.ContentLength > config.FetchFileMaxSize {
+		return nil, tooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, config.FetchFileMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", uri, err)
+	}
+	if len(data) > config.FetchFileMaxSize {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
 // convertURI transforms a URI based on protocol settings
 func convertURI(uri, serverRoot, serverURI, protocol string) string {
 	if !strings.HasPrefix(uri, serverRoot) {
@@ -462,7 +521,7 @@ func (c *Client) SearchRecords(q string, facets map[string]string, page, size in
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d for search", resp.StatusCode)
+		return nil, statusError(resp.StatusCode, "search")
 	}
 
 	var searchResp SearchResponse
@@ -473,34 +532,56 @@ func (c *Client) SearchRecords(q string, facets map[string]string, page, size in
 	return &searchResp, nil
 }
 
-// SearchAllRecords fetches all matching records by paginating through results
-// in batches of 50. Returns a combined SearchResponse with all hits.
-func (c *Client) SearchAllRecords(q string, facets map[string]string, sort string) (*SearchResponse, error) {
+// EachRecord pages through all matching records in batches of 50 and
+// calls fn for each hit, so that callers can process large result sets
+// without holding them in memory. It stops at the first error from fn.
+// It returns the total number of matching records.
+func (c *Client) EachRecord(q string, facets map[string]string, sort string, fn func(SearchHit) error) (int, error) {
 	const batchSize = 50
-	var allHits []SearchHit
 	page := 1
 	totalRecords := 0
+	seen := 0
 
 	for {
 		resp, err := c.SearchRecords(q, facets, page, batchSize, sort)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 
 		if page == 1 {
 			totalRecords = resp.Hits.Total
 		}
 
-		allHits = append(allHits, resp.Hits.Hits...)
+		for _, hit := range resp.Hits.Hits {
+			if err := fn(hit); err != nil {
+				return totalRecords, err
+			}
+		}
+		seen += len(resp.Hits.Hits)
 
 		// Check if we've fetched all records
-		if len(allHits) >= totalRecords {
+		if seen >= totalRecords || len(resp.Hits.Hits) == 0 {
 			break
 		}
 
 		page++
 	}
 
+	return totalRecords, nil
+}
+
+// SearchAllRecords fetches all matching records by paginating through results
+// in batches of 50. Returns a combined SearchResponse with all hits.
+func (c *Client) SearchAllRecords(q string, facets map[string]string, sort string) (*SearchResponse, error) {
+	var allHits []SearchHit
+	totalRecords, err := c.EachRecord(q, facets, sort, func(hit SearchHit) error {
+		allHits = append(allHits, hit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &SearchResponse{
 		Hits: SearchHits{
 			Total: totalRecords,
@@ -2,10 +2,15 @@ package searcher
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
 )
 
 func TestNewClient(t *testing.T) {
@@ -1091,3 +1096,114 @@ func TestFilterFilesByAvailability(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadRecordFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		wantID  string
+		wantErr bool
+	}{
+		{
+			name:    "metadata object",
+			content: `{"recid": 3005, "title": "Test Record"}`,
+			wantID:  "3005",
+		},
+		{
+			name:    "full record response",
+			content: `{"id": "3005", "metadata": {"recid": 3005, "title": "Test Record"}}`,
+			wantID:  "3005",
+		},
+		{
+			name:    "invalid json",
+			content: `not json`,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("record-%d.json", i))
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := LoadRecordFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRecordFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if record.ID != tt.wantID {
+				t.Errorf("LoadRecordFile() ID = %q, want %q", record.ID, tt.wantID)
+			}
+			if record.Metadata["title"] != "Test Record" {
+				t.Errorf("LoadRecordFile() title = %v, want %q", record.Metadata["title"], "Test Record")
+			}
+		})
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/record/1002/files/huge.txt" {
+			_, _ = w.Write(make([]byte, config.FetchFileMaxSize+1))
+			return
+		}
+		if r.URL.Path != "/record/1002/files/Cert_JSON.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"190645": [[10, 110]]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	data, err := client.FetchFile(server.URL + "/record/1002/files/Cert_JSON.txt")
+	if err != nil {
+		t.Fatalf("FetchFile failed: %v", err)
+	}
+	if string(data) != `{"190645": [[10, 110]]}` {
+		t.Errorf("unexpected content: %s", data)
+	}
+
+	if _, err := client.FetchFile(server.URL + "/record/1002/files/missing.txt"); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := client.FetchFile(server.URL + "/record/1002/files/huge.txt"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected error for oversized file, got %v", err)
+	}
+}
+
+func TestEachRecord(t *testing.T) {
+	pageRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageRequests++
+		hits := make([]any, 50)
+		for i := range hits {
+			hits[i] = map[string]any{"id": fmt.Sprintf("%d", i), "metadata": map[string]any{}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"hits": map[string]any{"total": 120, "hits": hits}})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	calls := 0
+	total, err := client.EachRecord("", nil, "", func(hit SearchHit) error {
+		calls++
+		if calls == 60 {
+			return fmt.Errorf("stop")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "stop" {
+		t.Errorf("expected error from callback, got %v", err)
+	}
+	if total != 120 || calls != 60 || pageRequests != 2 {
+		t.Errorf("total = %d, calls = %d, pages = %d; want 120, 60, 2", total, calls, pageRequests)
+	}
+}
@@ -0,0 +1,274 @@
+package searcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/clelange/cernopendata-client-go/internal/config"
+)
+
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrRateLimited = errors.New("rate limited by server")
+	ErrClient      = errors.New("request rejected")
+	ErrServer      = errors.New("server error")
+)
+
+// statusError converts an unexpected HTTP status into a typed error: 4xx
+// statuses are client errors and 5xx statuses server errors. Other
+// statuses give an untyped error.
+func statusError(statusCode int, what string) error {
+	var kind error
+	switch {
+	case statusCode == http.StatusNotFound:
+		kind = ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case statusCode >= 400 && statusCode < 500:
+		kind = ErrClient
+	case statusCode >= 500 && statusCode < 600:
+		kind = ErrServer
+	default:
+		return fmt.Errorf("server returned status %d for %s", statusCode, what)
+	}
+	return fmt.Errorf("%w: server returned status %d for %s", kind, statusCode, what)
+}
+
+// Limiter caps the request rate and the number of concurrent requests. A
+// single Limiter is shared by all clients so that goroutines running bulk
+// jobs cannot overload the portal.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	slots    chan struct{}
+}
+
+// NewLimiter returns a Limiter allowing requestsPerSecond requests per second
+// and maxConcurrent requests in flight. Non-positive values disable the
+// respective limit.
+func NewLimiter(requestsPerSecond float64, maxConcurrent int) *Limiter {
+	l := &Limiter{}
+	if requestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// Acquire blocks until a request may be sent. The returned function must be
+// called once the request has completed.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if l.interval > 0 {
+		l.mu.Lock()
+		now := time.Now()
+		wait := l.next.Sub(now)
+		if wait < 0 {
+			wait = 0
+			l.next = now
+		}
+		l.next = l.next.Add(l.interval)
+		l.mu.Unlock()
+
+		if wait > 0 {
+			if err := sleepContext(ctx, wait); err != nil {
+				release()
+				return nil, err
+			}
+		}
+	}
+
+	return release, nil
+}
+
+var (
+	sharedLimiterMu sync.Mutex
+	sharedLimiter   = NewLimiter(config.APIRequestsPerSecond, config.APIMaxConcurrentRequests)
+)
+
+// SetRateLimit replaces the limiter shared by all clients created afterwards.
+func SetRateLimit(requestsPerSecond float64, maxConcurrent int) {
+	sharedLimiterMu.Lock()
+	defer sharedLimiterMu.Unlock()
+	sharedLimiter = NewLimiter(requestsPerSecond, maxConcurrent)
+}
+
+func getSharedLimiter() *Limiter {
+	sharedLimiterMu.Lock()
+	defer sharedLimiterMu.Unlock()
+	return sharedLimiter
+}
+
+// RetryTransport is an http.RoundTripper that retries failed requests with
+// exponential backoff, honours Retry-After headers up to MaxDelay, and
+// applies a Limiter.
+type RetryTransport struct {
+	Base       http.RoundTripper
+	Limiter    *Limiter
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// Timeout bounds each attempt, including reading the response body.
+	Timeout time.Duration
+}
+
+// NewRetryTransport returns a RetryTransport using the configured defaults.
+func NewRetryTransport(base http.RoundTripper, limiter *Limiter) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RetryTransport{
+		Base:       base,
+		Limiter:    limiter,
+		MaxRetries: config.APIRetryLimit,
+		BaseDelay:  config.APIRetryBaseDelay * time.Second,
+		MaxDelay:   config.APIRetryMaxDelay * time.Second,
+		Timeout:    config.APIRequestTimeout * time.Second,
+	}
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// releaseBody releases the limiter slot and cancels the per-attempt context
+// once the response body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the delay before retry attempt n (starting at 0).
+func (t *RetryTransport) backoff(n int) time.Duration {
+	delay := t.BaseDelay << n
+	if delay <= 0 || (t.MaxDelay > 0 && delay > t.MaxDelay) {
+		delay = t.MaxDelay
+	}
+	return delay
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	canRetry := req.Body == nil || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if t.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, t.Timeout)
+		}
+		attemptReq := req.Clone(attemptCtx)
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+
+		release := cancel
+		if t.Limiter != nil {
+			releaseSlot, err := t.Limiter.Acquire(ctx)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			release = func() {
+				releaseSlot()
+				cancel()
+			}
+		}
+
+		resp, err := t.Base.RoundTrip(attemptReq)
+		last := !canRetry || attempt >= t.MaxRetries
+		if err != nil {
+			release()
+			if last || ctx.Err() != nil {
+				return nil, err
+			}
+			if err := sleepContext(ctx, t.backoff(attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+
+		if !isRetryableStatus(resp.StatusCode) || last {
+			return resp, nil
+		}
+
+		delay, ok := retryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			delay = t.backoff(attempt)
+		} else if t.MaxDelay > 0 && delay > t.MaxDelay {
+			delay = t.MaxDelay
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
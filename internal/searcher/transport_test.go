@@ -0,0 +1,243 @@
+package searcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Tests talk to local servers; don't throttle them.
+	SetRateLimit(0, 0)
+	os.Exit(m.Run())
+}
+
+func newTestTransport() *RetryTransport {
+	transport := NewRetryTransport(http.DefaultTransport, nil)
+	transport.BaseDelay = time.Millisecond
+	transport.MaxDelay = 5 * time.Millisecond
+	return transport
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		status     int
+		maxRetries int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{name: "success after transient errors", failures: 2, status: http.StatusServiceUnavailable, maxRetries: 3, wantStatus: http.StatusOK, wantCalls: 3},
+		{name: "gives up after max retries", failures: 10, status: http.StatusBadGateway, maxRetries: 2, wantStatus: http.StatusBadGateway, wantCalls: 3},
+		{name: "no retry on not found", failures: 10, status: http.StatusNotFound, maxRetries: 3, wantStatus: http.StatusNotFound, wantCalls: 1},
+		{name: "no retry on internal error", failures: 10, status: http.StatusInternalServerError, maxRetries: 3, wantStatus: http.StatusInternalServerError, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(atomic.AddInt32(&calls, 1)) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			transport := newTestTransport()
+			transport.MaxRetries = tt.maxRetries
+			client := &http.Client{Transport: transport}
+
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	var calls int32
+	var first time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if elapsed := time.Since(first); elapsed < 900*time.Millisecond {
+			t.Errorf("retried after %v, want at least 1s", elapsed)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := newTestTransport()
+	transport.MaxDelay = 2 * time.Second
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestRetryTransportRetryAfterCapped(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newTestTransport()}
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retried after %v, want at most MaxDelay", elapsed)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("5"); !ok || d != 5*time.Second {
+		t.Errorf("retryAfter(5) = %v, %v", d, ok)
+	}
+	if _, ok := retryAfter(""); ok {
+		t.Error("retryAfter(\"\") should not be ok")
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Error("retryAfter(soon) should not be ok")
+	}
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d, ok := retryAfter(future); !ok || d < 59*time.Minute {
+		t.Errorf("retryAfter(date) = %v, %v", d, ok)
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	limiter := NewLimiter(0, 2)
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				m := atomic.LoadInt32(&maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > 2 {
+		t.Errorf("max in flight = %d, want <= 2", maxInFlight)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	limiter := NewLimiter(100, 0)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := limiter.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("5 requests at 100/s took %v, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	blocked := NewLimiter(0, 1)
+	release, _ := blocked.Acquire(context.Background())
+	defer release()
+	if _, err := blocked.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire with cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   error
+	}{
+		{name: "not found", status: http.StatusNotFound, want: ErrNotFound},
+		{name: "bad request", status: http.StatusBadRequest, want: ErrClient},
+		{name: "forbidden", status: http.StatusForbidden, want: ErrClient},
+		{name: "internal error", status: http.StatusInternalServerError, want: ErrServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL)
+			_, err := client.GetRecord(1)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("GetRecord() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := statusError(http.StatusTooManyRequests, "search"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("statusError(429) = %v, want ErrRateLimited", err)
+	}
+	if err := statusError(http.StatusUnauthorized, "search"); errors.Is(err, ErrServer) {
+		t.Errorf("statusError(401) = %v, should not be ErrServer", err)
+	}
+	if err := statusError(http.StatusNotModified, "search"); errors.Is(err, ErrServer) || errors.Is(err, ErrClient) {
+		t.Errorf("statusError(304) = %v, should be untyped", err)
+	}
+}
@@ -0,0 +1,169 @@
+package verifier
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+	"github.com/clelange/cernopendata-client-go/internal/printer"
+)
+
+// Kinds of extra files.
+const (
+	// Orphan is a file that does not belong to the record.
+	Orphan = "orphan"
+	// Duplicate is a copy of a record file at another place in the tree.
+	Duplicate = "duplicate"
+	// ZeroByte is an empty file, usually left by a failed download.
+	ZeroByte = "zero-byte"
+	// Part is a .part file, as left by interrupted downloads of other
+	// tools such as wget or browsers.
+	Part = "part"
+)
+
+// ExtraFile is a file in a download directory that is not one of the
+// expected files.
+type ExtraFile struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Size int64  `json:"size"`
+}
+
+// recordDirPattern matches the names of the subdirectories in which
+// download-files --with-related stores related records.
+var recordDirPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// FindExtraFiles walks the tree below directory and adds the files that
+// are not among expectedFiles to stats, classified by kind. The expected
+// files are located as in VerifyFiles. Checksum cache sidecar files and
+// the top-level subdirectories named after a record ID, which hold related
+// records, are left out.
+func (v *Verifier) FindExtraFiles(directory string, expectedFiles []any, stats *VerificationStats) error {
+	expectedPaths := make(map[string]bool)
+	expectedNames := make(map[string]bool)
+	for _, file := range expectedFiles {
+		fileMap, ok := file.(map[string]any)
+		if !ok {
+			continue
+		}
+		uri, _ := fileMap["uri"].(string)
+		fileName := filepath.Base(uri)
+		if path, ok := fileMap["path"].(string); ok && path != "" {
+			fileName = filepath.FromSlash(path)
+		}
+		expectedPaths[filepath.Join(directory, fileName)] = true
+		expectedNames[filepath.Base(fileName)] = true
+	}
+
+	root := filepath.Clean(directory)
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if filepath.Dir(path) == root && path != root && recordDirPattern.MatchString(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), checksum.CacheFileName) || expectedPaths[path] {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		extra := ExtraFile{Path: path, Size: info.Size()}
+		rel, _ := filepath.Rel(root, path)
+		switch {
+		case strings.HasSuffix(d.Name(), ".part"):
+			extra.Kind = Part
+			stats.PartFiles++
+			v.display(printer.Warning, fmt.Sprintf("Partial download: %s", rel))
+		case info.Size() == 0:
+			extra.Kind = ZeroByte
+			stats.ZeroByteFiles++
+			v.display(printer.Warning, fmt.Sprintf("Zero-byte file: %s", rel))
+		case expectedNames[d.Name()]:
+			extra.Kind = Duplicate
+			stats.DuplicateFiles++
+			v.display(printer.Warning, fmt.Sprintf("Duplicate of a record file: %s", rel))
+		default:
+			extra.Kind = Orphan
+			stats.OrphanFiles++
+			v.display(printer.Warning, fmt.Sprintf("Orphan file: %s", rel))
+		}
+		stats.Extras = append(stats.Extras, extra)
+		return nil
+	})
+}
+
+// CheckPrune returns an error if the extra files in stats suggest that the
+// directory is not the download directory of the record, so that pruning
+// would remove unrelated data: none of the record files were found, or
+// there are more orphans than verified files.
+func CheckPrune(stats *VerificationStats) error {
+	if stats.TotalFiles > 0 && stats.MissingFiles == stats.TotalFiles {
+		return fmt.Errorf("none of the %d record files were found in the directory", stats.TotalFiles)
+	}
+	if stats.OrphanFiles > stats.VerifiedFiles {
+		return fmt.Errorf("the directory holds %d orphan files but only %d verified record files", stats.OrphanFiles, stats.VerifiedFiles)
+	}
+	return nil
+}
+
+// Prune removes the extra files found by FindExtraFiles, and the
+// subdirectories of directory that are left empty. It carries on after a
+// failed removal and returns the first error. With dryRun, it only lists
+// the files it would remove.
+func (v *Verifier) Prune(directory string, stats *VerificationStats, dryRun bool) error {
+	root := filepath.Clean(directory)
+	if dryRun {
+		for _, extra := range stats.Extras {
+			rel, _ := filepath.Rel(root, extra.Path)
+			v.display(printer.Note, fmt.Sprintf("Would remove: %s", rel))
+		}
+		return nil
+	}
+
+	var firstErr error
+	var removed []string
+	for _, extra := range stats.Extras {
+		if err := os.Remove(extra.Path); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		stats.PrunedFiles++
+		removed = append(removed, extra.Path)
+		if v.Cache != nil {
+			v.Cache.Forget(extra.Path)
+		}
+		rel, _ := filepath.Rel(root, extra.Path)
+		v.display(printer.Info, fmt.Sprintf("Removed: %s", rel))
+	}
+
+	// Saving the cache removes sidecar files that have no entries left,
+	// which would otherwise keep their directories from being removed.
+	if v.Cache != nil {
+		if err := v.Cache.Save(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Removing a directory fails while it still has entries.
+	for _, path := range removed {
+		for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return firstErr
+}
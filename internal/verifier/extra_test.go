@@ -0,0 +1,137 @@
+package verifier
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
+)
+
+func TestFindExtraFiles(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{
+		"a.root":               "test",
+		"b.root.part":          "te",
+		"b.root.corrupt":       "tset",
+		"empty.root":           "",
+		"notes.txt":            "notes",
+		"copy/a.root":          "test",
+		"copy/deep/other.dat":  "x",
+		"1234/related.root":    "related",
+		checksum.CacheFileName: "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(testDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectedFiles := []any{
+		map[string]any{"uri": "http://example.com/a.root", "size": float64(4), "checksum": "adler32:045d01c1"},
+		map[string]any{"uri": "http://example.com/b.root", "size": float64(4), "checksum": "adler32:045d01c1"},
+	}
+
+	v := NewVerifier()
+	stats := &VerificationStats{}
+	captureStdout(t, func() {
+		if err := v.FindExtraFiles(testDir, expectedFiles, stats); err != nil {
+			t.Fatalf("FindExtraFiles() error = %v", err)
+		}
+	})
+
+	var got []string
+	for _, extra := range stats.Extras {
+		rel, _ := filepath.Rel(testDir, extra.Path)
+		got = append(got, filepath.ToSlash(rel)+" "+extra.Kind)
+	}
+	sort.Strings(got)
+	want := []string{
+		"b.root.corrupt orphan",
+		"b.root.part part",
+		"copy/a.root duplicate",
+		"copy/deep/other.dat orphan",
+		"empty.root zero-byte",
+		"notes.txt orphan",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extra files = %q, want %q", got, want)
+	}
+	if stats.OrphanFiles != 3 || stats.DuplicateFiles != 1 || stats.ZeroByteFiles != 1 || stats.PartFiles != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Cache a checksum of an orphan so that its sidecar must go too.
+	orphan := filepath.Join(testDir, "copy", "deep", "other.dat")
+	info, err := os.Stat(orphan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Cache.Store(orphan, info, "adler32:00790079"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run lists the files and leaves them alone.
+	output := captureStdout(t, func() {
+		if err := v.Prune(testDir, stats, true); err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "Would remove: notes.txt") {
+		t.Errorf("dry run output missing notes.txt:\n%s", output)
+	}
+	if stats.PrunedFiles != 0 {
+		t.Errorf("PrunedFiles = %d after a dry run, want 0", stats.PrunedFiles)
+	}
+	for _, extra := range stats.Extras {
+		if _, err := os.Stat(extra.Path); err != nil {
+			t.Errorf("dry run removed %s: %v", extra.Path, err)
+		}
+	}
+
+	captureStdout(t, func() {
+		if err := v.Prune(testDir, stats, false); err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+	})
+	if stats.PrunedFiles != 6 {
+		t.Errorf("PrunedFiles = %d, want 6", stats.PrunedFiles)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "copy")); !os.IsNotExist(err) {
+		t.Errorf("empty subdirectory not removed: %v", err)
+	}
+	for _, name := range []string{"a.root", "1234/related.root", checksum.CacheFileName} {
+		if _, err := os.Stat(filepath.Join(testDir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s should be kept: %v", name, err)
+		}
+	}
+}
+
+func TestCheckPrune(t *testing.T) {
+	tests := []struct {
+		name    string
+		stats   VerificationStats
+		wantErr bool
+	}{
+		{name: "record directory", stats: VerificationStats{TotalFiles: 3, VerifiedFiles: 3, OrphanFiles: 1}},
+		{name: "as many orphans as verified files", stats: VerificationStats{TotalFiles: 3, VerifiedFiles: 2, MissingFiles: 1, OrphanFiles: 2}},
+		{name: "no record files found", stats: VerificationStats{TotalFiles: 3, MissingFiles: 3}, wantErr: true},
+		{name: "more orphans than verified files", stats: VerificationStats{TotalFiles: 3, VerifiedFiles: 3, OrphanFiles: 4}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckPrune(&tt.stats); (err != nil) != tt.wantErr {
+				t.Errorf("CheckPrune() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
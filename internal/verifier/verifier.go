@@ -2,23 +2,47 @@ package verifier
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/clelange/cernopendata-client-go/internal/checksum"
 	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/progress"
+)
+
+// Statuses of a verified file, from the most to the least severe.
+const (
+	StatusMissing          = "missing"
+	StatusUnreadable       = "unreadable"
+	StatusSizeMismatch     = "size-mismatch"
+	StatusChecksumMismatch = "checksum-mismatch"
+	StatusUnverifiable     = "unverifiable"
+	StatusVerified         = "verified"
 )
 
 type VerificationResult struct {
-	Path          string
-	ExpectedSize  int64
-	ActualSize    int64
-	ExpectedSum   string
-	ActualSum     string
-	SizeMatch     bool
-	ChecksumMatch bool
-	FileExists    bool
+	Path          string `json:"path"`
+	ExpectedSize  int64  `json:"expected_size"`
+	ActualSize    int64  `json:"actual_size"`
+	ExpectedSum   string `json:"expected_checksum"`
+	ActualSum     string `json:"actual_checksum,omitempty"`
+	SizeMatch     bool   `json:"size_match"`
+	ChecksumMatch bool   `json:"checksum_match"`
+	FileExists    bool   `json:"exists"`
+	// ActualSums holds the computed checksums keyed by algorithm.
+	ActualSums map[string]string `json:"checksums,omitempty"`
+	// ChecksumError explains why the checksum could not be verified, for
+	// example because the expected value uses an unknown algorithm.
+	ChecksumError string `json:"checksum_error,omitempty"`
+	// Status is the most severe problem found, or StatusVerified.
+	Status string `json:"status"`
+	// Error explains why a missing or unreadable file could not be
+	// checked.
+	Error string `json:"error,omitempty"`
+	// Cached is set if the checksum was taken from the checksum cache.
+	Cached bool `json:"cached"`
 }
 
 type VerificationStats struct {
@@ -27,57 +51,114 @@ type VerificationStats struct {
 	SizeFailed     int
 	ChecksumFailed int
 	MissingFiles   int
+	// UnsupportedChecksums counts the files whose expected checksum is
+	// missing or uses an unknown algorithm.
+	UnsupportedChecksums int
+	// CachedFiles counts the files whose checksum was taken from the
+	// checksum cache instead of being computed.
+	CachedFiles int
+	// Results holds the results of all expected files, in order.
+	Results []VerificationResult
+	// Failures holds, in order, the results of the files that are missing
+	// or have a wrong size or checksum.
+	Failures []VerificationResult
+	// OrphanFiles, DuplicateFiles, ZeroByteFiles and PartFiles count the
+	// extra files found by FindExtraFiles, by kind.
+	OrphanFiles    int
+	DuplicateFiles int
+	ZeroByteFiles  int
+	PartFiles      int
+	// PrunedFiles counts the extra files removed by Prune.
+	PrunedFiles int
+	// Extras holds the extra files in the order of the directory walk.
+	Extras []ExtraFile
 }
 
-type Verifier struct{}
+type Verifier struct {
+	// Jobs is the number of files hashed in parallel by VerifyFiles.
+	Jobs int
+	// ShowProgress displays the bytes hashed and the estimated time left.
+	ShowProgress bool
+	// Cache, if not nil, supplies the checksums of unchanged files and
+	// records the checksums computed by VerifyFiles.
+	Cache *checksum.Cache
+	// Rehash computes every checksum even if it is cached.
+	Rehash bool
+	// Algorithms lists checksum algorithms computed in addition to the
+	// algorithm of the expected checksum, in the same read pass.
+	Algorithms []string
+	// Quiet suppresses the messages about individual files and sends the
+	// progress display to standard error, leaving standard output to
+	// machine-readable reports.
+	Quiet bool
+}
 
 func NewVerifier() *Verifier {
-	return &Verifier{}
+	return &Verifier{Jobs: 1, Cache: checksum.NewCache()}
 }
 
+// VerifyLocalFiles prints the path, size and checksum of every file below
+// directory, walking subdirectories. Checksum cache sidecar files are
+// left out.
 func (v *Verifier) VerifyLocalFiles(directory string) (*VerificationStats, error) {
 	stats := &VerificationStats{}
 
-	files, err := os.ReadDir(directory)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory %s: %w", directory, err)
-	}
-
-	stats.TotalFiles = len(files)
-
-	for _, file := range files {
-		if file.IsDir() {
-			stats.TotalFiles--
-			continue
+	err := filepath.WalkDir(directory, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
-
-		filePath := filepath.Join(directory, file.Name())
+		if d.IsDir() || strings.HasPrefix(d.Name(), checksum.CacheFileName) {
+			return nil
+		}
+		stats.TotalFiles++
 
 		actualSize, err := checksum.GetFileSize(filePath)
 		if err != nil {
 			stats.MissingFiles++
 			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get size: %s", filePath))
-			continue
+			return nil
 		}
 
 		actualChecksum, err := checksum.CalculateChecksum(filePath)
 		if err != nil {
 			stats.MissingFiles++
 			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to calculate checksum: %s", filePath))
-			continue
+			return nil
 		}
 
 		printer.DisplayOutput(fmt.Sprintf("%s\t%d\t%s", filePath, actualSize, actualChecksum))
 		stats.VerifiedFiles++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", directory, err)
 	}
 
 	return stats, nil
 }
 
+// fileCheck is one expected file queued for verification.
+type fileCheck struct {
+	index    int
+	fileName string
+	result   VerificationResult
+	cached   bool
+	noSize   bool
+	failure  string
+	extra    []string
+}
+
+// VerifyFiles verifies the expected files found in directory. Files are
+// hashed by v.Jobs workers in parallel, but the results are reported in the
+// order of expectedFiles. A file entry with a path key is looked up at that
+// relative path instead of by the base name of its uri, and an entry
+// without a size is verified by its checksum only.
 func (v *Verifier) VerifyFiles(directory string, expectedFiles []any) (*VerificationStats, error) {
 	stats := &VerificationStats{}
 	stats.TotalFiles = len(expectedFiles)
 
+	var checks []*fileCheck
+	var totalBytes int64
 	for _, file := range expectedFiles {
 		fileMap, ok := file.(map[string]any)
 		if !ok {
@@ -86,64 +167,264 @@ func (v *Verifier) VerifyFiles(directory string, expectedFiles []any) (*Verifica
 		}
 
 		uri, _ := fileMap["uri"].(string)
-		expectedSize, _ := fileMap["size"].(float64)
+		expectedSize, hasSize := fileMap["size"].(float64)
 		expectedChecksum, _ := fileMap["checksum"].(string)
 
+		// Files are downloaded flat into the directory, but manifest
+		// entries give a path relative to it.
 		fileName := filepath.Base(uri)
-		filePath := filepath.Join(directory, fileName)
+		if path, ok := fileMap["path"].(string); ok && path != "" {
+			fileName = filepath.FromSlash(path)
+		}
+		checks = append(checks, &fileCheck{
+			index:    len(checks),
+			fileName: fileName,
+			noSize:   !hasSize,
+			result: VerificationResult{
+				Path:         filepath.Join(directory, fileName),
+				ExpectedSize: int64(expectedSize),
+				ExpectedSum:  expectedChecksum,
+			},
+		})
+		totalBytes += int64(expectedSize)
+	}
 
-		result := VerificationResult{
-			Path:         filePath,
-			ExpectedSize: int64(expectedSize),
-			ExpectedSum:  expectedChecksum,
+	var tracker *progress.Tracker
+	if v.ShowProgress && len(checks) > 0 {
+		tracker = progress.NewTracker("Verifying", len(checks), totalBytes)
+		if v.Quiet {
+			tracker.SetOutput(os.Stderr)
 		}
+	}
 
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			result.FileExists = false
-			stats.MissingFiles++
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("File not found: %s", filePath))
-			continue
+	jobs := v.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(checks) {
+		jobs = len(checks)
+	}
+
+	queue := make(chan *fileCheck)
+	done := make(chan *fileCheck)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			for check := range queue {
+				v.check(check, tracker)
+				done <- check
+			}
+		}()
+	}
+	go func() {
+		for _, check := range checks {
+			queue <- check
+		}
+		close(queue)
+	}()
+
+	// Report the checks in order as soon as all earlier ones are done.
+	finished := make([]bool, len(checks))
+	next := 0
+	for range checks {
+		check := <-done
+		finished[check.index] = true
+		for next < len(checks) && finished[next] {
+			if tracker != nil {
+				tracker.Clear()
+			}
+			v.report(checks[next], stats)
+			next++
 		}
-		result.FileExists = true
+	}
+	if tracker != nil {
+		tracker.Finish()
+	}
 
-		actualSize, err := checksum.GetFileSize(filePath)
-		if err != nil {
-			stats.MissingFiles++
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to get size: %s", filePath))
-			continue
+	if v.Cache != nil {
+		if err := v.Cache.Save(); err != nil {
+			printer.DisplayMessage(printer.Warning, err.Error())
 		}
-		result.ActualSize = actualSize
+	}
 
-		actualChecksum, err := checksum.CalculateChecksum(filePath)
-		if err != nil {
-			stats.MissingFiles++
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to calculate checksum: %s", filePath))
+	return stats, nil
+}
+
+// check checks the size and checksum of the file, recording a failure
+// message if the file cannot be read.
+func (v *Verifier) check(c *fileCheck, tracker *progress.Tracker) {
+	if tracker != nil {
+		defer tracker.FileDone()
+	}
+
+	if _, err := os.Stat(c.result.Path); os.IsNotExist(err) {
+		c.failure = fmt.Sprintf("File not found: %s", c.result.Path)
+		return
+	}
+	c.result.FileExists = true
+
+	actualSize, err := checksum.GetFileSize(c.result.Path)
+	if err != nil {
+		c.failure = fmt.Sprintf("Failed to get size: %s", c.result.Path)
+		return
+	}
+	c.result.ActualSize = actualSize
+
+	var algorithms []string
+	expectedAlgorithm, expectedDigest, err := checksum.Parse(c.result.ExpectedSum)
+	if err != nil {
+		c.result.ChecksumError = err.Error()
+	} else {
+		algorithms = append(algorithms, expectedAlgorithm)
+	}
+	for _, algorithm := range v.Algorithms {
+		algorithms = append(algorithms, strings.ToLower(algorithm))
+	}
+
+	// Take what we can from the cache and compute the rest in one pass.
+	c.result.ActualSums = make(map[string]string)
+	var missing []string
+	for _, algorithm := range algorithms {
+		if _, ok := c.result.ActualSums[algorithm]; ok {
 			continue
 		}
-		result.ActualSum = actualChecksum
+		if v.Cache != nil && !v.Rehash {
+			if sum, ok := v.Cache.Lookup(c.result.Path, algorithm); ok {
+				c.result.ActualSums[algorithm] = sum
+				continue
+			}
+		}
+		missing = append(missing, algorithm)
+	}
+	if len(missing) == 0 {
+		c.cached = len(algorithms) > 0
+		if tracker != nil {
+			tracker.Add(actualSize)
+		}
+	} else {
+		// Take the attributes before hashing, so that the cache does not
+		// keep checksums of a file that changed meanwhile.
+		info, err := os.Stat(c.result.Path)
+		if err != nil {
+			c.failure = fmt.Sprintf("Failed to calculate checksum: %s", c.result.Path)
+			return
+		}
+		sums, err := hashFile(c.result.Path, tracker, missing...)
+		if err != nil {
+			c.failure = fmt.Sprintf("Failed to calculate checksum: %s", c.result.Path)
+			return
+		}
+		var values []string
+		for algorithm, sum := range sums {
+			c.result.ActualSums[algorithm] = sum
+			values = append(values, sum)
+		}
+		if v.Cache != nil {
+			_ = v.Cache.Store(c.result.Path, info, values...)
+		}
+	}
 
-		result.SizeMatch = (result.ActualSize == result.ExpectedSize)
-		result.ChecksumMatch = (result.ActualSum == result.ExpectedSum)
+	if c.noSize {
+		c.result.ExpectedSize = actualSize
+	}
+	c.result.SizeMatch = (c.result.ActualSize == c.result.ExpectedSize)
+	if c.result.ChecksumError == "" {
+		c.result.ActualSum = c.result.ActualSums[expectedAlgorithm]
+		c.result.ChecksumMatch = (c.result.ActualSum == expectedAlgorithm+":"+expectedDigest)
+	}
+	c.extra = v.Algorithms
+}
 
-		if !result.SizeMatch {
-			stats.SizeFailed++
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Size mismatch: %s (expected: %d, actual: %d)",
-				fileName, result.ExpectedSize, result.ActualSize))
+// report prints the outcome of the check, unless v.Quiet is set, and adds
+// it to stats.
+func (v *Verifier) report(c *fileCheck, stats *VerificationStats) {
+	if c.failure != "" {
+		stats.MissingFiles++
+		v.display(printer.Error, c.failure)
+		c.result.Error = c.failure
+		c.result.Status = StatusUnreadable
+		if !c.result.FileExists {
+			c.result.Status = StatusMissing
+			stats.Failures = append(stats.Failures, c.result)
 		}
+		stats.Results = append(stats.Results, c.result)
+		return
+	}
 
-		if !result.ChecksumMatch {
-			stats.ChecksumFailed++
-			printer.DisplayMessage(printer.Error, fmt.Sprintf("Checksum mismatch: %s (expected: %s, actual: %s)",
-				fileName, result.ExpectedSum, result.ActualSum))
-		}
+	if c.cached {
+		stats.CachedFiles++
+		c.result.Cached = true
+	}
+
+	result := &c.result
+	if !result.SizeMatch {
+		stats.SizeFailed++
+		v.display(printer.Error, fmt.Sprintf("Size mismatch: %s (expected: %d, actual: %d)",
+			c.fileName, result.ExpectedSize, result.ActualSize))
+	}
+
+	if result.ChecksumError != "" {
+		stats.UnsupportedChecksums++
+		v.display(printer.Error, fmt.Sprintf("Cannot verify checksum of %s: %s", c.fileName, result.ChecksumError))
+	} else if !result.ChecksumMatch {
+		stats.ChecksumFailed++
+		v.display(printer.Error, fmt.Sprintf("Checksum mismatch: %s (expected: %s, actual: %s)",
+			c.fileName, result.ExpectedSum, result.ActualSum))
+	}
+
+	switch {
+	case !result.SizeMatch:
+		result.Status = StatusSizeMismatch
+	case result.ChecksumError != "":
+		result.Status = StatusUnverifiable
+	case !result.ChecksumMatch:
+		result.Status = StatusChecksumMismatch
+	default:
+		result.Status = StatusVerified
+	}
+
+	// A file whose checksum cannot be verified is only repairable if its
+	// size is wrong.
+	if !result.SizeMatch || (!result.ChecksumMatch && result.ChecksumError == "") {
+		stats.Failures = append(stats.Failures, *result)
+	}
+	stats.Results = append(stats.Results, *result)
 
-		if result.SizeMatch && result.ChecksumMatch {
-			stats.VerifiedFiles++
-			printer.DisplayMessage(printer.Info, fmt.Sprintf("Verified: %s", fileName))
+	if result.Status == StatusVerified {
+		stats.VerifiedFiles++
+		message := fmt.Sprintf("Verified: %s", c.fileName)
+		if len(c.extra) > 0 {
+			var sums []string
+			for _, algorithm := range c.extra {
+				sums = append(sums, result.ActualSums[strings.ToLower(algorithm)])
+			}
+			message += fmt.Sprintf(" (%s)", strings.Join(sums, ", "))
 		}
+		v.display(printer.Info, message)
 	}
+}
 
-	return stats, nil
+// display prints a message about an individual file unless v.Quiet is
+// set.
+func (v *Verifier) display(msgType printer.MessageType, message string) {
+	if !v.Quiet {
+		printer.DisplayMessage(msgType, message)
+	}
+}
+
+// hashFile calculates the checksums of a file with the algorithms in one
+// pass, adding the bytes read to tracker if it is not nil.
+func hashFile(filePath string, tracker *progress.Tracker, algorithms ...string) (map[string]string, error) {
+	if tracker == nil {
+		return checksum.CalculateFile(filePath, algorithms...)
+	}
+
+	file, err := os.Open(filePath) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return checksum.Calculate(tracker.Reader(file), algorithms...)
 }
 
 func (v *Verifier) GetFileChecksum(filePath string) (string, error) {
@@ -1,9 +1,16 @@
 package verifier
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
 )
 
 func TestVerifyLocalFiles(t *testing.T) {
@@ -15,19 +22,34 @@ func TestVerifyLocalFiles(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// Files in subdirectories are listed too.
+	if err := os.MkdirAll(filepath.Join(testDir, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "sub", "nested.txt"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
 	verifier := NewVerifier()
-	stats, err := verifier.VerifyLocalFiles(testDir)
+	var stats *VerificationStats
+	output := captureStdout(t, func() {
+		var err error
+		stats, err = verifier.VerifyLocalFiles(testDir)
+		if err != nil {
+			t.Fatalf("VerifyLocalFiles failed: %v", err)
+		}
+	})
 
-	if err != nil {
-		t.Fatalf("VerifyLocalFiles failed: %v", err)
+	if stats.TotalFiles != 2 {
+		t.Errorf("Expected 2 files, got %d", stats.TotalFiles)
 	}
 
-	if stats.TotalFiles != 1 {
-		t.Errorf("Expected 1 file, got %d", stats.TotalFiles)
+	if stats.VerifiedFiles != 2 {
+		t.Errorf("Expected 2 verified files, got %d", stats.VerifiedFiles)
 	}
 
-	if stats.VerifiedFiles != 1 {
-		t.Errorf("Expected 1 verified file, got %d", stats.VerifiedFiles)
+	if !strings.Contains(output, filepath.Join(testDir, "sub", "nested.txt")+"\t12\t") {
+		t.Errorf("output missing nested file:\n%s", output)
 	}
 }
 
@@ -64,6 +86,258 @@ func TestVerifyFiles(t *testing.T) {
 	}
 }
 
+// captureStdout returns what fn writes to standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	fn()
+	_ = w.Close()
+	os.Stdout = oldStdout
+	return <-output
+}
+
+func TestVerifyFilesParallel(t *testing.T) {
+	testDir := t.TempDir()
+
+	var expectedFiles []any
+	var wantOrder []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("file%02d.root", i)
+		content := []byte(strings.Repeat(name, i+1))
+		if err := os.WriteFile(filepath.Join(testDir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := checksum.CalculateChecksum(filepath.Join(testDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 3:
+			sum = "adler32:00000000"
+		case 7:
+			content = append(content, 'x')
+		}
+		expectedFiles = append(expectedFiles, map[string]any{
+			"uri":      "root://eospublic.cern.ch//eos/opendata/" + name,
+			"size":     float64(len(content)),
+			"checksum": sum,
+		})
+		if i != 3 && i != 7 {
+			wantOrder = append(wantOrder, "Verified: "+name)
+		}
+	}
+	expectedFiles = append(expectedFiles, map[string]any{"uri": "http://example.com/missing.root", "size": float64(1)})
+
+	var results []*VerificationStats
+	for _, jobs := range []int{1, 8} {
+		v := NewVerifier()
+		v.Jobs = jobs
+		v.Cache = nil
+		var stats *VerificationStats
+		output := captureStdout(t, func() {
+			var err error
+			stats, err = v.VerifyFiles(testDir, expectedFiles)
+			if err != nil {
+				t.Fatalf("VerifyFiles failed: %v", err)
+			}
+		})
+
+		var gotOrder []string
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "==> Verified: ") {
+				gotOrder = append(gotOrder, strings.TrimPrefix(line, "==> "))
+			}
+		}
+		if !reflect.DeepEqual(gotOrder, wantOrder) {
+			t.Errorf("jobs=%d: output order = %q, want %q", jobs, gotOrder, wantOrder)
+		}
+		results = append(results, stats)
+	}
+
+	want := &VerificationStats{TotalFiles: 21, VerifiedFiles: 18, SizeFailed: 1, ChecksumFailed: 1, MissingFiles: 1}
+	wantFailures := []string{"file03.root", "file07.root", "missing.root"}
+	for _, stats := range results {
+		var failures []string
+		for _, failure := range stats.Failures {
+			failures = append(failures, filepath.Base(failure.Path))
+		}
+		if !reflect.DeepEqual(failures, wantFailures) {
+			t.Errorf("failures = %q, want %q", failures, wantFailures)
+		}
+		stats.Failures = nil
+
+		if len(stats.Results) != 21 {
+			t.Fatalf("got %d results, want 21", len(stats.Results))
+		}
+		statuses := map[string]string{}
+		for i, result := range stats.Results {
+			if i < 20 && filepath.Base(result.Path) != fmt.Sprintf("file%02d.root", i) {
+				t.Errorf("result %d is %s, results should be in order", i, result.Path)
+			}
+			if result.Status != StatusVerified {
+				statuses[filepath.Base(result.Path)] = result.Status
+			}
+		}
+		wantStatuses := map[string]string{
+			"file03.root":  StatusChecksumMismatch,
+			"file07.root":  StatusSizeMismatch,
+			"missing.root": StatusMissing,
+		}
+		if !reflect.DeepEqual(statuses, wantStatuses) {
+			t.Errorf("statuses = %v, want %v", statuses, wantStatuses)
+		}
+		stats.Results = nil
+
+		if !reflect.DeepEqual(stats, want) {
+			t.Errorf("stats = %+v, want %+v", stats, want)
+		}
+	}
+}
+
+func TestVerifyFilesCache(t *testing.T) {
+	testDir := t.TempDir()
+	testFile := filepath.Join(testDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("test content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []any{
+		map[string]any{
+			"uri":      "http://example.com/test.txt",
+			"size":     float64(12),
+			"checksum": "adler32:1f2904dc",
+		},
+	}
+
+	verify := func(rehash bool) *VerificationStats {
+		t.Helper()
+		v := NewVerifier()
+		v.Rehash = rehash
+		var stats *VerificationStats
+		captureStdout(t, func() {
+			var err error
+			stats, err = v.VerifyFiles(testDir, expectedFiles)
+			if err != nil {
+				t.Fatalf("VerifyFiles failed: %v", err)
+			}
+		})
+		return stats
+	}
+
+	if stats := verify(false); stats.CachedFiles != 0 || stats.VerifiedFiles != 1 {
+		t.Errorf("first run: %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, checksum.CacheFileName)); err != nil {
+		t.Errorf("checksum cache not saved: %v", err)
+	}
+	if stats := verify(false); stats.CachedFiles != 1 || stats.VerifiedFiles != 1 {
+		t.Errorf("second run should use the cache: %+v", stats)
+	}
+	if stats := verify(true); stats.CachedFiles != 0 || stats.VerifiedFiles != 1 {
+		t.Errorf("rehash run should not use the cache: %+v", stats)
+	}
+
+	// A changed file is hashed again, even with the same size.
+	if err := os.WriteFile(testFile, []byte("TEST CONTENT"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(testFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if stats := verify(false); stats.CachedFiles != 0 || stats.ChecksumFailed != 1 {
+		t.Errorf("changed file should be hashed again: %+v", stats)
+	}
+
+	// The sidecar file is not listed as a local file.
+	stats, err := NewVerifier().VerifyLocalFiles(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalFiles != 1 {
+		t.Errorf("VerifyLocalFiles counted %d files, want 1", stats.TotalFiles)
+	}
+}
+
+func TestVerifyFilesAlgorithms(t *testing.T) {
+	testDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte("test"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectedFiles := []any{
+		map[string]any{"uri": "http://example.com/a.txt", "size": float64(4), "checksum": "sha256:9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"},
+		map[string]any{"uri": "http://example.com/b.txt", "size": float64(4), "checksum": "blake3:045d01c1"},
+		map[string]any{"uri": "http://example.com/c.txt", "size": float64(4)},
+		map[string]any{"uri": "http://example.com/d.txt", "size": float64(4), "checksum": "md5:00000000000000000000000000000000"},
+	}
+
+	v := NewVerifier()
+	v.Cache = nil
+	v.Algorithms = []string{"crc32c", "MD5"}
+	var stats *VerificationStats
+	output := captureStdout(t, func() {
+		var err error
+		stats, err = v.VerifyFiles(testDir, expectedFiles)
+		if err != nil {
+			t.Fatalf("VerifyFiles failed: %v", err)
+		}
+	})
+
+	if stats.VerifiedFiles != 1 || stats.UnsupportedChecksums != 2 || stats.ChecksumFailed != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if !strings.Contains(output, "Verified: a.txt (crc32c:86a072c0, md5:098f6bcd4621d373cade4e832627b4f6)") {
+		t.Errorf("output missing extra checksums:\n%s", output)
+	}
+	if len(stats.Failures) != 1 || filepath.Base(stats.Failures[0].Path) != "d.txt" {
+		t.Errorf("only the mismatching file should be repairable: %+v", stats.Failures)
+	}
+}
+
+func TestVerifyFilesRelativePaths(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(testDir, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "sub", "a.txt"), []byte("test"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []any{
+		// No size: verified by checksum only.
+		map[string]any{"path": "sub/a.txt", "uri": "sub/a.txt", "checksum": "adler32:045d01c1"},
+		map[string]any{"path": "sub/b.txt", "uri": "sub/b.txt", "size": float64(4), "checksum": "adler32:045d01c1"},
+	}
+
+	v := NewVerifier()
+	v.Cache = nil
+	var stats *VerificationStats
+	captureStdout(t, func() {
+		var err error
+		stats, err = v.VerifyFiles(testDir, expectedFiles)
+		if err != nil {
+			t.Fatalf("VerifyFiles failed: %v", err)
+		}
+	})
+
+	if stats.VerifiedFiles != 1 || stats.MissingFiles != 1 || stats.SizeFailed != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if len(stats.Failures) != 1 || stats.Failures[0].Path != filepath.Join(testDir, "sub", "b.txt") {
+		t.Errorf("unexpected failures: %+v", stats.Failures)
+	}
+}
+
 func TestParseChecksumMetadata(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -13,8 +13,10 @@ import (
 	"go-hep.org/x/hep/xrootd/xrdfs"
 	"go-hep.org/x/hep/xrootd/xrdio"
 
+	"github.com/clelange/cernopendata-client-go/internal/checksum"
 	"github.com/clelange/cernopendata-client-go/internal/config"
 	"github.com/clelange/cernopendata-client-go/internal/printer"
+	"github.com/clelange/cernopendata-client-go/internal/resumer"
 	"github.com/clelange/cernopendata-client-go/internal/utils"
 )
 
@@ -139,19 +141,11 @@ func (d *Downloader) DownloadFile(ctx context.Context, url, destPath string, res
 		if resume {
 			if fi, err := os.Stat(destPath); err == nil {
 				existingSize = fi.Size()
-				if existingSize > 0 {
-					printer.DisplayMessage(printer.Note, fmt.Sprintf("Resuming %s from %d bytes", destPath, existingSize))
-				}
 			} else if !os.IsNotExist(err) {
 				return nil, fmt.Errorf("error checking file: %w", err)
 			}
 		}
 
-		var offset int64 = 0
-		if resume && existingSize > 0 {
-			offset = existingSize
-		}
-
 		file, err := fs.Open(ctx, parsedURL.Path, xrdfs.OpenModeOwnerRead, xrdfs.OpenOptionsOpenRead|xrdfs.OpenOptionsSequentiallyIO)
 		if err != nil {
 			lastErr = err
@@ -159,6 +153,33 @@ func (d *Downloader) DownloadFile(ctx context.Context, url, destPath string, res
 			continue
 		}
 
+		// Only append to the bytes that agree with the server.
+		if existingSize > 0 {
+			verified, err := resumer.VerifiedOffset(destPath, existingSize, readRange(file))
+			if err != nil {
+				_ = file.Close(ctx)
+				lastErr = err
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Failed to verify partial file: %v", err))
+				continue
+			}
+			if verified < existingSize {
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Partial file %s differs from the server after %d bytes", destPath, verified))
+				if err := os.Truncate(destPath, verified); err != nil {
+					_ = file.Close(ctx)
+					return nil, fmt.Errorf("failed to truncate file: %w", err)
+				}
+				existingSize = verified
+			}
+			if existingSize > 0 {
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Resuming %s from %d bytes", destPath, existingSize))
+			}
+		}
+
+		var offset int64 = 0
+		if resume && existingSize > 0 {
+			offset = existingSize
+		}
+
 		if err := os.MkdirAll(filepath.Dir(destPath), 0750); err != nil {
 			_ = file.Close(ctx)
 			return nil, fmt.Errorf("failed to create directory: %w", err)
@@ -272,6 +293,26 @@ func (d *Downloader) DownloadFile(ctx context.Context, url, destPath string, res
 	}, lastErr
 }
 
+// readRange returns a function that reads byte ranges of the remote file,
+// to compare them with a partial file.
+func readRange(file xrdfs.File) resumer.FetchFunc {
+	return func(offset, length int64) ([]byte, error) {
+		buf := make([]byte, length)
+		var read int
+		for read < len(buf) {
+			n, err := file.ReadAt(buf[read:], offset+int64(read))
+			read += n
+			if err == io.EOF || (err == nil && n == 0) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf[:read], nil
+	}
+}
+
 func (d *Downloader) DownloadFiles(ctx context.Context, files []any, baseDir string, retry int, retrySleep int, verbose bool, dryRun bool, showProgress bool) DownloadStats {
 	d.retryLimit = retry
 	d.retrySleep = retrySleep
@@ -287,6 +328,9 @@ func (d *Downloader) DownloadFiles(ctx context.Context, files []any, baseDir str
 		return stats
 	}
 
+	cache := checksum.NewCache()
+	defer func() { _ = cache.Save() }()
+
 	for i, file := range files {
 		fileMap, ok := file.(map[string]any)
 		if !ok {
@@ -297,14 +341,14 @@ func (d *Downloader) DownloadFiles(ctx context.Context, files []any, baseDir str
 
 		uri, _ := fileMap["uri"].(string)
 		size, _ := fileMap["size"].(float64)
-		checksum, _ := fileMap["checksum"].(string)
+		expectedChecksum, _ := fileMap["checksum"].(string)
 
 		stats.TotalBytes += int64(size)
 
 		printer.DisplayMessage(printer.Info, fmt.Sprintf("Downloading file %d/%d: %s", i+1, stats.TotalFiles, filepath.Base(uri)))
 
 		if dryRun {
-			printer.DisplayMessage(printer.Note, fmt.Sprintf("Would download: %s (size: %d, checksum: %s)", uri, int64(size), checksum))
+			printer.DisplayMessage(printer.Note, fmt.Sprintf("Would download: %s (size: %d, checksum: %s)", uri, int64(size), expectedChecksum))
 			stats.DownloadedFiles++
 			stats.DownloadedBytes += int64(size)
 			continue
@@ -313,7 +357,22 @@ func (d *Downloader) DownloadFiles(ctx context.Context, files []any, baseDir str
 		destPath := filepath.Join(baseDir, filepath.Base(uri))
 
 		if fi, err := os.Stat(destPath); err == nil {
-			if fi.Size() >= int64(size) {
+			// Prefer a checksum cached by verify-files over the size of
+			// the existing file. Shorter files are resumed instead.
+			if cached, match := cache.Matches(destPath, expectedChecksum); cached && expectedChecksum != "" && fi.Size() >= int64(size) {
+				if match {
+					printer.DisplayMessage(printer.Note, fmt.Sprintf("File already exists and is verified: %s", destPath))
+					stats.SkippedFiles++
+					continue
+				}
+				printer.DisplayMessage(printer.Note, fmt.Sprintf("Checksum of existing file does not match, downloading again: %s", destPath))
+				if err := os.Remove(destPath); err != nil {
+					printer.DisplayMessage(printer.Error, fmt.Sprintf("Failed to remove %s: %v", destPath, err))
+					stats.FailedFiles++
+					continue
+				}
+				cache.Forget(destPath)
+			} else if fi.Size() >= int64(size) {
 				printer.DisplayMessage(printer.Note, fmt.Sprintf("File already exists: %s", destPath))
 				stats.SkippedFiles++
 				continue